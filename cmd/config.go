@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/vinamra28/whytho/internal/services"
+)
+
+const configUsage = "Usage:\n" +
+	"  whytho config lint [flags] [file]   validate .whytho/config.yaml\n" +
+	"  whytho config schema                print the JSON Schema of .whytho/config.yaml"
+
+// runConfig implements "whytho config", which checks .whytho/ files before
+// they are pushed.
+func runConfig(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing subcommand\n\n%s", configUsage)
+	}
+	switch args[0] {
+	case "lint":
+		return runConfigLint(args[1:])
+	case "schema":
+		_, err := os.Stdout.Write(services.WhyThoConfigSchema)
+		return err
+	default:
+		return fmt.Errorf("unknown subcommand %q\n\n%s", args[0], configUsage)
+	}
+}
+
+// runConfigLint validates the repository's .whytho/config.yaml, including
+// that the guidance files it maps exist, or a config file given as argument.
+func runConfigLint(args []string) error {
+	flags := flag.NewFlagSet("config lint", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: whytho config lint [flags] [file]\n\n"+
+			"Validates the .whytho/config.yaml of -repo and checks that the guidance files\n"+
+			"it maps exist. Given a file, validates only its content.\n\nFlags:")
+		flags.PrintDefaults()
+	}
+	repoDir := flags.String("repo", ".", "repository whose .whytho/ files are linted")
+	ref := flags.String("ref", "", "git ref to lint, defaults to the working tree")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	name := ".whytho/config.yaml"
+	var problems []string
+	switch flags.NArg() {
+	case 0:
+		repository := services.NewLocalRepository(*repoDir, "")
+		if _, err := repository.GetFile(*repoDir, name, *ref); errors.Is(err, services.ErrFileNotFound) {
+			fmt.Printf("%s not found, nothing to lint.\n", name)
+			return nil
+		}
+		var err error
+		if problems, err = services.ValidateWhyThoFiles(repository, *repoDir, *ref); err != nil {
+			return err
+		}
+	case 1:
+		name = flags.Arg(0)
+		content, err := os.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		problems = services.ValidateWhyThoConfig(string(content))
+	default:
+		flags.Usage()
+		return fmt.Errorf("expected at most one file")
+	}
+
+	if len(problems) == 0 {
+		fmt.Printf("%s is valid.\n", name)
+		return nil
+	}
+	for _, problem := range problems {
+		fmt.Printf("%s: %s\n", name, problem)
+	}
+	return fmt.Errorf("%d problem(s) found", len(problems))
+}
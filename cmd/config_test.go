@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestMain runs the whytho command instead of the tests when re-executed by
+// runWhytho, so exit statuses can be checked.
+func TestMain(m *testing.M) {
+	if args := os.Getenv("WHYTHO_TEST_ARGS"); args != "" {
+		os.Args = append([]string{"whytho"}, strings.Split(args, "\n")...)
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// runWhytho runs the whytho command with args and returns its exit status and
+// output.
+func runWhytho(t *testing.T, args ...string) (int, string, string) {
+	t.Helper()
+	cmd := exec.Command(os.Args[0])
+	cmd.Env = append(os.Environ(), "WHYTHO_TEST_ARGS="+strings.Join(args, "\n"))
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		return 0, stdout.String(), stderr.String()
+	case errors.As(err, &exitErr):
+		return exitErr.ExitCode(), stdout.String(), stderr.String()
+	default:
+		t.Fatalf("failed to run whytho: %v", err)
+		return 0, "", ""
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConfigLintExitStatus(t *testing.T) {
+	dir := t.TempDir()
+	valid := filepath.Join(dir, "valid.yaml")
+	writeFile(t, valid, "excludePaths: [vendor/]\ngating:\n  block: [CRITICAL]\n")
+	invalid := filepath.Join(dir, "invalid.yaml")
+	writeFile(t, invalid, "excludePath: [vendor/]\n")
+
+	emptyRepo := t.TempDir()
+	validRepo := t.TempDir()
+	writeFile(t, filepath.Join(validRepo, ".whytho/config.yaml"), "guidance:\n  \"*.sql\": .whytho/sql.md\n")
+	writeFile(t, filepath.Join(validRepo, ".whytho/sql.md"), "Check indexes.")
+	brokenRepo := t.TempDir()
+	writeFile(t, filepath.Join(brokenRepo, ".whytho/config.yaml"), "guidance:\n  \"*.sql\": .whytho/missing.md\n")
+
+	tests := []struct {
+		name       string
+		args       []string
+		wantStatus int
+		wantStdout string
+		wantStderr string
+	}{
+		{
+			name:       "valid file",
+			args:       []string{"config", "lint", valid},
+			wantStdout: "valid.yaml is valid.",
+		},
+		{
+			name:       "invalid file",
+			args:       []string{"config", "lint", invalid},
+			wantStatus: 1,
+			wantStdout: "field excludePath not found",
+			wantStderr: "whytho config: 1 problem(s) found",
+		},
+		{
+			name:       "missing file",
+			args:       []string{"config", "lint", filepath.Join(dir, "missing.yaml")},
+			wantStatus: 1,
+			wantStderr: "no such file or directory",
+		},
+		{
+			name:       "repository without config",
+			args:       []string{"config", "lint", "-repo", emptyRepo},
+			wantStdout: "not found, nothing to lint",
+		},
+		{
+			name:       "repository with valid config",
+			args:       []string{"config", "lint", "-repo", validRepo},
+			wantStdout: ".whytho/config.yaml is valid.",
+		},
+		{
+			name:       "repository with missing guidance file",
+			args:       []string{"config", "lint", "-repo", brokenRepo},
+			wantStatus: 1,
+			wantStdout: "guidance file .whytho/missing.md does not exist",
+			wantStderr: "whytho config: 1 problem(s) found",
+		},
+		{
+			name:       "too many arguments",
+			args:       []string{"config", "lint", valid, invalid},
+			wantStatus: 1,
+			wantStderr: "expected at most one file",
+		},
+		{
+			name:       "unknown flag",
+			args:       []string{"config", "lint", "-strict"},
+			wantStatus: 1,
+			wantStderr: "flag provided but not defined",
+		},
+		{
+			name:       "unknown subcommand",
+			args:       []string{"config", "check"},
+			wantStatus: 1,
+			wantStderr: `unknown subcommand "check"`,
+		},
+		{
+			name:       "schema",
+			args:       []string{"config", "schema"},
+			wantStdout: `"title": "WhyTho configuration"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, stdout, stderr := runWhytho(t, tt.args...)
+			if status != tt.wantStatus {
+				t.Errorf("exit status = %d, want %d\nstdout: %s\nstderr: %s", status, tt.wantStatus, stdout, stderr)
+			}
+			if !strings.Contains(stdout, tt.wantStdout) {
+				t.Errorf("stdout = %q, want it to contain %q", stdout, tt.wantStdout)
+			}
+			if !strings.Contains(stderr, tt.wantStderr) {
+				t.Errorf("stderr = %q, want it to contain %q", stderr, tt.wantStderr)
+			}
+		})
+	}
+}
@@ -2,11 +2,11 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
-	"time"
 
 	"github.com/sirupsen/logrus"
 	"github.com/vinamra28/whytho/internal/config"
@@ -14,6 +14,36 @@ import (
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "review":
+			if err := runReview(os.Args[2:]); err != nil {
+				fmt.Fprintln(os.Stderr, "whytho review:", err)
+				os.Exit(1)
+			}
+			return
+		case "replay":
+			if err := runReplay(os.Args[2:]); err != nil {
+				fmt.Fprintln(os.Stderr, "whytho replay:", err)
+				os.Exit(1)
+			}
+			return
+		case "config":
+			if err := runConfig(os.Args[2:]); err != nil {
+				fmt.Fprintln(os.Stderr, "whytho config:", err)
+				os.Exit(1)
+			}
+			return
+		case "serve":
+		default:
+			fmt.Fprintf(os.Stderr, "unknown command %q\n\nUsage:\n  whytho [serve]    start the webhook server\n  whytho review     review local changes\n  whytho replay     process a saved GitLab webhook\n  whytho config     lint .whytho/config.yaml\n", os.Args[1])
+			os.Exit(2)
+		}
+	}
+	serve()
+}
+
+func serve() {
 	logrus.SetFormatter(&logrus.JSONFormatter{})
 	logrus.SetLevel(logrus.InfoLevel)
 
@@ -46,7 +76,7 @@ func main() {
 
 	logrus.Info("Received shutdown signal")
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
 	defer cancel()
 
 	if err := srv.Shutdown(ctx); err != nil {
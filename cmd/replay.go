@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/vinamra28/whytho/internal/config"
+	"github.com/vinamra28/whytho/internal/handlers"
+	"github.com/vinamra28/whytho/internal/queue"
+	"github.com/vinamra28/whytho/internal/services"
+)
+
+// gitlabEventHeaders maps a payload's object_kind to its X-Gitlab-Event header.
+var gitlabEventHeaders = map[string]string{
+	"merge_request": "Merge Request Hook",
+	"note":          "Note Hook",
+}
+
+// runReplay implements "whytho replay": it feeds a saved GitLab webhook
+// through the webhook handler and runs the queued jobs to completion.
+func runReplay(args []string) error {
+	flags := flag.NewFlagSet("replay", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: whytho replay [flags] <payload.json>\n\n"+
+			"Processes a saved GitLab webhook payload, e.g. one stored through WEBHOOK_PAYLOAD_DIR,\n"+
+			"as the server would. It is configured by the same environment variables as the server.\n\nFlags:")
+		flags.PrintDefaults()
+	}
+	dryRun := flags.Bool("dry-run", true, "write the review to an artifact in DRY_RUN_DIR instead of posting it")
+	event := flags.String("event", "", "X-Gitlab-Event header, derived from the payload's object_kind by default")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return fmt.Errorf("expected one payload file")
+	}
+
+	payload, err := os.ReadFile(flags.Arg(0))
+	if err != nil {
+		return fmt.Errorf("failed to read payload: %w", err)
+	}
+	if *event == "" {
+		var kind struct {
+			ObjectKind string `json:"object_kind"`
+		}
+		if err := json.Unmarshal(payload, &kind); err != nil {
+			return fmt.Errorf("failed to parse payload: %w", err)
+		}
+		if *event = gitlabEventHeaders[kind.ObjectKind]; *event == "" {
+			return fmt.Errorf("unsupported object_kind %q, set -event", kind.ObjectKind)
+		}
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	if cfg.GitLabToken == "" {
+		return fmt.Errorf("GITLAB_TOKEN environment variable is required to replay GitLab webhooks")
+	}
+	// The payload was authenticated when it was received
+	cfg.WebhookSecrets, cfg.WebhookScopedSecrets, cfg.WebhookSigningTokens = nil, nil, nil
+	cfg.WebhookPayloadDir = ""
+	cfg.DryRun = *dryRun
+
+	llmProvider, err := services.NewLLMProvider(cfg.LLMProvider, cfg.LLMModel, cfg.LLMAPIKey, cfg.LLMBaseURL)
+	if err != nil {
+		return fmt.Errorf("failed to create LLM provider: %w", err)
+	}
+	reviewService := services.NewReviewService(llmProvider, cfg.ReviewMaxPromptTokens, cfg.ReviewBatchConcurrency).
+		WithConfigResolver(services.NewConfigResolver(cfg.ConfigDefaultsDir, cfg.ConfigGroupRepo, cfg.ConfigCacheTTL))
+	gitlabService := services.NewGitLabService(cfg.GitLabToken, cfg.GitLabBaseURL)
+
+	// The in-memory queue runs every pending job before shutting down
+	jobQueue := queue.New(queue.NewMemoryStore(), 1, cfg.QueueMaxAttempts)
+	webhookHandler := handlers.NewWebhookHandler(gitlabService, reviewService, jobQueue, cfg)
+	if err := jobQueue.Start(); err != nil {
+		return fmt.Errorf("failed to start job queue: %w", err)
+	}
+
+	gin.SetMode(gin.ReleaseMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/webhook", bytes.NewReader(payload))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Request.Header.Set("X-Gitlab-Event", *event)
+	webhookHandler.HandleWebhook(c)
+	logrus.WithFields(logrus.Fields{
+		"status":   recorder.Code,
+		"response": recorder.Body.String(),
+	}).Info("Webhook replayed")
+
+	if err := jobQueue.Shutdown(context.Background()); err != nil {
+		return fmt.Errorf("failed to run queued jobs: %w", err)
+	}
+	if recorder.Code != http.StatusOK {
+		return fmt.Errorf("webhook was answered with status %d: %s", recorder.Code, recorder.Body.String())
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/vinamra28/whytho/internal/config"
+	"github.com/vinamra28/whytho/internal/models"
+	"github.com/vinamra28/whytho/internal/services"
+)
+
+// runReview implements "whytho review": it reviews local changes with the same
+// pipeline as the server and prints the findings instead of posting them.
+func runReview(args []string) error {
+	flags := flag.NewFlagSet("review", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: whytho review [flags]\n\n"+
+			"Reviews the changes of a local repository since its merge base with -base,\n"+
+			"uncommitted changes to tracked files included, or the git-style patch given\n"+
+			"by -patch. The LLM is configured by the same environment variables as the server.\n\nFlags:")
+		flags.PrintDefaults()
+	}
+	repoDir := flags.String("repo", ".", "repository to review")
+	base := flags.String("base", "main", "ref the changes are reviewed against; .whytho/ files are read from it")
+	patchPath := flags.String("patch", "", "review this patch file instead, - reads it from stdin; .whytho/ files are read from -repo's working tree")
+	title := flags.String("title", "", "title given to the LLM, defaults to the last commit's subject")
+	description := flags.String("description", "", "description given to the LLM, defaults to the last commit's body")
+	jsonOutput := flags.Bool("json", false, "print the review as JSON")
+	verbose := flags.Bool("v", false, "log progress to stderr")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	logrus.SetLevel(logrus.WarnLevel)
+	if *verbose {
+		logrus.SetLevel(logrus.InfoLevel)
+	}
+
+	var repository *services.LocalRepository
+	if *patchPath != "" {
+		patch, err := readPatch(*patchPath)
+		if err != nil {
+			return err
+		}
+		repository = services.NewLocalPatch(*repoDir, patch)
+	} else {
+		repository = services.NewLocalRepository(*repoDir, *base)
+	}
+
+	pr, err := repository.GetPullRequest(*repoDir, 0)
+	if err != nil {
+		return err
+	}
+	if *title != "" {
+		pr.Title = *title
+	}
+	if *description != "" {
+		pr.Description = *description
+	}
+	changes, err := repository.GetChanges(*repoDir, 0)
+	if err != nil {
+		return err
+	}
+	if len(changes) == 0 {
+		fmt.Println("No changes to review.")
+		return nil
+	}
+
+	cfg, err := config.LoadLocal()
+	if err != nil {
+		return err
+	}
+	llmProvider, err := services.NewLLMProvider(cfg.LLMProvider, cfg.LLMModel, cfg.LLMAPIKey, cfg.LLMBaseURL)
+	if err != nil {
+		return fmt.Errorf("failed to create LLM provider: %w", err)
+	}
+	reviewService := services.NewReviewService(llmProvider, cfg.ReviewMaxPromptTokens, cfg.ReviewBatchConcurrency).
+		WithConfigResolver(services.NewConfigResolver(cfg.ConfigDefaultsDir, "", 0))
+
+	review, err := reviewService.ReviewCode(context.Background(), changes, pr.Title, pr.Description, services.ReviewTarget{
+		Provider:     repository,
+		Repo:         *repoDir,
+		TargetBranch: pr.TargetBranch,
+		HeadRef:      services.WorkingTree,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to review code: %w", err)
+	}
+
+	// Report findings by their file line rather than the DIFF_LINE the LLM saw
+	for i, comment := range review.PositionedComments {
+		if resolved, err := services.ResolveDiffPosition(changes, comment); err == nil {
+			review.PositionedComments[i] = resolved
+		}
+	}
+	sort.SliceStable(review.PositionedComments, func(i, j int) bool {
+		a, b := review.PositionedComments[i], review.PositionedComments[j]
+		if a.FilePath != b.FilePath {
+			return a.FilePath < b.FilePath
+		}
+		return findingLine(a) < findingLine(b)
+	})
+
+	if *jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(review)
+	}
+	printReview(os.Stdout, review)
+	return nil
+}
+
+func readPatch(path string) (string, error) {
+	var content []byte
+	var err error
+	if path == "-" {
+		content, err = io.ReadAll(os.Stdin)
+	} else {
+		content, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read patch: %w", err)
+	}
+	return string(content), nil
+}
+
+// findingLine is the file line a finding refers to, 0 when it could not be resolved.
+func findingLine(comment models.PositionedComment) int {
+	if comment.NewLineNumber != 0 {
+		return comment.NewLineNumber
+	}
+	return comment.OldLineNumber
+}
+
+func printReview(w io.Writer, review *models.CodeReview) {
+	for _, comment := range review.PositionedComments {
+		location := fmt.Sprintf("%s:%d", comment.FilePath, findingLine(comment))
+		switch {
+		case comment.NewLineNumber == 0 && comment.OldLineNumber != 0:
+			location += " (removed line)"
+		case findingLine(comment) == 0:
+			location = fmt.Sprintf("%s (diff line %d)", comment.FilePath, comment.LineNumber)
+		}
+		header := fmt.Sprintf("%s [%s]", location, comment.Severity)
+		if comment.Rule != "" {
+			header += " " + comment.Rule
+		}
+		fmt.Fprintln(w, header)
+		fmt.Fprintln(w, indent(comment.Comment, "    "))
+		if comment.Suggestion != "" {
+			fmt.Fprintln(w, "    Suggested change:")
+			fmt.Fprintln(w, indent(comment.Suggestion, "    | "))
+		}
+		fmt.Fprintln(w)
+	}
+
+	for _, comment := range review.Comments {
+		fmt.Fprintln(w, indent(comment, "    "))
+		fmt.Fprintln(w)
+	}
+
+	if review.Summary != "" {
+		fmt.Fprintln(w, "Summary:")
+		fmt.Fprintln(w, indent(review.Summary, "    "))
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintf(w, "%d finding(s), %d general comment(s)\n", len(review.PositionedComments), len(review.Comments))
+}
+
+func indent(text, prefix string) string {
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = prefix + line
+	}
+	return strings.Join(lines, "\n")
+}
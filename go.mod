@@ -6,6 +6,7 @@ require (
 	github.com/gin-gonic/gin v1.9.1
 	github.com/sirupsen/logrus v1.9.3
 	github.com/xanzy/go-gitlab v0.95.2
+	go.etcd.io/bbolt v1.3.10
 	google.golang.org/genai v1.23.0
 	gopkg.in/yaml.v3 v3.0.1
 )
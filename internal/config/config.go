@@ -3,6 +3,9 @@ package config
 import (
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/sirupsen/logrus"
 )
@@ -10,28 +13,124 @@ import (
 type Config struct {
 	GitLabToken   string
 	GitLabBaseURL string
-	GeminiAPIKey  string
-	WebhookSecret string
+
+	// WebhookSecrets are accepted for any project, several allow rotation
+	WebhookSecrets []string
+	// WebhookScopedSecrets maps a project or group path to its own secrets
+	WebhookScopedSecrets map[string][]string
+	// WebhookSigningTokens validate GitLab's webhook-signature header when set
+	WebhookSigningTokens []string
+
+	// GitHubToken enables reviews of GitHub pull requests on /github/webhook
+	GitHubToken   string
+	GitHubBaseURL string
+	// GitHubWebhookSecrets validate the X-Hub-Signature-256 header when set
+	GitHubWebhookSecrets []string
+
+	// GiteaToken and GiteaBaseURL enable reviews of Gitea/Forgejo pull requests on /gitea/webhook
+	GiteaToken   string
+	GiteaBaseURL string
+	// GiteaWebhookSecrets validate the X-Gitea-Signature header when set
+	GiteaWebhookSecrets []string
+
+	// BitbucketToken and BitbucketBaseURL enable reviews of Bitbucket Server/Data Center pull requests on /bitbucket/webhook
+	BitbucketToken   string
+	BitbucketBaseURL string
+	// BitbucketWebhookSecrets validate the X-Hub-Signature header when set
+	BitbucketWebhookSecrets []string
+
+	LLMProvider string
+	LLMModel    string
+	LLMAPIKey   string
+	LLMBaseURL  string
+
+	// IncrementalReview limits reviews on MR updates to the newly pushed commits
+	IncrementalReview bool
+	// DraftReviews creates review comments as draft notes and publishes them at once
+	DraftReviews bool
+	// ReviewCommitStatus reports review progress as the "whytho/review" commit status
+	ReviewCommitStatus bool
+	// ReviewMaxPromptTokens is the estimated prompt size per LLM request; larger MRs are split into batches
+	ReviewMaxPromptTokens  int
+	ReviewBatchConcurrency int
+
+	// ConfigDefaultsDir holds the server default .whytho/ files every review inherits
+	ConfigDefaultsDir string
+	// ConfigGroupRepo names the repository in each group whose .whytho/ files the group's repositories inherit
+	ConfigGroupRepo string
+	// ConfigCacheTTL is how long inherited .whytho/ files are cached
+	ConfigCacheTTL time.Duration
+
+	// DryRun runs every review without posting, writing artifacts to DryRunDir instead
+	DryRun    bool
+	DryRunDir string
+	// WebhookPayloadDir stores every received GitLab webhook for "whytho replay" when set
+	WebhookPayloadDir string
+
+	QueueWorkers     int
+	QueueMaxAttempts int
+	// QueueStorePath enables the persistent BoltDB job store when set
+	QueueStorePath  string
+	ShutdownTimeout time.Duration
 }
 
 func Load() (*Config, error) {
 	logrus.Debug("Loading configuration from environment variables")
 
 	cfg := &Config{
-		GitLabToken:   os.Getenv("GITLAB_TOKEN"),
-		GitLabBaseURL: os.Getenv("GITLAB_BASE_URL"),
-		GeminiAPIKey:  os.Getenv("GEMINI_API_KEY"),
-		WebhookSecret: os.Getenv("WEBHOOK_SECRET"),
+		GitLabToken:             os.Getenv("GITLAB_TOKEN"),
+		GitLabBaseURL:           os.Getenv("GITLAB_BASE_URL"),
+		WebhookSecrets:          splitList(os.Getenv("WEBHOOK_SECRET")),
+		WebhookSigningTokens:    splitList(os.Getenv("WEBHOOK_SIGNING_TOKEN")),
+		GitHubToken:             os.Getenv("GITHUB_TOKEN"),
+		GitHubBaseURL:           os.Getenv("GITHUB_BASE_URL"),
+		GitHubWebhookSecrets:    splitList(os.Getenv("GITHUB_WEBHOOK_SECRET")),
+		GiteaToken:              os.Getenv("GITEA_TOKEN"),
+		GiteaBaseURL:            os.Getenv("GITEA_BASE_URL"),
+		GiteaWebhookSecrets:     splitList(os.Getenv("GITEA_WEBHOOK_SECRET")),
+		BitbucketToken:          os.Getenv("BITBUCKET_TOKEN"),
+		BitbucketBaseURL:        os.Getenv("BITBUCKET_BASE_URL"),
+		BitbucketWebhookSecrets: splitList(os.Getenv("BITBUCKET_WEBHOOK_SECRET")),
+		LLMProvider:             strings.ToLower(os.Getenv("LLM_PROVIDER")),
+		LLMModel:                os.Getenv("LLM_MODEL"),
+		LLMAPIKey:               os.Getenv("LLM_API_KEY"),
+		LLMBaseURL:              os.Getenv("LLM_BASE_URL"),
+
+		IncrementalReview:      getEnvBool("INCREMENTAL_REVIEW", false),
+		DraftReviews:           getEnvBool("DRAFT_REVIEWS", false),
+		ReviewCommitStatus:     getEnvBool("REVIEW_COMMIT_STATUS", true),
+		ReviewMaxPromptTokens:  getEnvInt("REVIEW_MAX_PROMPT_TOKENS", 100000),
+		ReviewBatchConcurrency: getEnvInt("REVIEW_BATCH_CONCURRENCY", 3),
+
+		ConfigDefaultsDir: os.Getenv("CONFIG_DEFAULTS_DIR"),
+		ConfigGroupRepo:   strings.Trim(os.Getenv("CONFIG_GROUP_REPO"), "/"),
+		ConfigCacheTTL:    getEnvDuration("CONFIG_CACHE_TTL", 5*time.Minute),
+
+		DryRun:            getEnvBool("DRY_RUN", false),
+		DryRunDir:         os.Getenv("DRY_RUN_DIR"),
+		WebhookPayloadDir: os.Getenv("WEBHOOK_PAYLOAD_DIR"),
+
+		QueueWorkers:     getEnvInt("QUEUE_WORKERS", 2),
+		QueueMaxAttempts: getEnvInt("QUEUE_MAX_ATTEMPTS", 3),
+		QueueStorePath:   os.Getenv("QUEUE_STORE_PATH"),
+		ShutdownTimeout:  getEnvDuration("SHUTDOWN_TIMEOUT", 30*time.Second),
 	}
 
-	if cfg.GitLabToken == "" {
-		logrus.Error("GITLAB_TOKEN environment variable is missing")
-		return nil, fmt.Errorf("GITLAB_TOKEN environment variable is required")
+	if cfg.LLMProvider == "" {
+		cfg.LLMProvider = "gemini"
+	}
+	if cfg.DryRunDir == "" {
+		cfg.DryRunDir = "dry-runs"
+	}
+
+	if cfg.GitLabToken == "" && cfg.GitHubToken == "" && cfg.GiteaToken == "" && cfg.BitbucketToken == "" {
+		logrus.Error("No SCM provider token is configured")
+		return nil, fmt.Errorf("GITLAB_TOKEN, GITHUB_TOKEN, GITEA_TOKEN or BITBUCKET_TOKEN environment variable is required")
 	}
 
-	if cfg.GeminiAPIKey == "" {
-		logrus.Error("GEMINI_API_KEY environment variable is missing")
-		return nil, fmt.Errorf("GEMINI_API_KEY environment variable is required")
+	if err := cfg.resolveLLMAPIKey(); err != nil {
+		logrus.WithError(err).Error("LLM provider configuration is invalid")
+		return nil, err
 	}
 
 	if cfg.GitLabBaseURL == "" {
@@ -41,12 +140,221 @@ func Load() (*Config, error) {
 		logrus.WithField("url", cfg.GitLabBaseURL).Info("Using custom GitLab base URL")
 	}
 
-	if cfg.WebhookSecret == "" {
-		logrus.Warn("WEBHOOK_SECRET not set - webhook signature verification disabled")
+	if cfg.GitHubToken != "" {
+		if cfg.GitHubBaseURL == "" {
+			cfg.GitHubBaseURL = "https://api.github.com"
+		}
+		logrus.WithFields(logrus.Fields{
+			"url":                cfg.GitHubBaseURL,
+			"signature_verified": len(cfg.GitHubWebhookSecrets) > 0,
+		}).Info("GitHub provider enabled")
+		if len(cfg.GitHubWebhookSecrets) == 0 {
+			logrus.Warn("GITHUB_WEBHOOK_SECRET not set - GitHub webhook signature verification disabled")
+		}
+	}
+
+	if cfg.GiteaToken != "" {
+		if cfg.GiteaBaseURL == "" {
+			logrus.Error("GITEA_BASE_URL environment variable is missing")
+			return nil, fmt.Errorf("GITEA_BASE_URL environment variable is required when GITEA_TOKEN is set")
+		}
+		logrus.WithFields(logrus.Fields{
+			"url":                cfg.GiteaBaseURL,
+			"signature_verified": len(cfg.GiteaWebhookSecrets) > 0,
+		}).Info("Gitea provider enabled")
+		if len(cfg.GiteaWebhookSecrets) == 0 {
+			logrus.Warn("GITEA_WEBHOOK_SECRET not set - Gitea webhook signature verification disabled")
+		}
+	}
+
+	if cfg.BitbucketToken != "" {
+		if cfg.BitbucketBaseURL == "" {
+			logrus.Error("BITBUCKET_BASE_URL environment variable is missing")
+			return nil, fmt.Errorf("BITBUCKET_BASE_URL environment variable is required when BITBUCKET_TOKEN is set")
+		}
+		logrus.WithFields(logrus.Fields{
+			"url":                cfg.BitbucketBaseURL,
+			"signature_verified": len(cfg.BitbucketWebhookSecrets) > 0,
+		}).Info("Bitbucket provider enabled")
+		if len(cfg.BitbucketWebhookSecrets) == 0 {
+			logrus.Warn("BITBUCKET_WEBHOOK_SECRET not set - Bitbucket webhook signature verification disabled")
+		}
+	}
+
+	scopedSecrets, err := parseScopedSecrets(os.Getenv("WEBHOOK_SECRETS"))
+	if err != nil {
+		logrus.WithError(err).Error("WEBHOOK_SECRETS environment variable is invalid")
+		return nil, err
+	}
+	cfg.WebhookScopedSecrets = scopedSecrets
+
+	if len(cfg.WebhookSecrets) == 0 && len(cfg.WebhookScopedSecrets) == 0 {
+		logrus.Warn("WEBHOOK_SECRET not set - webhook token verification disabled")
 	} else {
-		logrus.Info("Webhook signature verification enabled")
+		logrus.WithFields(logrus.Fields{
+			"global_secrets": len(cfg.WebhookSecrets),
+			"scopes":         len(cfg.WebhookScopedSecrets),
+		}).Info("Webhook token verification enabled")
+	}
+	if len(cfg.WebhookSigningTokens) > 0 {
+		logrus.WithField("signing_tokens", len(cfg.WebhookSigningTokens)).Info("Webhook signature verification enabled")
 	}
 
+	logrus.WithFields(logrus.Fields{
+		"incremental_review": cfg.IncrementalReview,
+		"draft_reviews":      cfg.DraftReviews,
+		"commit_status":      cfg.ReviewCommitStatus,
+		"max_prompt_tokens":  cfg.ReviewMaxPromptTokens,
+		"batch_concurrency":  cfg.ReviewBatchConcurrency,
+	}).Info("Review mode configured")
+	if cfg.ConfigDefaultsDir != "" || cfg.ConfigGroupRepo != "" {
+		logrus.WithFields(logrus.Fields{
+			"defaults_dir": cfg.ConfigDefaultsDir,
+			"group_repo":   cfg.ConfigGroupRepo,
+			"cache_ttl":    cfg.ConfigCacheTTL,
+		}).Info("WhyTho config inheritance enabled")
+	}
+	if cfg.DryRun {
+		logrus.WithField("dir", cfg.DryRunDir).Warn("DRY_RUN enabled - reviews are written to local artifacts instead of being posted")
+	}
+	if cfg.WebhookPayloadDir != "" {
+		logrus.WithField("dir", cfg.WebhookPayloadDir).Info("Storing received webhook payloads")
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"workers":      cfg.QueueWorkers,
+		"max_attempts": cfg.QueueMaxAttempts,
+		"persistent":   cfg.QueueStorePath != "",
+	}).Info("Job queue configured")
+
 	logrus.Debug("Configuration loaded successfully")
 	return cfg, nil
 }
+
+// LoadLocal loads the configuration of the local review command, which needs
+// an LLM provider but no code host or webhook settings.
+func LoadLocal() (*Config, error) {
+	cfg := &Config{
+		LLMProvider:            strings.ToLower(os.Getenv("LLM_PROVIDER")),
+		LLMModel:               os.Getenv("LLM_MODEL"),
+		LLMAPIKey:              os.Getenv("LLM_API_KEY"),
+		LLMBaseURL:             os.Getenv("LLM_BASE_URL"),
+		ReviewMaxPromptTokens:  getEnvInt("REVIEW_MAX_PROMPT_TOKENS", 100000),
+		ReviewBatchConcurrency: getEnvInt("REVIEW_BATCH_CONCURRENCY", 3),
+		ConfigDefaultsDir:      os.Getenv("CONFIG_DEFAULTS_DIR"),
+	}
+	if cfg.LLMProvider == "" {
+		cfg.LLMProvider = "gemini"
+	}
+	if err := cfg.resolveLLMAPIKey(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// parseScopedSecrets parses "group/project=secret1,secret2;group=secret3".
+func parseScopedSecrets(value string) (map[string][]string, error) {
+	scoped := make(map[string][]string)
+	for _, entry := range strings.Split(value, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		scope, secrets, ok := strings.Cut(entry, "=")
+		scope = strings.Trim(strings.TrimSpace(scope), "/")
+		if !ok || scope == "" {
+			return nil, fmt.Errorf("invalid scoped secret entry %q, expected <project-or-group-path>=<secret>[,<secret>]", entry)
+		}
+
+		list := splitList(secrets)
+		if len(list) == 0 {
+			return nil, fmt.Errorf("no secrets given for scope %q", scope)
+		}
+		scoped[scope] = append(scoped[scope], list...)
+	}
+	return scoped, nil
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func getEnvBool(key string, defaultValue bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		logrus.WithError(err).WithField("key", key).Warn("Invalid boolean environment variable, using default")
+		return defaultValue
+	}
+	return parsed
+}
+
+// resolveLLMAPIKey falls back to the provider specific API key variable when
+// LLM_API_KEY is not set and checks that hosted providers have a key.
+func (c *Config) resolveLLMAPIKey() error {
+	var keyVar string
+	switch c.LLMProvider {
+	case "gemini":
+		keyVar = "GEMINI_API_KEY"
+	case "openai":
+		keyVar = "OPENAI_API_KEY"
+	case "anthropic":
+		keyVar = "ANTHROPIC_API_KEY"
+	case "ollama":
+		logrus.WithField("provider", c.LLMProvider).Info("Using local LLM provider, no API key required")
+		return nil
+	default:
+		return fmt.Errorf("unsupported LLM_PROVIDER %q (expected gemini, openai, anthropic or ollama)", c.LLMProvider)
+	}
+
+	if c.LLMAPIKey == "" {
+		c.LLMAPIKey = os.Getenv(keyVar)
+	}
+
+	// OpenAI-compatible servers running on-prem often do not require a key
+	if c.LLMAPIKey == "" && !(c.LLMProvider == "openai" && c.LLMBaseURL != "") {
+		return fmt.Errorf("%s (or LLM_API_KEY) environment variable is required for the %s provider", keyVar, c.LLMProvider)
+	}
+
+	logrus.WithField("provider", c.LLMProvider).Info("Using LLM provider")
+	return nil
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		logrus.WithError(err).WithField("key", key).Warn("Invalid integer environment variable, using default")
+		return defaultValue
+	}
+	return parsed
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		logrus.WithError(err).WithField("key", key).Warn("Invalid duration environment variable, using default")
+		return defaultValue
+	}
+	return parsed
+}
@@ -0,0 +1,312 @@
+// Package diff parses the unified diffs GitLab returns for merge request and
+// compare changes. Both the review prompt's DIFF_LINE numbering and the
+// positioning of comments are derived from the same parse, so they cannot
+// drift apart.
+package diff
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/vinamra28/whytho/internal/models"
+)
+
+const (
+	LineAdded   = "+"
+	LineRemoved = "-"
+	LineContext = " "
+)
+
+// noNewlineMarker follows a line that has no trailing newline in its file.
+const noNewlineMarker = `\ No newline at end of file`
+
+// hunkHeaderPattern matches "@@ -old[,count] +new[,count] @@ optional section".
+var hunkHeaderPattern = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@ ?(.*)$`)
+
+// Hunk is one "@@" section of a diff.
+type Hunk struct {
+	OldStart int
+	OldLines int
+	NewStart int
+	NewLines int
+	// Section is the function context git prints after the header, if any
+	Section string
+	Lines   []models.DiffLine
+}
+
+// File is a parsed file diff. Positions are the 1-based DIFF_LINE numbers of
+// the added, removed and context lines, counted across all hunks.
+type File struct {
+	Hunks []Hunk
+	// Binary is set for binary files, which have no hunks
+	Binary bool
+
+	byPosition map[int]models.DiffLine
+	byOldLine  map[int]int
+	byNewLine  map[int]int
+	// lineCodePairs holds GitLab's old/new position of every line, where the
+	// missing side of added and removed lines is the next line of that side
+	lineCodePairs map[int][2]int
+}
+
+// Parse parses a GitLab file diff. Lines outside hunks, such as "diff --git"
+// or "Binary files ... differ" headers, are ignored.
+func Parse(raw string) *File {
+	f := &File{
+		byPosition:    make(map[int]models.DiffLine),
+		byOldLine:     make(map[int]int),
+		byNewLine:     make(map[int]int),
+		lineCodePairs: make(map[int][2]int),
+	}
+
+	var hunk *Hunk
+	oldLine, newLine := 0, 0
+	oldLeft, newLeft := 0, 0
+	position := 0
+
+	for _, line := range strings.Split(raw, "\n") {
+		if match := hunkHeaderPattern.FindStringSubmatch(line); match != nil {
+			f.Hunks = append(f.Hunks, Hunk{
+				OldStart: atoi(match[1], 0),
+				OldLines: atoi(match[2], 1),
+				NewStart: atoi(match[3], 0),
+				NewLines: atoi(match[4], 1),
+				Section:  match[5],
+			})
+			hunk = &f.Hunks[len(f.Hunks)-1]
+			oldLine, newLine = hunk.OldStart-1, hunk.NewStart-1
+			oldLeft, newLeft = hunk.OldLines, hunk.NewLines
+			continue
+		}
+
+		if hunk == nil {
+			if strings.HasPrefix(line, "Binary files ") || strings.HasPrefix(line, "GIT binary patch") {
+				f.Binary = true
+			}
+			continue
+		}
+
+		if line == noNewlineMarker {
+			if n := len(hunk.Lines); n > 0 {
+				hunk.Lines[n-1].NoNewline = true
+				f.byPosition[hunk.Lines[n-1].Position] = hunk.Lines[n-1]
+			}
+			continue
+		}
+
+		var diffLine models.DiffLine
+		switch {
+		case strings.HasPrefix(line, LineAdded) && newLeft > 0:
+			newLine++
+			newLeft--
+			diffLine = models.DiffLine{Type: LineAdded, Content: line[1:], NewLineNum: newLine}
+		case strings.HasPrefix(line, LineRemoved) && oldLeft > 0:
+			oldLine++
+			oldLeft--
+			diffLine = models.DiffLine{Type: LineRemoved, Content: line[1:], OldLineNum: oldLine}
+		case (strings.HasPrefix(line, LineContext) || line == "") && oldLeft > 0 && newLeft > 0:
+			// Some tools strip the leading space from empty context lines
+			oldLine++
+			newLine++
+			oldLeft--
+			newLeft--
+			diffLine = models.DiffLine{Type: LineContext, Content: strings.TrimPrefix(line, LineContext), OldLineNum: oldLine, NewLineNum: newLine}
+		default:
+			// Past the end of the hunk, e.g. the trailing newline of the diff
+			continue
+		}
+
+		position++
+		diffLine.Position = position
+		hunk.Lines = append(hunk.Lines, diffLine)
+		f.byPosition[position] = diffLine
+		oldPos, newPos := oldLine, newLine
+		switch diffLine.Type {
+		case LineAdded:
+			oldPos++
+		case LineRemoved:
+			newPos++
+		}
+		f.lineCodePairs[position] = [2]int{oldPos, newPos}
+		if diffLine.OldLineNum != 0 {
+			f.byOldLine[diffLine.OldLineNum] = position
+		}
+		if diffLine.NewLineNum != 0 {
+			f.byNewLine[diffLine.NewLineNum] = position
+		}
+	}
+
+	return f
+}
+
+// Line returns the line at a DIFF_LINE position.
+func (f *File) Line(position int) (models.DiffLine, bool) {
+	line, ok := f.byPosition[position]
+	return line, ok
+}
+
+// LineAtNew returns the added or context line with the given new file line number.
+func (f *File) LineAtNew(newLine int) (models.DiffLine, bool) {
+	position, ok := f.byNewLine[newLine]
+	if !ok {
+		return models.DiffLine{}, false
+	}
+	return f.byPosition[position], true
+}
+
+// LineAtOld returns the removed or context line with the given old file line number.
+func (f *File) LineAtOld(oldLine int) (models.DiffLine, bool) {
+	position, ok := f.byOldLine[oldLine]
+	if !ok {
+		return models.DiffLine{}, false
+	}
+	return f.byPosition[position], true
+}
+
+// Lines returns every line keyed by its DIFF_LINE position.
+func (f *File) Lines() map[int]models.DiffLine {
+	lines := make(map[int]models.DiffLine, len(f.byPosition))
+	for position, line := range f.byPosition {
+		lines[position] = line
+	}
+	return lines
+}
+
+// HunkAt returns the hunk containing the DIFF_LINE position.
+func (f *File) HunkAt(position int) (*Hunk, bool) {
+	for i := range f.Hunks {
+		lines := f.Hunks[i].Lines
+		if len(lines) > 0 && lines[0].Position <= position && position <= lines[len(lines)-1].Position {
+			return &f.Hunks[i], true
+		}
+	}
+	return nil, false
+}
+
+// LineCode returns GitLab's line_code for the line at a DIFF_LINE position,
+// "<sha1 of the file path>_<old line>_<new line>".
+func (f *File) LineCode(filePath string, position int) (string, bool) {
+	pair, ok := f.lineCodePairs[position]
+	if !ok {
+		return "", false
+	}
+	sum := sha1.Sum([]byte(filePath))
+	return fmt.Sprintf("%s_%d_%d", hex.EncodeToString(sum[:]), pair[0], pair[1]), true
+}
+
+// Header renders the hunk's "@@" header line.
+func (h *Hunk) Header() string {
+	header := fmt.Sprintf("@@ -%d,%d +%d,%d @@", h.OldStart, h.OldLines, h.NewStart, h.NewLines)
+	if h.Section != "" {
+		header += " " + h.Section
+	}
+	return header
+}
+
+// String renders the hunk back into unified diff format.
+func (h *Hunk) String() string {
+	var b strings.Builder
+	b.WriteString(h.Header())
+	for _, line := range h.Lines {
+		b.WriteString("\n" + line.Type + line.Content)
+		if line.NoNewline {
+			b.WriteString("\n" + noNewlineMarker)
+		}
+	}
+	return b.String()
+}
+
+func atoi(value string, defaultValue int) int {
+	if value == "" {
+		return defaultValue
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return defaultValue
+	}
+	return n
+}
+
+// SplitFiles splits a multi-file git diff, such as a whole pull request's
+// ".diff", into per-file changes whose Diff holds only the hunks like GitLab's.
+func SplitFiles(raw string) []models.MRChange {
+	var changes []models.MRChange
+	var current *models.MRChange
+	var body []string
+	inHunks := false
+
+	flush := func() {
+		if current != nil {
+			current.Diff = strings.Join(body, "\n")
+			changes = append(changes, *current)
+		}
+	}
+
+	for _, line := range strings.Split(raw, "\n") {
+		if strings.HasPrefix(line, "diff --git ") {
+			flush()
+			current = &models.MRChange{}
+			body = nil
+			inHunks = false
+			// "diff --git a/<old> b/<new>", refined by the headers below
+			if paths := strings.TrimPrefix(line, "diff --git "); strings.HasPrefix(paths, "a/") {
+				if i := strings.Index(paths, " b/"); i >= 0 {
+					current.OldPath = paths[2:i]
+					current.NewPath = paths[i+3:]
+				}
+			}
+			continue
+		}
+		if current == nil {
+			continue
+		}
+
+		if !inHunks {
+			switch {
+			case strings.HasPrefix(line, "@@"):
+				inHunks = true
+			case strings.HasPrefix(line, "new file mode"):
+				current.NewFile = true
+				continue
+			case strings.HasPrefix(line, "deleted file mode"):
+				current.DeletedFile = true
+				continue
+			case strings.HasPrefix(line, "rename from "):
+				current.RenamedFile = true
+				current.OldPath = strings.TrimPrefix(line, "rename from ")
+				continue
+			case strings.HasPrefix(line, "rename to "):
+				current.RenamedFile = true
+				current.NewPath = strings.TrimPrefix(line, "rename to ")
+				continue
+			case strings.HasPrefix(line, "--- a/"):
+				current.OldPath = strings.TrimPrefix(line, "--- a/")
+				continue
+			case strings.HasPrefix(line, "+++ b/"):
+				current.NewPath = strings.TrimPrefix(line, "+++ b/")
+				continue
+			case strings.HasPrefix(line, "Binary files ") || strings.HasPrefix(line, "GIT binary patch"):
+				// Kept so Parse marks the file as binary
+			default:
+				continue
+			}
+		}
+		body = append(body, line)
+	}
+	flush()
+
+	// New and deleted files only name themselves on one side of the headers
+	for i := range changes {
+		if changes[i].OldPath == "" {
+			changes[i].OldPath = changes[i].NewPath
+		}
+		if changes[i].NewPath == "" {
+			changes[i].NewPath = changes[i].OldPath
+		}
+	}
+	return changes
+}
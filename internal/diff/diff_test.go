@@ -0,0 +1,298 @@
+package diff
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/vinamra28/whytho/internal/models"
+)
+
+func TestParse(t *testing.T) {
+	raw := "@@ -1,4 +1,4 @@\n" +
+		" package main\n" +
+		"-import \"os\"\n" +
+		"+import \"fmt\"\n" +
+		" \n" +
+		" func main() {}\n"
+
+	f := Parse(raw)
+	if len(f.Hunks) != 1 {
+		t.Fatalf("got %d hunks, want 1", len(f.Hunks))
+	}
+
+	want := []models.DiffLine{
+		{Position: 1, Type: LineContext, Content: "package main", OldLineNum: 1, NewLineNum: 1},
+		{Position: 2, Type: LineRemoved, Content: `import "os"`, OldLineNum: 2},
+		{Position: 3, Type: LineAdded, Content: `import "fmt"`, NewLineNum: 2},
+		{Position: 4, Type: LineContext, Content: "", OldLineNum: 3, NewLineNum: 3},
+		{Position: 5, Type: LineContext, Content: "func main() {}", OldLineNum: 4, NewLineNum: 4},
+	}
+	if !reflect.DeepEqual(f.Hunks[0].Lines, want) {
+		t.Errorf("lines = %+v\nwant %+v", f.Hunks[0].Lines, want)
+	}
+	for _, line := range want {
+		if got, ok := f.Line(line.Position); !ok || got != line {
+			t.Errorf("Line(%d) = %+v, %v, want %+v", line.Position, got, ok, line)
+		}
+	}
+	if _, ok := f.Line(6); ok {
+		t.Error("Line(6) found a line past the end of the diff")
+	}
+	if len(f.Lines()) != len(want) {
+		t.Errorf("Lines() has %d lines, want %d", len(f.Lines()), len(want))
+	}
+
+	if line, ok := f.LineAtNew(2); !ok || line.Position != 3 {
+		t.Errorf("LineAtNew(2) = %+v, %v, want the added line", line, ok)
+	}
+	if line, ok := f.LineAtOld(2); !ok || line.Position != 2 {
+		t.Errorf("LineAtOld(2) = %+v, %v, want the removed line", line, ok)
+	}
+	if _, ok := f.LineAtNew(9); ok {
+		t.Error("LineAtNew(9) found a line outside the diff")
+	}
+}
+
+func TestParseMultipleHunks(t *testing.T) {
+	raw := "@@ -1,2 +1,3 @@\n" +
+		" a\n" +
+		"+b\n" +
+		" c\n" +
+		"@@ -20,2 +21,2 @@\n" +
+		"-x\n" +
+		"+y\n" +
+		" z"
+
+	f := Parse(raw)
+	if len(f.Hunks) != 2 {
+		t.Fatalf("got %d hunks, want 2", len(f.Hunks))
+	}
+	// Positions continue across hunks
+	if line, ok := f.Line(4); !ok || line.Type != LineRemoved || line.OldLineNum != 20 {
+		t.Errorf("Line(4) = %+v, %v, want old line 20 removed", line, ok)
+	}
+	if line, ok := f.Line(6); !ok || line.OldLineNum != 21 || line.NewLineNum != 22 {
+		t.Errorf("Line(6) = %+v, %v, want context line 21/22", line, ok)
+	}
+	if hunk, ok := f.HunkAt(5); !ok || hunk.OldStart != 20 {
+		t.Errorf("HunkAt(5) = %+v, %v, want the second hunk", hunk, ok)
+	}
+	if _, ok := f.HunkAt(7); ok {
+		t.Error("HunkAt(7) found a hunk past the end of the diff")
+	}
+}
+
+func TestParseNoNewline(t *testing.T) {
+	raw := "@@ -1,2 +1,2 @@\n" +
+		" first\n" +
+		"-last\n" +
+		`\ No newline at end of file` + "\n" +
+		"+last\n" +
+		"+" // an added empty line would be "+", the marker must not be mistaken for one
+
+	f := Parse(raw)
+	lines := f.Hunks[0].Lines
+	if len(lines) != 3 {
+		t.Fatalf("got %d lines, want 3: %+v", len(lines), lines)
+	}
+	if !lines[1].NoNewline {
+		t.Error("removed line without trailing newline is not marked")
+	}
+	if line, _ := f.Line(2); !line.NoNewline {
+		t.Error("Line(2) does not carry the no newline marker")
+	}
+	if lines[0].NoNewline || lines[2].NoNewline {
+		t.Error("lines with a trailing newline are marked")
+	}
+
+	// Only two new lines are announced, so the trailing "+" is past the hunk
+	want := "@@ -1,2 +1,2 @@\n first\n-last\n" + `\ No newline at end of file` + "\n+last"
+	if got := f.Hunks[0].String(); got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
+
+func TestParseNoNewlineOnBothSides(t *testing.T) {
+	raw := "@@ -1 +1 @@\n" +
+		"-old\n" +
+		`\ No newline at end of file` + "\n" +
+		"+new\n" +
+		`\ No newline at end of file`
+
+	lines := Parse(raw).Hunks[0].Lines
+	if len(lines) != 2 || !lines[0].NoNewline || !lines[1].NoNewline {
+		t.Errorf("lines = %+v, want both marked without newline", lines)
+	}
+}
+
+func TestParseBinary(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+	}{
+		{"binary files differ", "Binary files a/logo.png and b/logo.png differ"},
+		{"git binary patch", "GIT binary patch\nliteral 12\nzcmZ?wbhEHbJ"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := Parse(tt.raw)
+			if !f.Binary {
+				t.Error("file is not marked binary")
+			}
+			if len(f.Hunks) != 0 || len(f.Lines()) != 0 {
+				t.Errorf("binary file has hunks %+v", f.Hunks)
+			}
+		})
+	}
+
+	if Parse("@@ -1 +1 @@\n-Binary files are fun\n+Binary files are great").Binary {
+		t.Error("text file mentioning binary files is marked binary")
+	}
+}
+
+func TestHunkHeader(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  string
+		want    Hunk
+		rebuilt string
+	}{
+		{
+			name:    "function context",
+			header:  "@@ -10,3 +12,4 @@ func (s *Server) Start() error {",
+			want:    Hunk{OldStart: 10, OldLines: 3, NewStart: 12, NewLines: 4, Section: "func (s *Server) Start() error {"},
+			rebuilt: "@@ -10,3 +12,4 @@ func (s *Server) Start() error {",
+		},
+		{
+			name:    "no function context",
+			header:  "@@ -1,2 +1,2 @@",
+			want:    Hunk{OldStart: 1, OldLines: 2, NewStart: 1, NewLines: 2},
+			rebuilt: "@@ -1,2 +1,2 @@",
+		},
+		{
+			name:    "omitted counts default to one",
+			header:  "@@ -5 +5 @@ class Foo:",
+			want:    Hunk{OldStart: 5, OldLines: 1, NewStart: 5, NewLines: 1, Section: "class Foo:"},
+			rebuilt: "@@ -5,1 +5,1 @@ class Foo:",
+		},
+		{
+			name:    "new file",
+			header:  "@@ -0,0 +1,2 @@",
+			want:    Hunk{OldStart: 0, OldLines: 0, NewStart: 1, NewLines: 2},
+			rebuilt: "@@ -0,0 +1,2 @@",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := Parse(tt.header)
+			if len(f.Hunks) != 1 {
+				t.Fatalf("got %d hunks, want 1", len(f.Hunks))
+			}
+			hunk := f.Hunks[0]
+			if hunk.OldStart != tt.want.OldStart || hunk.OldLines != tt.want.OldLines ||
+				hunk.NewStart != tt.want.NewStart || hunk.NewLines != tt.want.NewLines || hunk.Section != tt.want.Section {
+				t.Errorf("hunk = %+v, want %+v", hunk, tt.want)
+			}
+			if got := hunk.Header(); got != tt.rebuilt {
+				t.Errorf("Header() = %q, want %q", got, tt.rebuilt)
+			}
+		})
+	}
+}
+
+func TestLineCode(t *testing.T) {
+	raw := "@@ -3,3 +3,3 @@\n" +
+		" keep\n" +
+		"-before\n" +
+		"+after\n" +
+		" tail"
+	path := "internal/app/main.go"
+	sum := sha1.Sum([]byte(path))
+	prefix := hex.EncodeToString(sum[:])
+
+	f := Parse(raw)
+	tests := []struct {
+		position int
+		old, new int
+	}{
+		{1, 3, 3}, // context lines carry both line numbers
+		{2, 4, 4}, // a removed line's new side is the next new line
+		{3, 5, 4}, // an added line's old side is the next old line
+		{4, 5, 5},
+	}
+	for _, tt := range tests {
+		got, ok := f.LineCode(path, tt.position)
+		want := fmt.Sprintf("%s_%d_%d", prefix, tt.old, tt.new)
+		if !ok || got != want {
+			t.Errorf("LineCode(%d) = %q, %v, want %q", tt.position, got, ok, want)
+		}
+	}
+	if _, ok := f.LineCode(path, 9); ok {
+		t.Error("LineCode(9) returned a code for a line outside the diff")
+	}
+}
+
+func TestSplitFiles(t *testing.T) {
+	raw := "diff --git a/main.go b/main.go\n" +
+		"index 1111111..2222222 100644\n" +
+		"--- a/main.go\n" +
+		"+++ b/main.go\n" +
+		"@@ -1,2 +1,2 @@ package main\n" +
+		"-a\n" +
+		"+b\n" +
+		" c\n" +
+		"diff --git a/new.txt b/new.txt\n" +
+		"new file mode 100644\n" +
+		"index 0000000..3333333\n" +
+		"--- /dev/null\n" +
+		"+++ b/new.txt\n" +
+		"@@ -0,0 +1 @@\n" +
+		"+hello\n" +
+		"diff --git a/old.txt b/old.txt\n" +
+		"deleted file mode 100644\n" +
+		"index 4444444..0000000\n" +
+		"--- a/old.txt\n" +
+		"+++ /dev/null\n" +
+		"@@ -1 +0,0 @@\n" +
+		"-bye\n" +
+		"diff --git a/docs/a b.md b/docs/c d.md\n" +
+		"similarity index 100%\n" +
+		"rename from docs/a b.md\n" +
+		"rename to docs/c d.md\n" +
+		"diff --git a/logo.png b/logo.png\n" +
+		"index 5555555..6666666 100644\n" +
+		"Binary files a/logo.png and b/logo.png differ\n"
+
+	want := []models.MRChange{
+		{OldPath: "main.go", NewPath: "main.go", Diff: "@@ -1,2 +1,2 @@ package main\n-a\n+b\n c"},
+		{OldPath: "new.txt", NewPath: "new.txt", NewFile: true, Diff: "@@ -0,0 +1 @@\n+hello"},
+		{OldPath: "old.txt", NewPath: "old.txt", DeletedFile: true, Diff: "@@ -1 +0,0 @@\n-bye"},
+		{OldPath: "docs/a b.md", NewPath: "docs/c d.md", RenamedFile: true},
+		{OldPath: "logo.png", NewPath: "logo.png", Diff: "Binary files a/logo.png and b/logo.png differ"},
+	}
+
+	got := SplitFiles(raw)
+	if len(got) != len(want) {
+		t.Fatalf("got %d files, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("file %d = %+v\nwant %+v", i, got[i], want[i])
+		}
+	}
+	if !Parse(got[4].Diff).Binary {
+		t.Error("split binary file is not parsed as binary")
+	}
+	if f := Parse(got[0].Diff); len(f.Hunks) != 1 || f.Hunks[0].Section != "package main" {
+		t.Errorf("split file hunks = %+v", f.Hunks)
+	}
+}
+
+func TestSplitFilesWithoutGitHeaders(t *testing.T) {
+	if got := SplitFiles("@@ -1 +1 @@\n-a\n+b"); len(got) != 0 {
+		t.Errorf("SplitFiles() = %+v, want no files without diff --git headers", got)
+	}
+}
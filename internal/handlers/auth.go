@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// signatureTolerance bounds the age of signed webhooks to prevent replays
+const signatureTolerance = 5 * time.Minute
+
+// WebhookAuthenticator verifies GitLab webhooks. GitLab sends the configured
+// secret token verbatim in X-Gitlab-Token; secrets can be scoped to a project
+// or group path so several tenants and rotated secrets can coexist. When
+// signing tokens are configured the Standard Webhooks signature headers
+// (webhook-id, webhook-timestamp, webhook-signature) are validated as well.
+type WebhookAuthenticator struct {
+	secrets       []string
+	scopedSecrets map[string][]string
+	signingKeys   [][]byte
+	// projectPath resolves a project ID to its path, so scoped secrets are
+	// bound to the project a webhook acts on rather than the path it claims
+	projectPath func(projectID int) (string, error)
+	now         func() time.Time
+}
+
+func NewWebhookAuthenticator(secrets []string, scopedSecrets map[string][]string, signingTokens []string, projectPath func(projectID int) (string, error)) *WebhookAuthenticator {
+	auth := &WebhookAuthenticator{
+		secrets:       secrets,
+		scopedSecrets: scopedSecrets,
+		projectPath:   projectPath,
+		now:           time.Now,
+	}
+
+	for _, token := range signingTokens {
+		key, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(token, "whsec_"))
+		if err != nil {
+			logrus.WithError(err).Error("Ignoring webhook signing token that is not valid base64")
+			continue
+		}
+		auth.signingKeys = append(auth.signingKeys, key)
+	}
+
+	return auth
+}
+
+func (a *WebhookAuthenticator) Enabled() bool {
+	return len(a.secrets) > 0 || len(a.scopedSecrets) > 0 || len(a.signingKeys) > 0
+}
+
+// Authenticate checks the secret token and, if configured, the signature of a webhook request.
+func (a *WebhookAuthenticator) Authenticate(header http.Header, body []byte) error {
+	if len(a.secrets) > 0 || len(a.scopedSecrets) > 0 {
+		project := webhookProject(body)
+		token := header.Get("X-Gitlab-Token")
+		if !a.verifyToken(project.PathWithNamespace, token) {
+			return fmt.Errorf("invalid webhook token for project %q", project.PathWithNamespace)
+		}
+		if len(a.scopedSecrets) > 0 {
+			if err := a.verifyProjectScope(project.ID, token); err != nil {
+				return err
+			}
+		}
+	}
+
+	if len(a.signingKeys) > 0 {
+		if err := a.verifySignature(header, body); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// verifyToken compares the token against the secrets of the most specific
+// scope matching the project path, falling back to the global secrets.
+func (a *WebhookAuthenticator) verifyToken(projectPath, token string) bool {
+	if token == "" {
+		return false
+	}
+
+	candidates := a.secrets
+	if scoped, ok := a.secretsForPath(projectPath); ok {
+		candidates = scoped
+	}
+
+	valid := false
+	for _, secret := range candidates {
+		// Compare against every candidate so timing does not reveal which one matched
+		if subtle.ConstantTimeCompare([]byte(token), []byte(secret)) == 1 {
+			valid = true
+		}
+	}
+	return valid
+}
+
+// verifyProjectScope checks the token against the secrets of the project the
+// webhook acts on, looked up by its ID. The path in the payload is not
+// authenticated, so a secret of one scope could otherwise be used to act on
+// the projects of another by claiming a path in its own scope.
+func (a *WebhookAuthenticator) verifyProjectScope(projectID int, token string) error {
+	if projectID == 0 {
+		return fmt.Errorf("webhook payload has no project ID")
+	}
+	if a.projectPath == nil {
+		return fmt.Errorf("cannot resolve the path of project %d", projectID)
+	}
+	path, err := a.projectPath(projectID)
+	if err != nil {
+		return fmt.Errorf("failed to resolve the path of project %d: %w", projectID, err)
+	}
+	if !a.verifyToken(path, token) {
+		return fmt.Errorf("invalid webhook token for project %d (%s)", projectID, path)
+	}
+	return nil
+}
+
+func (a *WebhookAuthenticator) secretsForPath(projectPath string) ([]string, bool) {
+	path := strings.Trim(projectPath, "/")
+	for path != "" {
+		if secrets, ok := a.scopedSecrets[path]; ok {
+			return secrets, true
+		}
+		slash := strings.LastIndex(path, "/")
+		if slash < 0 {
+			break
+		}
+		path = path[:slash]
+	}
+	return nil, false
+}
+
+func (a *WebhookAuthenticator) verifySignature(header http.Header, body []byte) error {
+	id := header.Get("webhook-id")
+	timestamp := header.Get("webhook-timestamp")
+	signatures := header.Get("webhook-signature")
+	if id == "" || timestamp == "" || signatures == "" {
+		return fmt.Errorf("missing webhook signature headers")
+	}
+
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid webhook timestamp %q", timestamp)
+	}
+	age := a.now().Sub(time.Unix(seconds, 0))
+	if age > signatureTolerance || age < -signatureTolerance {
+		return fmt.Errorf("webhook timestamp outside of tolerance")
+	}
+
+	signedContent := []byte(id + "." + timestamp + "." + string(body))
+	for _, key := range a.signingKeys {
+		mac := hmac.New(sha256.New, key)
+		mac.Write(signedContent)
+		expected := mac.Sum(nil)
+
+		// The header holds space separated "v1,<base64>" entries
+		for _, signature := range strings.Fields(signatures) {
+			version, value, ok := strings.Cut(signature, ",")
+			if !ok || version != "v1" {
+				continue
+			}
+			decoded, err := base64.StdEncoding.DecodeString(value)
+			if err != nil {
+				continue
+			}
+			if hmac.Equal(decoded, expected) {
+				return nil
+			}
+		}
+	}
+
+	return fmt.Errorf("invalid webhook signature")
+}
+
+// webhookProjectRef is the project a GitLab payload refers to.
+type webhookProjectRef struct {
+	ID                int    `json:"id"`
+	PathWithNamespace string `json:"path_with_namespace"`
+}
+
+// webhookProject extracts the project of a GitLab payload.
+func webhookProject(body []byte) webhookProjectRef {
+	var payload struct {
+		Project webhookProjectRef `json:"project"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return webhookProjectRef{}
+	}
+	return payload.Project
+}
+
+// verifyHMACSignature checks a hex encoded HMAC-SHA256 signature of the body,
+// as sent by GitHub, Gitea and Bitbucket, against each of the secrets.
+func verifyHMACSignature(secrets []string, signature string, body []byte) bool {
+	decoded, err := hex.DecodeString(signature)
+	if err != nil || len(decoded) == 0 {
+		return false
+	}
+
+	valid := false
+	for _, secret := range secrets {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(body)
+		if hmac.Equal(decoded, mac.Sum(nil)) {
+			valid = true
+		}
+	}
+	return valid
+}
@@ -0,0 +1,231 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func mergeRequestBody(projectID int, path string) []byte {
+	return []byte(fmt.Sprintf(`{"object_kind":"merge_request","project":{"id":%d,"path_with_namespace":%q}}`, projectID, path))
+}
+
+// projectPaths resolves the project IDs of the tests like the GitLab API would.
+func projectPaths(projectID int) (string, error) {
+	paths := map[int]string{
+		1: "acme/payments/api",
+		2: "acme/web/site",
+		3: "other/tool",
+	}
+	path, ok := paths[projectID]
+	if !ok {
+		return "", fmt.Errorf("project %d not found", projectID)
+	}
+	return path, nil
+}
+
+func TestWebhookAuthenticatorToken(t *testing.T) {
+	scoped := map[string][]string{
+		"acme/payments": {"payments-old", "payments-new"},
+		"acme/web":      {"web-secret"},
+	}
+
+	tests := []struct {
+		name          string
+		secrets       []string
+		scopedSecrets map[string][]string
+		token         string
+		body          []byte
+		wantErr       bool
+	}{
+		{
+			name:    "verbatim global token",
+			secrets: []string{"global"},
+			token:   "global",
+			body:    mergeRequestBody(3, "other/tool"),
+		},
+		{
+			name:    "wrong global token",
+			secrets: []string{"global"},
+			token:   "guess",
+			body:    mergeRequestBody(3, "other/tool"),
+			wantErr: true,
+		},
+		{
+			name:    "missing token",
+			secrets: []string{"global"},
+			body:    mergeRequestBody(3, "other/tool"),
+			wantErr: true,
+		},
+		{
+			name:    "rotation overlap accepts the old secret",
+			secrets: []string{"old", "new"},
+			token:   "old",
+			body:    mergeRequestBody(3, "other/tool"),
+		},
+		{
+			name:    "rotation overlap accepts the new secret",
+			secrets: []string{"old", "new"},
+			token:   "new",
+			body:    mergeRequestBody(3, "other/tool"),
+		},
+		{
+			name:          "scoped secret",
+			secrets:       []string{"global"},
+			scopedSecrets: scoped,
+			token:         "payments-new",
+			body:          mergeRequestBody(1, "acme/payments/api"),
+		},
+		{
+			name:          "global secret is not accepted for a scoped project",
+			secrets:       []string{"global"},
+			scopedSecrets: scoped,
+			token:         "global",
+			body:          mergeRequestBody(1, "acme/payments/api"),
+			wantErr:       true,
+		},
+		{
+			name:          "unscoped project falls back to the global secret",
+			secrets:       []string{"global"},
+			scopedSecrets: scoped,
+			token:         "global",
+			body:          mergeRequestBody(3, "other/tool"),
+		},
+		{
+			name:          "secret of another scope",
+			secrets:       []string{"global"},
+			scopedSecrets: scoped,
+			token:         "web-secret",
+			body:          mergeRequestBody(1, "acme/payments/api"),
+			wantErr:       true,
+		},
+		{
+			name:          "cross-scope path claimed with the ID of another scope's project",
+			secrets:       []string{"global"},
+			scopedSecrets: scoped,
+			token:         "web-secret",
+			body:          mergeRequestBody(1, "acme/web/site"),
+			wantErr:       true,
+		},
+		{
+			name:          "unscoped path claimed with the ID of a scoped project",
+			secrets:       []string{"global"},
+			scopedSecrets: scoped,
+			token:         "global",
+			body:          mergeRequestBody(1, "other/tool"),
+			wantErr:       true,
+		},
+		{
+			name:          "unknown project ID",
+			secrets:       []string{"global"},
+			scopedSecrets: scoped,
+			token:         "web-secret",
+			body:          mergeRequestBody(99, "acme/web/site"),
+			wantErr:       true,
+		},
+		{
+			name:          "payload without project",
+			scopedSecrets: scoped,
+			token:         "web-secret",
+			body:          []byte(`{"object_kind":"merge_request"}`),
+			wantErr:       true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auth := NewWebhookAuthenticator(tt.secrets, tt.scopedSecrets, nil, projectPaths)
+			header := http.Header{}
+			if tt.token != "" {
+				header.Set("X-Gitlab-Token", tt.token)
+			}
+			err := auth.Authenticate(header, tt.body)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Authenticate() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWebhookAuthenticatorSignature(t *testing.T) {
+	key := []byte("signing-key-for-tests")
+	token := "whsec_" + base64.StdEncoding.EncodeToString(key)
+	now := time.Unix(1700000000, 0)
+	body := mergeRequestBody(3, "other/tool")
+
+	sign := func(id string, timestamp int64, body []byte) string {
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(id + "." + strconv.FormatInt(timestamp, 10) + "." + string(body)))
+		return "v1," + base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	}
+
+	tests := []struct {
+		name      string
+		timestamp int64
+		signature string
+		body      []byte
+		wantErr   bool
+	}{
+		{
+			name:      "valid signature",
+			timestamp: now.Unix(),
+			signature: sign("msg_1", now.Unix(), body),
+			body:      body,
+		},
+		{
+			name:      "valid signature among several",
+			timestamp: now.Unix(),
+			signature: "v1,bm90IGl0 " + sign("msg_1", now.Unix(), body),
+			body:      body,
+		},
+		{
+			name:      "tampered body",
+			timestamp: now.Unix(),
+			signature: sign("msg_1", now.Unix(), body),
+			body:      mergeRequestBody(1, "acme/payments/api"),
+			wantErr:   true,
+		},
+		{
+			name:      "stale timestamp",
+			timestamp: now.Add(-10 * time.Minute).Unix(),
+			signature: sign("msg_1", now.Add(-10*time.Minute).Unix(), body),
+			body:      body,
+			wantErr:   true,
+		},
+		{
+			name:      "timestamp in the future",
+			timestamp: now.Add(10 * time.Minute).Unix(),
+			signature: sign("msg_1", now.Add(10*time.Minute).Unix(), body),
+			body:      body,
+			wantErr:   true,
+		},
+		{
+			name:      "missing signature",
+			timestamp: now.Unix(),
+			body:      body,
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auth := NewWebhookAuthenticator(nil, nil, []string{token}, projectPaths)
+			auth.now = func() time.Time { return now }
+			header := http.Header{}
+			header.Set("webhook-id", "msg_1")
+			header.Set("webhook-timestamp", strconv.FormatInt(tt.timestamp, 10))
+			if tt.signature != "" {
+				header.Set("webhook-signature", tt.signature)
+			}
+			err := auth.Authenticate(header, tt.body)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Authenticate() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
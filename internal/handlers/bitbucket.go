@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// BitbucketHandler receives Bitbucket Server and Data Center pull request
+// webhooks and queues reviews with the PullRequestReviewer.
+type BitbucketHandler struct {
+	reviewer *PullRequestReviewer
+	// secrets verify the X-Hub-Signature header when set
+	secrets []string
+}
+
+func NewBitbucketHandler(reviewer *PullRequestReviewer, secrets []string) *BitbucketHandler {
+	logrus.Info("Creating Bitbucket webhook handler")
+	return &BitbucketHandler{reviewer: reviewer, secrets: secrets}
+}
+
+type bitbucketPullRequestEvent struct {
+	EventKey    string `json:"eventKey"`
+	PullRequest struct {
+		ID    int    `json:"id"`
+		State string `json:"state"`
+		ToRef struct {
+			Repository struct {
+				Slug    string `json:"slug"`
+				Project struct {
+					Key string `json:"key"`
+				} `json:"project"`
+			} `json:"repository"`
+		} `json:"toRef"`
+	} `json:"pullRequest"`
+	// Comment is only set for pr:comment:added
+	Comment struct {
+		Text string `json:"text"`
+	} `json:"comment"`
+}
+
+// repo returns the "PROJECT/slug" name of the pull request's repository.
+func (e *bitbucketPullRequestEvent) repo() string {
+	repository := e.PullRequest.ToRef.Repository
+	return repository.Project.Key + "/" + repository.Slug
+}
+
+func (h *BitbucketHandler) HandleWebhook(c *gin.Context) {
+	logrus.Info("Received Bitbucket webhook request")
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to read request body")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+		return
+	}
+
+	if len(h.secrets) > 0 {
+		signature := strings.TrimPrefix(c.GetHeader("X-Hub-Signature"), "sha256=")
+		if !verifyHMACSignature(h.secrets, signature, body) {
+			logrus.Warn("Invalid Bitbucket webhook signature received")
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid signature"})
+			return
+		}
+	}
+
+	eventKey := c.GetHeader("X-Event-Key")
+	logrus.WithField("event_type", eventKey).Debug("Received Bitbucket event")
+	switch eventKey {
+	case "diagnostics:ping":
+		c.JSON(http.StatusOK, gin.H{"message": "pong"})
+		return
+	case "pr:opened", "pr:from_ref_updated", "pr:comment:added":
+	default:
+		logrus.WithField("event_type", eventKey).Info("Ignoring unsupported event")
+		c.JSON(http.StatusOK, gin.H{"message": "Event ignored"})
+		return
+	}
+
+	var event bitbucketPullRequestEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		logrus.WithError(err).Error("Failed to parse webhook payload")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to parse webhook"})
+		return
+	}
+	if event.PullRequest.State != "OPEN" {
+		c.JSON(http.StatusOK, gin.H{"message": "PR not open, review skipped"})
+		return
+	}
+
+	if eventKey == "pr:comment:added" {
+		h.reviewer.queueCommandReview(c, "bitbucket", event.repo(), event.PullRequest.ID, event.Comment.Text)
+		return
+	}
+	h.reviewer.queueReview(c, pullRequestJob{
+		Provider: "bitbucket",
+		Repo:     event.repo(),
+		Number:   event.PullRequest.ID,
+	})
+}
@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/vinamra28/whytho/internal/models"
+	"github.com/vinamra28/whytho/internal/queue"
+	"github.com/vinamra28/whytho/internal/services"
+)
+
+// botMention is the handle developers use to address the bot in MR comments.
+const botMention = "@whytho"
+
+const (
+	commandReview    = "review"
+	commandIgnore    = "ignore"
+	commandSummarize = "summarize"
+	commandExplain   = "explain"
+)
+
+// chatCommand is a bot command parsed from a merge request comment, e.g.
+// "@whytho review internal/**".
+type chatCommand struct {
+	Name string `json:"name"`
+	// Args holds the whitespace separated arguments, Text the raw remainder of the line
+	Args []string `json:"args,omitempty"`
+	Text string   `json:"text,omitempty"`
+}
+
+const chatCommandHelp = `I can help with the following commands:
+
+- ` + "`@whytho review [path ...]`" + ` - review the merge request again, optionally only files matching the given patterns (e.g. ` + "`internal/**`" + `)
+- ` + "`@whytho ignore`" + ` - stop reviewing this merge request automatically on every push
+- ` + "`@whytho summarize`" + ` - summarize the changes in this merge request
+- ` + "`@whytho explain [question]`" + ` - on a diff thread, explain the commented code`
+
+// parseChatCommand finds the first line of a comment that starts with one of
+// the mentions and returns the command that follows it. Mentions elsewhere in
+// a line are ignored so quoting a command does not trigger it.
+func parseChatCommand(body string, mentions []string) (chatCommand, bool) {
+	for _, line := range strings.Split(body, "\n") {
+		line = strings.TrimSpace(line)
+		for _, mention := range mentions {
+			if mention == "" || len(line) < len(mention) || !strings.EqualFold(line[:len(mention)], mention) {
+				continue
+			}
+			rest := line[len(mention):]
+			if rest != "" && rest[0] != ' ' && rest[0] != '\t' {
+				continue // e.g. "@whythonot"
+			}
+
+			fields := strings.Fields(rest)
+			if len(fields) == 0 {
+				return chatCommand{}, true
+			}
+			name := strings.ToLower(fields[0])
+			text := strings.TrimSpace(strings.TrimSpace(rest)[len(fields[0]):])
+			return chatCommand{Name: name, Args: fields[1:], Text: text}, true
+		}
+	}
+	return chatCommand{}, false
+}
+
+// filterChangesByPaths keeps the changes whose new or old path matches one of
+// the patterns.
+func filterChangesByPaths(changes []models.MRChange, patterns []string) []models.MRChange {
+	var result []models.MRChange
+	for _, change := range changes {
+		if services.MatchesPathPatterns(change.NewPath, patterns) || services.MatchesPathPatterns(change.OldPath, patterns) {
+			result = append(result, change)
+		}
+	}
+	return result
+}
+
+// chatCommandJob is the queued payload of a chat command.
+type chatCommandJob struct {
+	Note    models.NoteWebhook `json:"note"`
+	Command chatCommand        `json:"command"`
+}
+
+func (h *WebhookHandler) handleChatCommandJob(ctx context.Context, job *queue.Job) error {
+	var payload chatCommandJob
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return fmt.Errorf("failed to decode chat command job payload: %w", err)
+	}
+
+	note := &payload.Note
+	lastAttempt := job.Attempts+1 >= job.MaxAttempts
+	err := h.runChatCommand(ctx, note, payload.Command, lastAttempt)
+	if err != nil && lastAttempt {
+		// Only report the failure once retries are exhausted
+		h.replyToNote(note, fmt.Sprintf("🤖 Sorry, `%s %s` failed: %v", botMention, payload.Command.Name, err))
+	}
+	return err
+}
+
+func (h *WebhookHandler) runChatCommand(ctx context.Context, note *models.NoteWebhook, command chatCommand, lastAttempt bool) error {
+	projectID := note.Project.ID
+	mrIID := note.MergeRequest.IID
+
+	logrus.WithFields(logrus.Fields{
+		"project_id": projectID,
+		"mr_iid":     mrIID,
+		"command":    command.Name,
+		"args":       command.Args,
+	}).Info("Running chat command")
+
+	switch command.Name {
+	case commandReview:
+		if note.MergeRequest.State != "opened" {
+			h.replyToNote(note, "🤖 Only open merge requests can be reviewed.")
+			return nil
+		}
+
+		newComments, err := h.runReview(ctx, reviewRequest{
+			ProjectID:    projectID,
+			MRIID:        mrIID,
+			Title:        note.MergeRequest.Title,
+			Description:  note.MergeRequest.Description,
+			TargetBranch: note.MergeRequest.TargetBranch,
+			HeadSHA:      note.MergeRequest.LastCommit.ID,
+			WebURL:       note.MergeRequest.URL,
+			Paths:        command.Args,
+		}, lastAttempt)
+		if err != nil {
+			return err
+		}
+
+		reply := fmt.Sprintf("🤖 Review finished with %d new comment(s).", newComments)
+		if newComments == 0 {
+			reply = "🤖 Review finished, no new findings."
+		}
+		if len(command.Args) > 0 {
+			reply += fmt.Sprintf(" Only files matching `%s` were reviewed.", strings.Join(command.Args, "`, `"))
+		}
+		h.replyToNote(note, reply)
+		return nil
+
+	case commandIgnore:
+		return h.gitlabService.DisableAutomaticReviews(projectID, mrIID, note.ObjectAttributes.DiscussionID,
+			fmt.Sprintf("🤖 Automatic reviews are now disabled for this merge request. Mention `%s review` to request a review.", botMention))
+
+	case commandSummarize:
+		changes, err := h.gitlabService.GetMRChanges(projectID, mrIID)
+		if err != nil {
+			return fmt.Errorf("failed to fetch MR changes: %w", err)
+		}
+		if len(changes) == 0 {
+			h.replyToNote(note, "🤖 This merge request has no changes to summarize.")
+			return nil
+		}
+
+		summary, err := h.reviewService.SummarizeChanges(ctx, changes, note.MergeRequest.Title, note.MergeRequest.Description)
+		if err != nil {
+			return err
+		}
+		h.replyToNote(note, fmt.Sprintf("## 🤖 Merge Request Summary\n\n%s", summary))
+		return nil
+
+	case commandExplain:
+		position := note.ObjectAttributes.Position
+		if position == nil || note.ObjectAttributes.Type != "DiffNote" {
+			h.replyToNote(note, fmt.Sprintf("🤖 `%s explain` only works in a comment on a line of the diff.", botMention))
+			return nil
+		}
+
+		changes, err := h.gitlabService.GetMRChanges(projectID, mrIID)
+		if err != nil {
+			return fmt.Errorf("failed to fetch MR changes: %w", err)
+		}
+		var change *models.MRChange
+		for i := range changes {
+			if changes[i].NewPath == position.NewPath {
+				change = &changes[i]
+				break
+			}
+		}
+		if change == nil {
+			h.replyToNote(note, fmt.Sprintf("🤖 `%s` is no longer part of this merge request.", position.NewPath))
+			return nil
+		}
+
+		explanation, err := h.reviewService.ExplainCode(ctx, *change, *position, command.Text)
+		if err != nil {
+			return err
+		}
+		h.replyToNote(note, "🤖 "+explanation)
+		return nil
+
+	case "":
+		h.replyToNote(note, "🤖 "+chatCommandHelp)
+		return nil
+
+	default:
+		h.replyToNote(note, fmt.Sprintf("🤖 I don't know the command `%s`. %s", command.Name, chatCommandHelp))
+		return nil
+	}
+}
+
+// replyToNote answers in the discussion the command was posted in. Failures
+// are logged only, so a command is not re-run just because the reply failed.
+func (h *WebhookHandler) replyToNote(note *models.NoteWebhook, body string) {
+	if err := h.gitlabService.ReplyToDiscussion(note.Project.ID, note.MergeRequest.IID, note.ObjectAttributes.DiscussionID, body); err != nil {
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"project_id":    note.Project.ID,
+			"mr_iid":        note.MergeRequest.IID,
+			"discussion_id": note.ObjectAttributes.DiscussionID,
+		}).Error("Failed to reply to chat command")
+	}
+}
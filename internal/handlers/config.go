@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/vinamra28/whytho/internal/models"
+	"github.com/vinamra28/whytho/internal/services"
+)
+
+// maxConfigSize bounds the configs accepted by the validation endpoint
+const maxConfigSize = 1 << 20
+
+// ValidateConfig validates the .whytho/config.yaml sent as the request body.
+func ValidateConfig(c *gin.Context) {
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxConfigSize))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+		return
+	}
+
+	problems := services.ValidateWhyThoConfig(string(body))
+	if problems == nil {
+		problems = []string{}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"valid":    len(problems) == 0,
+		"problems": problems,
+	})
+}
+
+// ConfigSchema serves the JSON Schema of .whytho/config.yaml.
+func ConfigSchema(c *gin.Context) {
+	c.Data(http.StatusOK, "application/schema+json", services.WhyThoConfigSchema)
+}
+
+// reportConfigProblems validates the .whytho/ files modified by the pull
+// request and reports their problems in a bot note, which is edited in place
+// on later reviews and marked fixed once they are valid. noteID and
+// fingerprint identify the existing note, if any. Failures are logged only.
+func reportConfigProblems(provider services.SCMProvider, target services.ReviewTarget, changes []models.MRChange, noteID int64, fingerprint string) {
+	problems := services.ValidateConfigChanges(target, changes)
+	if len(problems) == 0 && noteID == 0 {
+		return
+	}
+
+	body, newFingerprint := services.ConfigCheckBody(problems)
+	if newFingerprint == fingerprint {
+		return
+	}
+
+	var err error
+	if noteID != 0 {
+		err = provider.UpdateComment(target.Repo, target.Number, noteID, body)
+	} else {
+		err = provider.PostGeneralComment(target.Repo, target.Number, body)
+	}
+	if err != nil {
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"project_id": target.Repo,
+			"mr_iid":     target.Number,
+		}).Error("Failed to post WhyTho config problems")
+	}
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/vinamra28/whytho/internal/models"
+	"github.com/vinamra28/whytho/internal/queue"
+	"github.com/vinamra28/whytho/internal/services"
+)
+
+// jobTypeMergeRequestDryRun reviews a merge request event without posting.
+const jobTypeMergeRequestDryRun = "merge_request_dry_run"
+
+// isDryRunRequest reports whether the webhook URL asks for a dry run with
+// ?dry_run=true.
+func isDryRunRequest(c *gin.Context) bool {
+	dryRun, _ := strconv.ParseBool(c.Query("dry_run"))
+	return dryRun
+}
+
+func (h *WebhookHandler) handleDryRunJob(ctx context.Context, job *queue.Job) error {
+	var webhook models.GitLabWebhook
+	if err := json.Unmarshal(job.Payload, &webhook); err != nil {
+		return fmt.Errorf("failed to decode review job payload: %w", err)
+	}
+
+	req := mergeRequestReviewRequest(&webhook)
+	req.DryRun = services.NewDryRunArtifact("gitlab", strconv.Itoa(req.ProjectID), req.MRIID, job.Payload)
+	_, err := h.runReview(ctx, req, job.Attempts+1 >= job.MaxAttempts)
+	writeDryRunArtifact(h.dryRunDir, req.DryRun, err)
+	return err
+}
+
+// writeDryRunArtifact writes the artifact of a finished dry run. Failures are
+// logged only.
+func writeDryRunArtifact(dir string, artifact *services.DryRunArtifact, reviewErr error) {
+	fields := logrus.Fields{
+		"provider":   artifact.Provider,
+		"project_id": artifact.Repo,
+		"mr_iid":     artifact.Number,
+	}
+	path, err := artifact.Write(dir, reviewErr)
+	if err != nil {
+		logrus.WithError(err).WithFields(fields).Error("Failed to write dry run artifact")
+		return
+	}
+	logrus.WithFields(fields).WithField("path", path).Info("Dry run artifact written")
+}
+
+// savePayload stores a received webhook so it can be fed to "whytho replay".
+// Failures are logged only.
+func (h *WebhookHandler) savePayload(eventType string, body []byte) {
+	if h.payloadDir == "" {
+		return
+	}
+	if err := os.MkdirAll(h.payloadDir, 0o755); err != nil {
+		logrus.WithError(err).WithField("dir", h.payloadDir).Error("Failed to create webhook payload directory")
+		return
+	}
+
+	event := strings.ToLower(strings.ReplaceAll(strings.TrimSuffix(eventType, " Hook"), " ", "-"))
+	if event == "" {
+		event = "unknown"
+	}
+	path := filepath.Join(h.payloadDir, fmt.Sprintf("%s-%s.json", time.Now().UTC().Format("20060102T150405.000000"), event))
+	if err := os.WriteFile(path, body, 0o600); err != nil {
+		logrus.WithError(err).WithField("path", path).Error("Failed to store webhook payload")
+		return
+	}
+	logrus.WithField("path", path).Debug("Stored webhook payload")
+}
@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+	"github.com/vinamra28/whytho/internal/models"
+	"github.com/vinamra28/whytho/internal/queue"
+	"github.com/vinamra28/whytho/internal/services"
+)
+
+// maxFollowUpReplies caps how often the bot answers in a single thread so an
+// argument cannot go on forever.
+const maxFollowUpReplies = 5
+
+// isThreadReply reports whether a note was posted inside a discussion rather
+// than as a new top-level comment.
+func isThreadReply(note *models.NoteWebhook) bool {
+	return note.ObjectAttributes.DiscussionID != "" &&
+		(note.ObjectAttributes.Type == "DiffNote" || note.ObjectAttributes.Type == "DiscussionNote")
+}
+
+func (h *WebhookHandler) handleFollowUpJob(ctx context.Context, job *queue.Job) error {
+	var note models.NoteWebhook
+	if err := json.Unmarshal(job.Payload, &note); err != nil {
+		return fmt.Errorf("failed to decode follow-up job payload: %w", err)
+	}
+	return h.answerFollowUp(ctx, &note)
+}
+
+// answerFollowUp continues the conversation when a developer replies to one
+// of the bot's findings, conceding and resolving the thread when the model
+// agrees the finding no longer applies.
+func (h *WebhookHandler) answerFollowUp(ctx context.Context, note *models.NoteWebhook) error {
+	projectID := note.Project.ID
+	mrIID := note.MergeRequest.IID
+	discussionID := note.ObjectAttributes.DiscussionID
+
+	thread, err := h.gitlabService.GetBotThread(projectID, mrIID, discussionID)
+	if err != nil {
+		return fmt.Errorf("failed to fetch discussion: %w", err)
+	}
+	if thread == nil || thread.Discussion.Kind != services.BotNoteKindFinding {
+		logrus.WithFields(logrus.Fields{
+			"project_id":    projectID,
+			"mr_iid":        mrIID,
+			"discussion_id": discussionID,
+		}).Debug("Reply is not on a bot finding, ignoring")
+		return nil
+	}
+
+	fields := logrus.Fields{
+		"project_id":    projectID,
+		"mr_iid":        mrIID,
+		"discussion_id": discussionID,
+		"file_path":     thread.Discussion.FilePath,
+	}
+	if thread.Discussion.Resolved {
+		logrus.WithFields(fields).Debug("Thread is already resolved, not answering")
+		return nil
+	}
+	// A retried or late job may find the bot already had the last word
+	if last := thread.Notes[len(thread.Notes)-1]; last.FromBot {
+		logrus.WithFields(fields).Debug("Latest note in thread is from the bot, not answering")
+		return nil
+	}
+	botReplies := 0
+	for _, threadNote := range thread.Notes[1:] {
+		if threadNote.FromBot {
+			botReplies++
+		}
+	}
+	if botReplies >= maxFollowUpReplies {
+		logrus.WithFields(fields).Info("Reached follow-up reply limit for thread, not answering")
+		return nil
+	}
+
+	hunk := ""
+	if thread.Position != nil {
+		changes, err := h.gitlabService.GetMRChanges(projectID, mrIID)
+		if err != nil {
+			return fmt.Errorf("failed to fetch MR changes: %w", err)
+		}
+		for _, change := range changes {
+			if change.NewPath == thread.Position.NewPath {
+				hunk = services.DiffHunk(change.Diff, thread.Position.NewLine, thread.Position.OldLine)
+				break
+			}
+		}
+		if hunk == "" {
+			logrus.WithFields(fields).Debug("Finding's line is no longer in the MR diff, answering without code context")
+		}
+	}
+
+	answer, err := h.reviewService.AnswerFollowUp(ctx, thread, hunk)
+	if err != nil {
+		return err
+	}
+
+	req := reviewRequest{
+		ProjectID:    projectID,
+		MRIID:        mrIID,
+		TargetBranch: note.MergeRequest.TargetBranch,
+		HeadSHA:      note.MergeRequest.LastCommit.ID,
+		WebURL:       note.MergeRequest.URL,
+	}
+	resolve := answer.Resolve && thread.Discussion.Resolvable
+	reply := "🤖 " + answer.Reply
+	if resolve && gatesSeverity(h.gatingPolicy(req), thread.Discussion.Severity) {
+		// The bot must not lift a merge gate on its own say-so
+		logrus.WithFields(fields).WithField("severity", thread.Discussion.Severity).Info("Finding is gated, leaving its resolution to a human")
+		resolve = false
+		reply += "\n\n_This finding's severity gates the merge request, so please resolve the thread yourself if you agree it is addressed._"
+	}
+
+	logrus.WithFields(fields).WithField("resolve", resolve).Info("Posting follow-up answer")
+	if resolve {
+		if err := h.gitlabService.ResolveDiscussion(projectID, mrIID, discussionID, reply); err != nil {
+			return err
+		}
+
+		// Resolving the finding may lift the merge gate
+		if err := h.reevaluateGate(req); err != nil {
+			logrus.WithError(err).WithFields(fields).Warn("Failed to re-evaluate merge gate")
+		}
+		return nil
+	}
+	return h.gitlabService.ReplyToDiscussion(projectID, mrIID, discussionID, reply)
+}
@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/vinamra28/whytho/internal/models"
+	"github.com/vinamra28/whytho/internal/queue"
+	"github.com/vinamra28/whytho/internal/services"
+)
+
+// gateDecision is the outcome of a gating policy for the open findings of a
+// merge request, counted by severity.
+type gateDecision struct {
+	Blocking   map[string]int
+	Unresolved map[string]int
+}
+
+// evaluateGate matches the open findings against the policy's severities.
+func evaluateGate(policy *models.GatingPolicy, open map[string]int) gateDecision {
+	decision := gateDecision{
+		Blocking:   make(map[string]int),
+		Unresolved: make(map[string]int),
+	}
+	for severity, count := range open {
+		if count == 0 {
+			continue
+		}
+		if containsSeverity(policy.Block, severity) {
+			decision.Blocking[severity] = count
+		} else if containsSeverity(policy.RequireResolution, severity) {
+			decision.Unresolved[severity] = count
+		}
+	}
+	return decision
+}
+
+// gatesSeverity reports whether open findings of the severity block the
+// merge request or withhold the bot's approval under the policy.
+func gatesSeverity(policy *models.GatingPolicy, severity string) bool {
+	if policy == nil {
+		return false
+	}
+	return containsSeverity(policy.Block, severity) || containsSeverity(policy.RequireResolution, severity)
+}
+
+func containsSeverity(severities []string, severity string) bool {
+	for _, s := range severities {
+		if strings.EqualFold(strings.TrimSpace(s), severity) {
+			return true
+		}
+	}
+	return false
+}
+
+// gatingPolicy resolves the gating policy from the inherited config and the
+// target branch's .whytho/config.yaml. The MR's own version is deliberately
+// not used, so a merge request cannot relax the gate it is held to.
+func (h *WebhookHandler) gatingPolicy(req reviewRequest) *models.GatingPolicy {
+	if req.TargetBranch == "" {
+		return nil
+	}
+	resolved := h.reviewService.ResolveConfig(services.ReviewTarget{
+		Provider:     h.gitlabService,
+		Repo:         strconv.Itoa(req.ProjectID),
+		Number:       req.MRIID,
+		TargetBranch: req.TargetBranch,
+	}, nil)
+	return resolved.Config.Gating
+}
+
+// applyGatingPolicy approves the MR or revokes the bot's approval as the
+// policy demands and returns the resulting review status.
+func (h *WebhookHandler) applyGatingPolicy(req reviewRequest, policy *models.GatingPolicy, report *findingsReport) (string, string) {
+	decision := evaluateGate(policy, report.Open)
+
+	fields := logrus.Fields{
+		"project_id": req.ProjectID,
+		"mr_iid":     req.MRIID,
+		"blocking":   decision.Blocking,
+		"unresolved": decision.Unresolved,
+	}
+	logrus.WithFields(fields).Info("Evaluated merge gating policy")
+
+	if policy.Approve {
+		approve := len(decision.Blocking) == 0 && len(decision.Unresolved) == 0
+		if _, err := h.gitlabService.SetBotApproval(req.ProjectID, req.MRIID, approve, req.HeadSHA); err != nil {
+			logrus.WithError(err).WithFields(fields).Warn("Failed to update bot approval")
+		}
+	}
+
+	switch {
+	case len(decision.Blocking) > 0:
+		return services.ReviewStatusFailed, fmt.Sprintf("Blocked by open findings: %s", formatSeverityCounts(decision.Blocking))
+	case len(decision.Unresolved) > 0:
+		return services.ReviewStatusSuccess, fmt.Sprintf("Review completed, resolve before approval: %s", formatSeverityCounts(decision.Unresolved))
+	default:
+		return services.ReviewStatusSuccess, report.description()
+	}
+}
+
+// withholdGate reports the head commit as pending and revokes the bot's
+// approval, for head commits the gate cannot be evaluated for.
+func (h *WebhookHandler) withholdGate(req reviewRequest, policy *models.GatingPolicy, description, targetURL string) {
+	if policy.Approve {
+		if _, err := h.gitlabService.SetBotApproval(req.ProjectID, req.MRIID, false, req.HeadSHA); err != nil {
+			logrus.WithError(err).WithFields(logrus.Fields{
+				"project_id": req.ProjectID,
+				"mr_iid":     req.MRIID,
+			}).Warn("Failed to revoke bot approval")
+		}
+	}
+	h.postReviewStatus(req, services.ReviewStatusPending, description, targetURL)
+}
+
+func (h *WebhookHandler) handleGateJob(ctx context.Context, job *queue.Job) error {
+	var webhook models.GitLabWebhook
+	if err := json.Unmarshal(job.Payload, &webhook); err != nil {
+		return fmt.Errorf("failed to decode gate job payload: %w", err)
+	}
+
+	req := mergeRequestReviewRequest(&webhook)
+	req.Automatic = false
+	return h.reevaluateGate(req)
+}
+
+// reevaluateGate refreshes the review status and the bot's approval after
+// findings were resolved, without reviewing the MR again. Only a head commit
+// the bot reviewed is re-evaluated, so findings of earlier commits never pass
+// code that was not reviewed.
+func (h *WebhookHandler) reevaluateGate(req reviewRequest) error {
+	report, err := h.findingsReport(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch bot discussions: %w", err)
+	}
+	if req.HeadSHA == "" || report.ReviewedSHA != req.HeadSHA {
+		logrus.WithFields(logrus.Fields{
+			"project_id":   req.ProjectID,
+			"mr_iid":       req.MRIID,
+			"head_sha":     req.HeadSHA,
+			"reviewed_sha": report.ReviewedSHA,
+		}).Debug("Head commit was not reviewed, leaving the merge gate alone")
+		return nil
+	}
+
+	h.publishReviewOutcome(req, h.gatingPolicy(req), report)
+	return nil
+}
@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// GiteaHandler receives Gitea and Forgejo pull_request and issue_comment
+// webhooks and queues reviews with the PullRequestReviewer.
+type GiteaHandler struct {
+	reviewer *PullRequestReviewer
+	// secrets verify the X-Gitea-Signature header when set
+	secrets []string
+}
+
+func NewGiteaHandler(reviewer *PullRequestReviewer, secrets []string) *GiteaHandler {
+	logrus.Info("Creating Gitea webhook handler")
+	return &GiteaHandler{reviewer: reviewer, secrets: secrets}
+}
+
+type giteaRepository struct {
+	FullName string `json:"full_name"`
+}
+
+type giteaPullRequestEvent struct {
+	Action      string          `json:"action"`
+	Number      int             `json:"number"`
+	Repository  giteaRepository `json:"repository"`
+	PullRequest struct {
+		State string `json:"state"`
+	} `json:"pull_request"`
+}
+
+type giteaIssueCommentEvent struct {
+	Action     string          `json:"action"`
+	Repository giteaRepository `json:"repository"`
+	IsPull     bool            `json:"is_pull"`
+	Issue      struct {
+		Number int `json:"number"`
+	} `json:"issue"`
+	Comment struct {
+		Body string `json:"body"`
+	} `json:"comment"`
+}
+
+func (h *GiteaHandler) HandleWebhook(c *gin.Context) {
+	logrus.Info("Received Gitea webhook request")
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to read request body")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+		return
+	}
+
+	if len(h.secrets) > 0 {
+		signature := c.GetHeader("X-Gitea-Signature")
+		if signature == "" {
+			signature = c.GetHeader("X-Forgejo-Signature")
+		}
+		if !verifyHMACSignature(h.secrets, signature, body) {
+			logrus.Warn("Invalid Gitea webhook signature received")
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid signature"})
+			return
+		}
+	}
+
+	// Forgejo sends its own headers next to the Gitea compatible ones
+	eventType := c.GetHeader("X-Gitea-Event")
+	if eventType == "" {
+		eventType = c.GetHeader("X-Forgejo-Event")
+	}
+	logrus.WithField("event_type", eventType).Debug("Received Gitea event")
+	switch eventType {
+	case "pull_request":
+		h.handlePullRequestEvent(c, body)
+	case "issue_comment":
+		h.handleIssueCommentEvent(c, body)
+	default:
+		logrus.WithField("event_type", eventType).Info("Ignoring unsupported event")
+		c.JSON(http.StatusOK, gin.H{"message": "Event ignored"})
+	}
+}
+
+func (h *GiteaHandler) handlePullRequestEvent(c *gin.Context, body []byte) {
+	var event giteaPullRequestEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		logrus.WithError(err).Error("Failed to parse webhook payload")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to parse webhook"})
+		return
+	}
+
+	switch event.Action {
+	case "opened", "reopened", "synchronized":
+	default:
+		logrus.WithField("action", event.Action).Info("Ignoring pull request action")
+		c.JSON(http.StatusOK, gin.H{"message": "Action ignored"})
+		return
+	}
+	if event.PullRequest.State != "open" {
+		c.JSON(http.StatusOK, gin.H{"message": "PR not open, review skipped"})
+		return
+	}
+
+	h.reviewer.queueReview(c, pullRequestJob{
+		Provider: "gitea",
+		Repo:     event.Repository.FullName,
+		Number:   event.Number,
+	})
+}
+
+func (h *GiteaHandler) handleIssueCommentEvent(c *gin.Context, body []byte) {
+	var event giteaIssueCommentEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		logrus.WithError(err).Error("Failed to parse webhook payload")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to parse webhook"})
+		return
+	}
+
+	if event.Action != "created" || !event.IsPull {
+		c.JSON(http.StatusOK, gin.H{"message": "Comment ignored"})
+		return
+	}
+
+	h.reviewer.queueCommandReview(c, "gitea", event.Repository.FullName, event.Issue.Number, event.Comment.Body)
+}
@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// GitHubHandler receives GitHub pull_request and issue_comment webhooks and
+// queues reviews with the PullRequestReviewer.
+type GitHubHandler struct {
+	reviewer *PullRequestReviewer
+	// secrets verify the X-Hub-Signature-256 header when set
+	secrets []string
+}
+
+func NewGitHubHandler(reviewer *PullRequestReviewer, secrets []string) *GitHubHandler {
+	logrus.Info("Creating GitHub webhook handler")
+	return &GitHubHandler{reviewer: reviewer, secrets: secrets}
+}
+
+type githubRepository struct {
+	FullName string `json:"full_name"`
+}
+
+type githubPullRequestEvent struct {
+	Action      string           `json:"action"`
+	Number      int              `json:"number"`
+	Repository  githubRepository `json:"repository"`
+	PullRequest struct {
+		State string `json:"state"`
+	} `json:"pull_request"`
+}
+
+type githubIssueCommentEvent struct {
+	Action     string           `json:"action"`
+	Repository githubRepository `json:"repository"`
+	Issue      struct {
+		Number int `json:"number"`
+		// PullRequest is only present for comments on pull requests
+		PullRequest *struct{} `json:"pull_request"`
+	} `json:"issue"`
+	Comment struct {
+		Body string `json:"body"`
+		User struct {
+			Login string `json:"login"`
+			Type  string `json:"type"`
+		} `json:"user"`
+	} `json:"comment"`
+}
+
+func (h *GitHubHandler) HandleWebhook(c *gin.Context) {
+	logrus.Info("Received GitHub webhook request")
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to read request body")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+		return
+	}
+
+	if len(h.secrets) > 0 {
+		signature := strings.TrimPrefix(c.GetHeader("X-Hub-Signature-256"), "sha256=")
+		if !verifyHMACSignature(h.secrets, signature, body) {
+			logrus.Warn("Invalid GitHub webhook signature received")
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid signature"})
+			return
+		}
+	}
+
+	eventType := c.GetHeader("X-GitHub-Event")
+	logrus.WithField("event_type", eventType).Debug("Received GitHub event")
+	switch eventType {
+	case "ping":
+		c.JSON(http.StatusOK, gin.H{"message": "pong"})
+	case "pull_request":
+		h.handlePullRequestEvent(c, body)
+	case "issue_comment":
+		h.handleIssueCommentEvent(c, body)
+	default:
+		logrus.WithField("event_type", eventType).Info("Ignoring unsupported event")
+		c.JSON(http.StatusOK, gin.H{"message": "Event ignored"})
+	}
+}
+
+func (h *GitHubHandler) handlePullRequestEvent(c *gin.Context, body []byte) {
+	var event githubPullRequestEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		logrus.WithError(err).Error("Failed to parse webhook payload")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to parse webhook"})
+		return
+	}
+
+	switch event.Action {
+	case "opened", "reopened", "synchronize", "ready_for_review":
+	default:
+		logrus.WithField("action", event.Action).Info("Ignoring pull request action")
+		c.JSON(http.StatusOK, gin.H{"message": "Action ignored"})
+		return
+	}
+	if event.PullRequest.State != "open" {
+		c.JSON(http.StatusOK, gin.H{"message": "PR not open, review skipped"})
+		return
+	}
+
+	h.reviewer.queueReview(c, pullRequestJob{
+		Provider: "github",
+		Repo:     event.Repository.FullName,
+		Number:   event.Number,
+	})
+}
+
+func (h *GitHubHandler) handleIssueCommentEvent(c *gin.Context, body []byte) {
+	var event githubIssueCommentEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		logrus.WithError(err).Error("Failed to parse webhook payload")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to parse webhook"})
+		return
+	}
+
+	if event.Action != "created" || event.Issue.PullRequest == nil || event.Comment.User.Type == "Bot" {
+		c.JSON(http.StatusOK, gin.H{"message": "Comment ignored"})
+		return
+	}
+
+	h.reviewer.queueCommandReview(c, "github", event.Repository.FullName, event.Issue.Number, event.Comment.Body)
+}
@@ -0,0 +1,305 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/vinamra28/whytho/internal/config"
+	"github.com/vinamra28/whytho/internal/models"
+	"github.com/vinamra28/whytho/internal/queue"
+	"github.com/vinamra28/whytho/internal/services"
+)
+
+const jobTypePullRequestReview = "pull_request_review"
+
+// pullRequestJob is the queued payload of a review on a provider other than GitLab.
+type pullRequestJob struct {
+	Provider string `json:"provider"`
+	Repo     string `json:"repo"`
+	Number   int    `json:"number"`
+	// Paths restricts the review to files matching these patterns
+	Paths []string `json:"paths,omitempty"`
+	// Command is set for reviews requested from a comment, which are answered with the outcome
+	Command bool `json:"command,omitempty"`
+	// DryRun records the review in a local artifact instead of posting it
+	DryRun bool `json:"dry_run,omitempty"`
+}
+
+// PullRequestReviewer reviews the pull requests of SCM providers other than
+// GitLab. It sticks to what every services.SCMProvider supports: findings are
+// deduplicated by fingerprint and the summary is edited in place, but there
+// are no incremental reviews, follow-up conversations or merge gating.
+type PullRequestReviewer struct {
+	providers     map[string]services.SCMProvider
+	reviewService *services.ReviewService
+	queue         *queue.Queue
+	commitStatus  bool
+	dryRun        bool
+	dryRunDir     string
+}
+
+func NewPullRequestReviewer(reviewService *services.ReviewService, jobQueue *queue.Queue, cfg *config.Config) *PullRequestReviewer {
+	r := &PullRequestReviewer{
+		providers:     make(map[string]services.SCMProvider),
+		reviewService: reviewService,
+		queue:         jobQueue,
+		commitStatus:  cfg.ReviewCommitStatus,
+		dryRun:        cfg.DryRun,
+		dryRunDir:     cfg.DryRunDir,
+	}
+	jobQueue.Register(jobTypePullRequestReview, r.handleReviewJob)
+	return r
+}
+
+// AddProvider makes a provider's pull requests reviewable.
+func (r *PullRequestReviewer) AddProvider(provider services.SCMProvider) {
+	r.providers[provider.Name()] = provider
+}
+
+// enqueue queues a review. Reviews of the same pull request run one at a time.
+func (r *PullRequestReviewer) enqueue(job pullRequestJob) error {
+	key := fmt.Sprintf("%s:%s#%d", job.Provider, job.Repo, job.Number)
+	_, err := r.queue.Enqueue(jobTypePullRequestReview, key, &job)
+	return err
+}
+
+// queueReview queues a review requested by a webhook and answers the webhook.
+func (r *PullRequestReviewer) queueReview(c *gin.Context, job pullRequestJob) {
+	job.DryRun = r.dryRun || isDryRunRequest(c)
+	logrus.WithFields(logrus.Fields{
+		"provider":   job.Provider,
+		"project_id": job.Repo,
+		"mr_iid":     job.Number,
+		"dry_run":    job.DryRun,
+	}).Info("Queueing pull request review")
+	if err := r.enqueue(job); err != nil {
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"provider":   job.Provider,
+			"project_id": job.Repo,
+			"mr_iid":     job.Number,
+		}).Error("Failed to queue pull request review")
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Failed to queue review"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook received"})
+}
+
+// queueCommandReview queues the review requested by a pull request comment
+// and answers the webhook. Only the review command is supported outside
+// GitLab, other comments are ignored.
+func (r *PullRequestReviewer) queueCommandReview(c *gin.Context, provider, repo string, number int, body string) {
+	command, ok := parseChatCommand(body, []string{botMention})
+	if !ok || command.Name != commandReview {
+		c.JSON(http.StatusOK, gin.H{"message": "No command found"})
+		return
+	}
+
+	r.queueReview(c, pullRequestJob{
+		Provider: provider,
+		Repo:     repo,
+		Number:   number,
+		Paths:    command.Args,
+		Command:  true,
+	})
+}
+
+func (r *PullRequestReviewer) handleReviewJob(ctx context.Context, job *queue.Job) error {
+	var payload pullRequestJob
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return fmt.Errorf("failed to decode pull request job payload: %w", err)
+	}
+	provider, ok := r.providers[payload.Provider]
+	if !ok {
+		return fmt.Errorf("no SCM provider %q is configured", payload.Provider)
+	}
+
+	reviewService := r.reviewService
+	var artifact *services.DryRunArtifact
+	if payload.DryRun {
+		artifact = services.NewDryRunArtifact(payload.Provider, payload.Repo, payload.Number, job.Payload)
+		provider = services.NewDryRunProvider(provider, artifact)
+		reviewService = reviewService.WithDryRun(artifact)
+	}
+
+	lastAttempt := job.Attempts+1 >= job.MaxAttempts
+	err := r.review(ctx, provider, reviewService, payload, lastAttempt)
+	if err != nil && lastAttempt && payload.Command {
+		r.reply(provider, payload, fmt.Sprintf("🤖 Sorry, `%s %s` failed: %v", botMention, commandReview, err))
+	}
+	if artifact != nil {
+		writeDryRunArtifact(r.dryRunDir, artifact, err)
+	}
+	return err
+}
+
+func (r *PullRequestReviewer) review(ctx context.Context, provider services.SCMProvider, reviewService *services.ReviewService, job pullRequestJob, lastAttempt bool) error {
+	pr, err := provider.GetPullRequest(job.Repo, job.Number)
+	if err != nil {
+		return err
+	}
+	if !pr.Open {
+		logrus.WithFields(logrus.Fields{
+			"provider":   job.Provider,
+			"project_id": job.Repo,
+			"mr_iid":     job.Number,
+		}).Info("Pull request is not open, skipping review")
+		if job.Command {
+			r.reply(provider, job, "🤖 Only open pull requests can be reviewed.")
+		}
+		return nil
+	}
+
+	r.setStatus(provider, job, pr, services.ReviewStatusRunning, "Review in progress", pr.WebURL)
+
+	newComments, findings, err := r.reviewPullRequest(ctx, provider, reviewService, job, pr)
+	if err != nil {
+		if lastAttempt {
+			r.setStatus(provider, job, pr, services.ReviewStatusFailed, "Review failed", pr.WebURL)
+		} else {
+			r.setStatus(provider, job, pr, services.ReviewStatusPending, "Review failed, retrying", pr.WebURL)
+		}
+		return err
+	}
+
+	description := "Review completed, no findings"
+	if total := len(findings); total > 0 {
+		counts := make(map[string]int)
+		for _, finding := range findings {
+			counts[finding.Severity]++
+		}
+		description = fmt.Sprintf("Review completed, %d finding(s): %s", total, formatSeverityCounts(counts))
+	}
+	r.setStatus(provider, job, pr, services.ReviewStatusSuccess, description, pr.WebURL)
+
+	if job.Command {
+		reply := fmt.Sprintf("🤖 Review finished with %d new comment(s).", newComments)
+		if newComments == 0 {
+			reply = "🤖 Review finished, no new findings."
+		}
+		if len(job.Paths) > 0 {
+			reply += fmt.Sprintf(" Only files matching `%s` were reviewed.", strings.Join(job.Paths, "`, `"))
+		}
+		r.reply(provider, job, reply)
+	}
+	return nil
+}
+
+// reviewPullRequest reviews the pull request and posts the findings that were
+// not posted before. It returns the number of new comments and every finding
+// of the review.
+func (r *PullRequestReviewer) reviewPullRequest(ctx context.Context, provider services.SCMProvider, reviewService *services.ReviewService, job pullRequestJob, pr *models.PullRequest) (int, []models.PositionedComment, error) {
+	fields := logrus.Fields{
+		"provider":   job.Provider,
+		"project_id": job.Repo,
+		"mr_iid":     job.Number,
+	}
+	logrus.WithFields(fields).Info("Processing pull request")
+
+	changes, err := provider.GetChanges(job.Repo, job.Number)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to fetch pull request changes: %w", err)
+	}
+
+	// Look up what the bot already posted so unchanged findings are not repeated
+	botUsername, err := provider.BotUsername()
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to fetch bot user: %w", err)
+	}
+	comments, err := provider.ListComments(job.Repo, job.Number)
+	if err != nil {
+		logrus.WithError(err).WithFields(fields).Warn("Failed to fetch existing comments, posting all comments")
+	}
+	posted, summaryID := services.BotComments(comments, botUsername)
+
+	// Report invalid .whytho/ files modified by the pull request where developers see them
+	target := services.ReviewTarget{
+		Provider:     provider,
+		Repo:         job.Repo,
+		Number:       job.Number,
+		TargetBranch: pr.TargetBranch,
+		HeadRef:      pr.HeadSHA,
+	}
+	configNoteID, configFingerprint := services.ConfigNote(comments, botUsername)
+	reportConfigProblems(provider, target, changes, configNoteID, configFingerprint)
+
+	if len(job.Paths) > 0 {
+		changes = filterChangesByPaths(changes, job.Paths)
+	}
+	if len(changes) == 0 {
+		logrus.WithFields(fields).Info("No changes left to review, skipping review")
+		return 0, nil, nil
+	}
+
+	review, err := reviewService.ReviewCode(ctx, changes, pr.Title, pr.Description, target)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to review code: %w", err)
+	}
+
+	newComments := 0
+	for _, comment := range review.PositionedComments {
+		if posted[services.FindingFingerprint(comment)] {
+			continue
+		}
+		if err := provider.PostInlineComment(job.Repo, job.Number, comment); err != nil {
+			logrus.WithError(err).WithFields(fields).WithField("file_path", comment.FilePath).Error("Failed to post positioned review comment")
+			continue
+		}
+		newComments++
+	}
+	for _, comment := range review.Comments {
+		if posted[services.CommentFingerprint(comment)] {
+			continue
+		}
+		if err := provider.PostGeneralComment(job.Repo, job.Number, services.GeneralCommentBody(comment)); err != nil {
+			logrus.WithError(err).WithFields(fields).Error("Failed to post general review comment")
+			continue
+		}
+		newComments++
+	}
+
+	if summary := reviewSummaryComment(reviewRequest{Paths: job.Paths}, review, false); summary != "" {
+		body := services.SummaryCommentBody(summary, pr.HeadSHA)
+		if summaryID != 0 {
+			err = provider.UpdateComment(job.Repo, job.Number, summaryID, body)
+		} else {
+			err = provider.PostGeneralComment(job.Repo, job.Number, body)
+		}
+		if err != nil {
+			logrus.WithError(err).WithFields(fields).Error("Failed to post summary comment")
+		}
+	}
+
+	logrus.WithFields(fields).WithField("new_comments", newComments).Info("Pull request processing completed")
+	return newComments, review.PositionedComments, nil
+}
+
+// setStatus reports the review status when enabled, logging failures only.
+func (r *PullRequestReviewer) setStatus(provider services.SCMProvider, job pullRequestJob, pr *models.PullRequest, state, description, targetURL string) {
+	if !r.commitStatus || pr.HeadSHA == "" {
+		return
+	}
+	if err := provider.SetStatus(job.Repo, pr.HeadSHA, state, description, targetURL); err != nil {
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"provider":   job.Provider,
+			"project_id": job.Repo,
+			"mr_iid":     job.Number,
+			"state":      state,
+		}).Warn("Failed to report review status")
+	}
+}
+
+// reply answers a review command. Failures are logged only.
+func (r *PullRequestReviewer) reply(provider services.SCMProvider, job pullRequestJob, body string) {
+	if err := provider.PostGeneralComment(job.Repo, job.Number, body); err != nil {
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"provider":   job.Provider,
+			"project_id": job.Repo,
+			"mr_iid":     job.Number,
+		}).Error("Failed to reply to review command")
+	}
+}
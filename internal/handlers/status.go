@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/vinamra28/whytho/internal/models"
+	"github.com/vinamra28/whytho/internal/services"
+)
+
+// severityOrder lists the finding severities from most to least severe.
+var severityOrder = []string{"CRITICAL", "HIGH", "MEDIUM", "LOW"}
+
+// runReview runs reviewMergeRequest and reports its progress as the
+// "whytho/review" commit status of the reviewed head commit.
+func (h *WebhookHandler) runReview(ctx context.Context, req reviewRequest, lastAttempt bool) (int, error) {
+	// The gate is enforced through the status, so a gated MR reports every
+	// state even when review statuses are disabled
+	policy := h.gatingPolicy(req)
+	setStatus := h.setReviewStatus
+	if policy != nil {
+		setStatus = h.postReviewStatus
+	}
+	setStatus(req, services.ReviewStatusRunning, "Review in progress", req.WebURL)
+
+	newComments, err := h.reviewMergeRequest(ctx, req)
+	if err != nil {
+		if lastAttempt {
+			setStatus(req, services.ReviewStatusFailed, "Review failed", req.WebURL)
+		} else {
+			setStatus(req, services.ReviewStatusPending, "Review failed, retrying", req.WebURL)
+		}
+		return newComments, err
+	}
+	if req.DryRun != nil {
+		// The final status and gating depend on the notes a dry run does not post
+		return newComments, nil
+	}
+
+	report, err := h.findingsReport(req)
+	if err != nil {
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"project_id": req.ProjectID,
+			"mr_iid":     req.MRIID,
+		}).Warn("Failed to fetch bot discussions for the review status")
+		if policy != nil {
+			setStatus(req, services.ReviewStatusFailed, "Review completed, merge gate could not be evaluated", req.WebURL)
+		} else {
+			setStatus(req, services.ReviewStatusSuccess, "Review completed", req.WebURL)
+		}
+		return newComments, nil
+	}
+	h.publishReviewOutcome(req, policy, report)
+	return newComments, nil
+}
+
+// findingsReport summarises the bot's discussions on a merge request.
+type findingsReport struct {
+	// Open counts the unresolved findings by severity
+	Open  map[string]int
+	Total int
+	// TargetURL links to the review summary, or to the MR when there is none
+	TargetURL string
+	// ReviewedSHA is the head commit of the last review that posted a summary
+	ReviewedSHA       string
+	AutomaticDisabled bool
+}
+
+func (h *WebhookHandler) findingsReport(req reviewRequest) (*findingsReport, error) {
+	discussions, err := h.gitlabService.ListBotDiscussions(req.ProjectID, req.MRIID)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &findingsReport{
+		Open:      make(map[string]int),
+		TargetURL: req.WebURL,
+	}
+	for _, discussion := range discussions {
+		switch discussion.Kind {
+		case services.BotNoteKindSummary:
+			report.ReviewedSHA = discussion.HeadSHA
+			if req.WebURL != "" {
+				report.TargetURL = fmt.Sprintf("%s#note_%d", req.WebURL, discussion.NoteID)
+			}
+		case services.BotNoteKindIgnore:
+			report.AutomaticDisabled = true
+		case services.BotNoteKindFinding:
+			if !discussion.Resolved {
+				report.Open[discussion.Severity]++
+				report.Total++
+			}
+		}
+	}
+	return report, nil
+}
+
+// description describes the open findings by severity.
+func (r *findingsReport) description() string {
+	if r.Total == 0 {
+		return "Review completed, no open findings"
+	}
+	return fmt.Sprintf("Review completed, %d open finding(s): %s", r.Total, formatSeverityCounts(r.Open))
+}
+
+// publishReviewOutcome sets the final review status from the open findings,
+// applying the project's gating policy when it has one.
+func (h *WebhookHandler) publishReviewOutcome(req reviewRequest, policy *models.GatingPolicy, report *findingsReport) {
+	if req.Automatic && report.AutomaticDisabled {
+		if policy != nil {
+			// A skipped review must not pass the gate, or "@whytho ignore"
+			// would let every later push through unreviewed
+			h.withholdGate(req, policy, "Automatic reviews disabled, comment @whytho review to review", report.TargetURL)
+			return
+		}
+		h.setReviewStatus(req, services.ReviewStatusSuccess, "Automatic reviews disabled", report.TargetURL)
+		return
+	}
+
+	if policy == nil {
+		h.setReviewStatus(req, services.ReviewStatusSuccess, report.description(), report.TargetURL)
+		return
+	}
+	// The gate is enforced through the status, so it is reported even when
+	// review statuses are disabled
+	state, description := h.applyGatingPolicy(req, policy, report)
+	h.postReviewStatus(req, state, description, report.TargetURL)
+}
+
+// formatSeverityCounts renders counts such as "1 critical, 2 high", most
+// severe first.
+func formatSeverityCounts(counts map[string]int) string {
+	var parts []string
+	for _, severity := range severityOrder {
+		if counts[severity] > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", counts[severity], strings.ToLower(severity)))
+		}
+	}
+
+	// Severities the model made up are listed last
+	var other []string
+	for severity, count := range counts {
+		if isKnownSeverity(severity) || count == 0 {
+			continue
+		}
+		name := strings.ToLower(severity)
+		if name == "" {
+			name = "unrated"
+		}
+		other = append(other, fmt.Sprintf("%d %s", count, name))
+	}
+	sort.Strings(other)
+
+	return strings.Join(append(parts, other...), ", ")
+}
+
+// setReviewStatus sets the review commit status when status reporting is
+// enabled. Failures are logged only so they never fail a review.
+func (h *WebhookHandler) setReviewStatus(req reviewRequest, state, description, targetURL string) {
+	if !h.commitStatus {
+		return
+	}
+	h.postReviewStatus(req, state, description, targetURL)
+}
+
+// postReviewStatus sets the review commit status regardless of whether
+// status reporting is enabled. Failures are logged only.
+func (h *WebhookHandler) postReviewStatus(req reviewRequest, state, description, targetURL string) {
+	if req.HeadSHA == "" {
+		return
+	}
+	if req.DryRun != nil {
+		req.DryRun.AddNote(services.DryRunNoteStatus, req.HeadSHA, state+": "+description)
+		return
+	}
+	if err := h.gitlabService.SetReviewStatus(req.ProjectID, req.HeadSHA, state, description, targetURL); err != nil {
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"project_id": req.ProjectID,
+			"mr_iid":     req.MRIID,
+			"state":      state,
+		}).Warn("Failed to report review status")
+	}
+}
+
+func isKnownSeverity(severity string) bool {
+	for _, known := range severityOrder {
+		if severity == known {
+			return true
+		}
+	}
+	return false
+}
@@ -1,33 +1,63 @@
 package handlers
 
 import (
-	"crypto/hmac"
-	"crypto/sha256"
-	"encoding/hex"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
+	"github.com/vinamra28/whytho/internal/config"
 	"github.com/vinamra28/whytho/internal/models"
+	"github.com/vinamra28/whytho/internal/queue"
 	"github.com/vinamra28/whytho/internal/services"
 )
 
+const (
+	jobTypeMergeRequestReview = "merge_request_review"
+	jobTypeChatCommand        = "chat_command"
+	jobTypeFollowUp           = "follow_up"
+	jobTypeGateEvaluation     = "gate_evaluation"
+)
+
 type WebhookHandler struct {
-	gitlabService *services.GitLabService
-	reviewService *services.ReviewService
-	webhookSecret string
+	gitlabService     *services.GitLabService
+	reviewService     *services.ReviewService
+	queue             *queue.Queue
+	authenticator     *WebhookAuthenticator
+	incrementalReview bool
+	draftReviews      bool
+	commitStatus      bool
+	// dryRun records every review in dryRunDir instead of posting it
+	dryRun     bool
+	dryRunDir  string
+	payloadDir string
 }
 
-func NewWebhookHandler(gitlabService *services.GitLabService, reviewService *services.ReviewService, webhookSecret string) *WebhookHandler {
+func NewWebhookHandler(gitlabService *services.GitLabService, reviewService *services.ReviewService, jobQueue *queue.Queue, cfg *config.Config) *WebhookHandler {
 	logrus.Info("Creating webhook handler")
-	return &WebhookHandler{
-		gitlabService: gitlabService,
-		reviewService: reviewService,
-		webhookSecret: webhookSecret,
+	h := &WebhookHandler{
+		gitlabService:     gitlabService,
+		reviewService:     reviewService,
+		queue:             jobQueue,
+		authenticator:     NewWebhookAuthenticator(cfg.WebhookSecrets, cfg.WebhookScopedSecrets, cfg.WebhookSigningTokens, gitlabService.ProjectPath),
+		incrementalReview: cfg.IncrementalReview,
+		draftReviews:      cfg.DraftReviews,
+		commitStatus:      cfg.ReviewCommitStatus,
+		dryRun:            cfg.DryRun,
+		dryRunDir:         cfg.DryRunDir,
+		payloadDir:        cfg.WebhookPayloadDir,
 	}
+	jobQueue.Register(jobTypeMergeRequestReview, h.handleReviewJob)
+	jobQueue.Register(jobTypeMergeRequestDryRun, h.handleDryRunJob)
+	jobQueue.Register(jobTypeChatCommand, h.handleChatCommandJob)
+	jobQueue.Register(jobTypeFollowUp, h.handleFollowUpJob)
+	jobQueue.Register(jobTypeGateEvaluation, h.handleGateJob)
+	return h
 }
 
 func (h *WebhookHandler) HandleWebhook(c *gin.Context) {
@@ -39,24 +69,31 @@ func (h *WebhookHandler) HandleWebhook(c *gin.Context) {
 		return
 	}
 
-	if h.webhookSecret != "" {
-		logrus.Debug("Verifying webhook signature")
-		if !h.verifySignature(body, c.GetHeader("X-Gitlab-Token")) {
-			logrus.Warn("Invalid webhook signature received")
+	if h.authenticator.Enabled() {
+		logrus.Debug("Verifying webhook authentication")
+		if err := h.authenticator.Authenticate(c.Request.Header, body); err != nil {
+			logrus.WithError(err).Warn("Invalid webhook authentication received")
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid signature"})
 			return
 		}
-		logrus.Debug("Webhook signature verified successfully")
+		logrus.Debug("Webhook authentication verified successfully")
 	}
 
 	eventType := c.GetHeader("X-Gitlab-Event")
 	logrus.WithField("event_type", eventType).Debug("Received GitLab event")
-	if eventType != "Merge Request Hook" {
-		logrus.WithField("event_type", eventType).Info("Ignoring non-merge request event")
+	h.savePayload(eventType, body)
+	switch eventType {
+	case "Merge Request Hook":
+		h.handleMergeRequestEvent(c, body)
+	case "Note Hook":
+		h.handleNoteEvent(c, body)
+	default:
+		logrus.WithField("event_type", eventType).Info("Ignoring unsupported event")
 		c.JSON(http.StatusOK, gin.H{"message": "Event ignored"})
-		return
 	}
+}
 
+func (h *WebhookHandler) handleMergeRequestEvent(c *gin.Context, body []byte) {
 	var webhook models.GitLabWebhook
 	if err := json.Unmarshal(body, &webhook); err != nil {
 		logrus.WithError(err).Error("Failed to parse webhook payload")
@@ -88,39 +125,222 @@ func (h *WebhookHandler) HandleWebhook(c *gin.Context) {
 
 	// Check if this is a new commit (only for update actions)
 	// The oldrev field is only present when commits are pushed to the MR
+	dryRun := h.dryRun || isDryRunRequest(c)
 	if webhook.ObjectAttributes.Action == "update" && webhook.ObjectAttributes.OldRev == "" {
 		logrus.WithFields(logrus.Fields{
 			"project_id": webhook.Project.ID,
 			"mr_iid":     webhook.ObjectAttributes.IID,
 		}).Info("MR update without new commits (e.g., label/assignee change), skipping review")
+
+		if dryRun {
+			c.JSON(http.StatusOK, gin.H{"message": "No new commits, review skipped"})
+			return
+		}
+		// Findings may have been resolved since, which can change the merge gate
+		h.enqueueGateEvaluation(&webhook)
 		c.JSON(http.StatusOK, gin.H{"message": "No new commits, review skipped"})
 		return
 	}
 
+	jobType := jobTypeMergeRequestReview
+	if dryRun {
+		jobType = jobTypeMergeRequestDryRun
+	}
 	logrus.WithFields(logrus.Fields{
 		"project_id": webhook.Project.ID,
 		"mr_iid":     webhook.ObjectAttributes.IID,
-	}).Info("Starting merge request processing")
-	go h.processMergeRequest(&webhook)
+		"dry_run":    dryRun,
+	}).Info("Queueing merge request review")
+	if _, err := h.queue.Enqueue(jobType, mergeRequestJobKey(webhook.Project.ID, webhook.ObjectAttributes.IID), &webhook); err != nil {
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"project_id": webhook.Project.ID,
+			"mr_iid":     webhook.ObjectAttributes.IID,
+		}).Error("Failed to queue merge request review")
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Failed to queue review"})
+		return
+	}
+	if !dryRun {
+		h.setReviewStatus(mergeRequestReviewRequest(&webhook), services.ReviewStatusPending, "Review queued", webhook.ObjectAttributes.URL)
+	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Webhook received"})
 }
 
-func (h *WebhookHandler) verifySignature(body []byte, signature string) bool {
-	if h.webhookSecret == "" {
-		return true
+func (h *WebhookHandler) handleNoteEvent(c *gin.Context, body []byte) {
+	var note models.NoteWebhook
+	if err := json.Unmarshal(body, &note); err != nil {
+		logrus.WithError(err).Error("Failed to parse note webhook payload")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to parse webhook"})
+		return
+	}
+
+	if note.ObjectAttributes.NoteableType != "MergeRequest" || note.ObjectAttributes.System {
+		logrus.WithField("noteable_type", note.ObjectAttributes.NoteableType).Debug("Ignoring note that is not a merge request comment")
+		c.JSON(http.StatusOK, gin.H{"message": "Note ignored"})
+		return
+	}
+
+	// Commands and follow-ups answer in the MR, so dry runs only cover merge request events
+	if h.dryRun || isDryRunRequest(c) {
+		logrus.WithFields(logrus.Fields{
+			"project_id": note.Project.ID,
+			"mr_iid":     note.MergeRequest.IID,
+		}).Info("Ignoring note in dry run mode")
+		c.JSON(http.StatusOK, gin.H{"message": "Note ignored in dry run mode"})
+		return
+	}
+
+	mentions := []string{botMention}
+	botUser, err := h.gitlabService.BotUser()
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to fetch bot user, only accepting the default mention")
+	} else {
+		// Never react to the bot's own replies
+		if note.User.ID == botUser.ID {
+			c.JSON(http.StatusOK, gin.H{"message": "Note ignored"})
+			return
+		}
+		mentions = append(mentions, "@"+botUser.Username)
+	}
+
+	command, ok := parseChatCommand(note.ObjectAttributes.Note, mentions)
+	if !ok {
+		// GitLab sends no event when a thread is resolved, so comments are the
+		// next chance to notice resolutions that change the merge gate
+		if note.MergeRequest.State == "opened" {
+			h.enqueueGateEvaluation(&models.GitLabWebhook{
+				ObjectKind:       "merge_request",
+				Project:          note.Project,
+				ObjectAttributes: note.MergeRequest,
+			})
+		}
+		if isThreadReply(&note) {
+			h.enqueueFollowUp(c, &note)
+			return
+		}
+		logrus.WithFields(logrus.Fields{
+			"project_id": note.Project.ID,
+			"mr_iid":     note.MergeRequest.IID,
+		}).Debug("Note does not mention the bot, ignoring")
+		c.JSON(http.StatusOK, gin.H{"message": "Note ignored"})
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"project_id": note.Project.ID,
+		"mr_iid":     note.MergeRequest.IID,
+		"command":    command.Name,
+		"user":       note.User.Username,
+	}).Info("Queueing chat command")
+	payload := &chatCommandJob{Note: note, Command: command}
+	if _, err := h.queue.Enqueue(jobTypeChatCommand, mergeRequestJobKey(note.Project.ID, note.MergeRequest.IID), payload); err != nil {
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"project_id": note.Project.ID,
+			"mr_iid":     note.MergeRequest.IID,
+		}).Error("Failed to queue chat command")
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Failed to queue command"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Command received"})
+}
+
+// enqueueGateEvaluation queues a re-evaluation of the merge gate from the
+// bot's open findings. Failures are logged only.
+func (h *WebhookHandler) enqueueGateEvaluation(webhook *models.GitLabWebhook) {
+	if _, err := h.queue.Enqueue(jobTypeGateEvaluation, mergeRequestJobKey(webhook.Project.ID, webhook.ObjectAttributes.IID), webhook); err != nil {
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"project_id": webhook.Project.ID,
+			"mr_iid":     webhook.ObjectAttributes.IID,
+		}).Warn("Failed to queue merge gate evaluation")
+	}
+}
+
+// enqueueFollowUp queues a reply inside a discussion; the job checks whether
+// the discussion is one of the bot's findings.
+func (h *WebhookHandler) enqueueFollowUp(c *gin.Context, note *models.NoteWebhook) {
+	logrus.WithFields(logrus.Fields{
+		"project_id":    note.Project.ID,
+		"mr_iid":        note.MergeRequest.IID,
+		"discussion_id": note.ObjectAttributes.DiscussionID,
+	}).Debug("Queueing follow-up on discussion reply")
+	if _, err := h.queue.Enqueue(jobTypeFollowUp, mergeRequestJobKey(note.Project.ID, note.MergeRequest.IID), note); err != nil {
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"project_id": note.Project.ID,
+			"mr_iid":     note.MergeRequest.IID,
+		}).Error("Failed to queue follow-up")
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Failed to queue follow-up"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Reply received"})
+}
+
+// mergeRequestJobKey serialises jobs for the same merge request so pushes are
+// reviewed in order and never concurrently.
+func mergeRequestJobKey(projectID, mrIID int) string {
+	return fmt.Sprintf("gitlab:%d:%d", projectID, mrIID)
+}
+
+func (h *WebhookHandler) handleReviewJob(ctx context.Context, job *queue.Job) error {
+	var webhook models.GitLabWebhook
+	if err := json.Unmarshal(job.Payload, &webhook); err != nil {
+		return fmt.Errorf("failed to decode review job payload: %w", err)
 	}
+	return h.processMergeRequest(ctx, &webhook, job.Attempts+1 >= job.MaxAttempts)
+}
 
-	mac := hmac.New(sha256.New, []byte(h.webhookSecret))
-	mac.Write(body)
-	expectedSignature := hex.EncodeToString(mac.Sum(nil))
+// reviewRequest describes a review run, triggered either by a merge request
+// event or by a chat-ops command.
+type reviewRequest struct {
+	ProjectID    int
+	MRIID        int
+	Title        string
+	Description  string
+	TargetBranch string
+	// HeadSHA is the commit the review status is reported on, WebURL the MR page
+	HeadSHA string
+	WebURL  string
+	// OldRev and NewRev are set for pushes so only the interdiff is reviewed
+	OldRev string
+	NewRev string
+	// Paths restricts the review to files matching these patterns
+	Paths []string
+	// Automatic reviews are skipped when the MR was opted out with "@whytho ignore"
+	Automatic bool
+	// DryRun, when set, records the review's writes instead of posting them
+	DryRun *services.DryRunArtifact
+}
+
+func (h *WebhookHandler) processMergeRequest(ctx context.Context, webhook *models.GitLabWebhook, lastAttempt bool) error {
+	_, err := h.runReview(ctx, mergeRequestReviewRequest(webhook), lastAttempt)
+	return err
+}
 
-	return hmac.Equal([]byte(signature), []byte(expectedSignature))
+// mergeRequestReviewRequest builds the automatic review of a merge request event.
+func mergeRequestReviewRequest(webhook *models.GitLabWebhook) reviewRequest {
+	req := reviewRequest{
+		ProjectID:    webhook.Project.ID,
+		MRIID:        webhook.ObjectAttributes.IID,
+		Title:        webhook.ObjectAttributes.Title,
+		Description:  webhook.ObjectAttributes.Description,
+		TargetBranch: webhook.ObjectAttributes.TargetBranch,
+		HeadSHA:      webhook.ObjectAttributes.LastCommit.ID,
+		WebURL:       webhook.ObjectAttributes.URL,
+		Automatic:    true,
+	}
+	if webhook.ObjectAttributes.Action == "update" {
+		req.OldRev = webhook.ObjectAttributes.OldRev
+		req.NewRev = webhook.ObjectAttributes.LastCommit.ID
+	}
+	return req
 }
 
-func (h *WebhookHandler) processMergeRequest(webhook *models.GitLabWebhook) {
-	projectID := webhook.Project.ID
-	mrIID := webhook.ObjectAttributes.IID
+// reviewMergeRequest runs a review and posts its results. It returns the
+// number of new comments that were posted.
+func (h *WebhookHandler) reviewMergeRequest(ctx context.Context, req reviewRequest) (int, error) {
+	projectID := req.ProjectID
+	mrIID := req.MRIID
 
 	logrus.WithFields(logrus.Fields{
 		"project_id": projectID,
@@ -133,7 +353,7 @@ func (h *WebhookHandler) processMergeRequest(webhook *models.GitLabWebhook) {
 			"project_id": projectID,
 			"mr_iid":     mrIID,
 		}).Error("Failed to fetch MR changes")
-		return
+		return 0, fmt.Errorf("failed to fetch MR changes: %w", err)
 	}
 
 	if len(changes) == 0 {
@@ -141,7 +361,7 @@ func (h *WebhookHandler) processMergeRequest(webhook *models.GitLabWebhook) {
 			"project_id": projectID,
 			"mr_iid":     mrIID,
 		}).Warn("No changes found in merge request")
-		return
+		return 0, nil
 	}
 
 	logrus.WithFields(logrus.Fields{
@@ -150,18 +370,117 @@ func (h *WebhookHandler) processMergeRequest(webhook *models.GitLabWebhook) {
 		"changes_count": len(changes),
 	}).Info("Retrieved merge request changes")
 
+	// Look up what the bot already posted so unchanged findings are not repeated
+	existing, err := h.gitlabService.ListBotDiscussions(projectID, mrIID)
+	if err != nil {
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"project_id": projectID,
+			"mr_iid":     mrIID,
+		}).Warn("Failed to fetch existing bot discussions, posting all comments")
+	}
+	posted := make(map[string]bool)
+	summaryNoteID := 0
+	var configNoteID int64
+	configFingerprint := ""
+	for _, discussion := range existing {
+		switch discussion.Kind {
+		case services.BotNoteKindSummary:
+			summaryNoteID = discussion.NoteID
+		case services.BotNoteKindConfig:
+			configNoteID, configFingerprint = int64(discussion.NoteID), discussion.Fingerprint
+		case services.BotNoteKindIgnore:
+			if req.Automatic {
+				logrus.WithFields(logrus.Fields{
+					"project_id": projectID,
+					"mr_iid":     mrIID,
+				}).Info("Automatic reviews are disabled for this merge request, skipping review")
+				return 0, nil
+			}
+		default:
+			posted[discussion.Fingerprint] = true
+		}
+	}
+
+	// Report invalid .whytho/ files modified by the MR where developers see them
+	var configProvider services.SCMProvider = h.gitlabService
+	if req.DryRun != nil {
+		configProvider = services.NewDryRunProvider(h.gitlabService, req.DryRun)
+	}
+	reportConfigProblems(configProvider, services.ReviewTarget{
+		Provider: configProvider,
+		Repo:     strconv.Itoa(projectID),
+		Number:   mrIID,
+		HeadRef:  req.HeadSHA,
+	}, changes, configNoteID, configFingerprint)
+
+	// On pushes to an existing MR only review the commits added since the last review
+	reviewChanges := changes
+	incremental := false
+	if h.incrementalReview && req.OldRev != "" && req.NewRev != "" {
+		interdiff, err := h.gitlabService.GetCompareChanges(projectID, req.OldRev, req.NewRev)
+		if err != nil {
+			logrus.WithError(err).WithFields(logrus.Fields{
+				"project_id": projectID,
+				"mr_iid":     mrIID,
+				"old_rev":    req.OldRev,
+				"new_rev":    req.NewRev,
+			}).Warn("Failed to fetch interdiff, falling back to full review")
+		} else {
+			reviewChanges = incrementalChanges(changes, interdiff)
+			incremental = true
+
+			logrus.WithFields(logrus.Fields{
+				"project_id":      projectID,
+				"mr_iid":          mrIID,
+				"old_rev":         req.OldRev,
+				"new_rev":         req.NewRev,
+				"interdiff_files": len(reviewChanges),
+			}).Info("Reviewing only changes pushed since the last review")
+		}
+	}
+
+	if len(req.Paths) > 0 {
+		reviewChanges = filterChangesByPaths(reviewChanges, req.Paths)
+		logrus.WithFields(logrus.Fields{
+			"project_id":     projectID,
+			"mr_iid":         mrIID,
+			"paths":          req.Paths,
+			"matching_files": len(reviewChanges),
+		}).Info("Restricting review to requested paths")
+	}
+
+	if len(reviewChanges) == 0 {
+		logrus.WithFields(logrus.Fields{
+			"project_id":  projectID,
+			"mr_iid":      mrIID,
+			"incremental": incremental,
+		}).Info("No changes left to review, skipping review")
+		return 0, nil
+	}
+
 	logrus.WithFields(logrus.Fields{
-		"project_id": projectID,
-		"mr_iid":     mrIID,
+		"project_id":  projectID,
+		"mr_iid":      mrIID,
+		"incremental": incremental,
 	}).Info("Starting code review")
 
-	review, err := h.reviewService.ReviewCode(changes, webhook.ObjectAttributes.Title, webhook.ObjectAttributes.Description, h.gitlabService, projectID, mrIID, webhook.ObjectAttributes.TargetBranch)
+	reviewService := h.reviewService
+	if req.DryRun != nil {
+		reviewService = reviewService.WithDryRun(req.DryRun)
+	}
+	review, err := reviewService.ReviewCode(ctx, reviewChanges, req.Title, req.Description, services.ReviewTarget{
+		Provider:     h.gitlabService,
+		Repo:         strconv.Itoa(projectID),
+		Number:       mrIID,
+		TargetBranch: req.TargetBranch,
+		HeadRef:      req.HeadSHA,
+	})
 	if err != nil {
 		logrus.WithError(err).WithFields(logrus.Fields{
 			"project_id": projectID,
 			"mr_iid":     mrIID,
 		}).Error("Failed to review code")
-		return
+		return 0, fmt.Errorf("failed to review code: %w", err)
 	}
 
 	logrus.WithFields(logrus.Fields{
@@ -171,8 +490,59 @@ func (h *WebhookHandler) processMergeRequest(webhook *models.GitLabWebhook) {
 		"positioned_comments_count": len(review.PositionedComments),
 	}).Info("Code review completed")
 
+	if incremental {
+		review.PositionedComments = h.remapIncrementalComments(review.PositionedComments, reviewChanges, changes)
+	}
+
+	h.resolveOutdatedFindings(req, existing, changes)
+
+	summaryComment := reviewSummaryComment(req, review, incremental)
+
+	postPositioned := h.gitlabService.PostPositionedMRComment
+	postGeneral := h.gitlabService.PostGeneralReviewComment
+	drafts := 0
+	switch {
+	case req.DryRun != nil:
+		postPositioned = func(_, _ int, comment models.PositionedComment) error {
+			req.DryRun.AddInlineComment(changes, comment)
+			return nil
+		}
+		postGeneral = func(_, _ int, comment string) error {
+			req.DryRun.AddNote(services.DryRunNoteGeneral, "", services.GeneralCommentBody(comment))
+			return nil
+		}
+	case h.draftReviews:
+		// Create everything as draft notes and publish them in one go at the
+		// end, so watchers get a single notification for the whole review
+		postPositioned = h.gitlabService.CreateDraftPositionedNote
+		postGeneral = h.gitlabService.CreateDraftGeneralReviewNote
+		if err := h.gitlabService.DiscardDraftNotes(projectID, mrIID); err != nil {
+			logrus.WithError(err).WithFields(logrus.Fields{
+				"project_id": projectID,
+				"mr_iid":     mrIID,
+			}).Warn("Failed to discard stale draft notes")
+		}
+		// A new summary leads the review as a draft; an existing one is only
+		// edited once the findings it describes are published
+		if summaryComment != "" && summaryNoteID == 0 && h.postReviewSummary(projectID, mrIID, summaryComment, req.HeadSHA, 0) {
+			drafts++
+		}
+	}
+
+	newComments := 0
+
 	// Post positioned comments first
 	for i, posComment := range review.PositionedComments {
+		if fingerprint := services.FindingFingerprint(posComment); posted[fingerprint] {
+			logrus.WithFields(logrus.Fields{
+				"project_id":  projectID,
+				"mr_iid":      mrIID,
+				"file_path":   posComment.FilePath,
+				"fingerprint": fingerprint,
+			}).Debug("Skipping positioned comment that was already posted")
+			continue
+		}
+
 		logrus.WithFields(logrus.Fields{
 			"project_id":                projectID,
 			"mr_iid":                    mrIID,
@@ -182,7 +552,7 @@ func (h *WebhookHandler) processMergeRequest(webhook *models.GitLabWebhook) {
 			"line_number":               posComment.LineNumber,
 		}).Debug("Posting positioned review comment")
 
-		if err := h.gitlabService.PostPositionedMRComment(projectID, mrIID, posComment); err != nil {
+		if err := postPositioned(projectID, mrIID, posComment); err != nil {
 			logrus.WithError(err).WithFields(logrus.Fields{
 				"project_id":    projectID,
 				"mr_iid":        mrIID,
@@ -191,6 +561,7 @@ func (h *WebhookHandler) processMergeRequest(webhook *models.GitLabWebhook) {
 				"line_number":   posComment.LineNumber,
 			}).Error("Failed to post positioned review comment")
 		} else {
+			newComments++
 			logrus.WithFields(logrus.Fields{
 				"project_id":    projectID,
 				"mr_iid":        mrIID,
@@ -203,6 +574,15 @@ func (h *WebhookHandler) processMergeRequest(webhook *models.GitLabWebhook) {
 
 	// Post general comments
 	for i, comment := range review.Comments {
+		if fingerprint := services.CommentFingerprint(comment); posted[fingerprint] {
+			logrus.WithFields(logrus.Fields{
+				"project_id":  projectID,
+				"mr_iid":      mrIID,
+				"fingerprint": fingerprint,
+			}).Debug("Skipping general comment that was already posted")
+			continue
+		}
+
 		logrus.WithFields(logrus.Fields{
 			"project_id":             projectID,
 			"mr_iid":                 mrIID,
@@ -210,13 +590,14 @@ func (h *WebhookHandler) processMergeRequest(webhook *models.GitLabWebhook) {
 			"total_general_comments": len(review.Comments),
 		}).Debug("Posting general review comment")
 
-		if err := h.gitlabService.PostMRComment(projectID, mrIID, comment); err != nil {
+		if err := postGeneral(projectID, mrIID, comment); err != nil {
 			logrus.WithError(err).WithFields(logrus.Fields{
 				"project_id":    projectID,
 				"mr_iid":        mrIID,
 				"comment_index": i + 1,
 			}).Error("Failed to post general review comment")
 		} else {
+			newComments++
 			logrus.WithFields(logrus.Fields{
 				"project_id":    projectID,
 				"mr_iid":        mrIID,
@@ -225,30 +606,196 @@ func (h *WebhookHandler) processMergeRequest(webhook *models.GitLabWebhook) {
 		}
 	}
 
-	if review.Summary != "" {
-		logrus.WithFields(logrus.Fields{
-			"project_id": projectID,
-			"mr_iid":     mrIID,
-		}).Info("Posting review summary")
-
-		summaryComment := fmt.Sprintf("## 🤖 AI Code Review Summary\n\n%s", review.Summary)
-		if err := h.gitlabService.PostMRComment(projectID, mrIID, summaryComment); err != nil {
-			logrus.WithError(err).WithFields(logrus.Fields{
-				"project_id": projectID,
-				"mr_iid":     mrIID,
-			}).Error("Failed to post summary comment")
-		} else {
+	switch {
+	case req.DryRun != nil:
+		if summaryComment != "" {
+			kind, target := services.DryRunNoteGeneral, ""
+			if summaryNoteID != 0 {
+				kind, target = services.DryRunNoteUpdate, strconv.Itoa(summaryNoteID)
+			}
+			req.DryRun.AddNote(kind, target, services.SummaryCommentBody(summaryComment, req.HeadSHA))
+		}
+	case h.draftReviews:
+		drafts += newComments
+		if drafts > 0 {
+			if err := h.gitlabService.PublishDraftNotes(projectID, mrIID); err != nil {
+				return 0, err
+			}
 			logrus.WithFields(logrus.Fields{
 				"project_id": projectID,
 				"mr_iid":     mrIID,
-			}).Info("Summary comment posted successfully")
+				"drafts":     drafts,
+			}).Info("Review published")
+		}
+		if summaryComment != "" && summaryNoteID != 0 {
+			h.postReviewSummary(projectID, mrIID, summaryComment, req.HeadSHA, summaryNoteID)
 		}
+	case summaryComment != "":
+		h.postReviewSummary(projectID, mrIID, summaryComment, req.HeadSHA, summaryNoteID)
 	}
 
 	logrus.WithFields(logrus.Fields{
 		"project_id": projectID,
 		"mr_iid":     mrIID,
 	}).Info("Merge request processing completed")
+	return newComments, nil
+}
+
+// incrementalChanges restricts the interdiff to files that are part of the
+// merge request, so changes pulled in from the target branch by a rebase are
+// not reviewed.
+func incrementalChanges(mrChanges, interdiff []models.MRChange) []models.MRChange {
+	mrFiles := make(map[string]models.MRChange)
+	for _, change := range mrChanges {
+		if !change.DeletedFile {
+			mrFiles[change.NewPath] = change
+		}
+	}
+
+	var result []models.MRChange
+	for _, change := range interdiff {
+		mrChange, ok := mrFiles[change.NewPath]
+		if !ok || change.DeletedFile {
+			continue
+		}
+		change.NewFile = mrChange.NewFile
+		change.RenamedFile = mrChange.RenamedFile
+		change.OldPath = mrChange.OldPath
+		result = append(result, change)
+	}
+	return result
+}
+
+// remapIncrementalComments keeps only comments on lines introduced by the new
+// commits and renumbers them against the full MR diff for posting.
+func (h *WebhookHandler) remapIncrementalComments(comments []models.PositionedComment, interdiff, mrChanges []models.MRChange) []models.PositionedComment {
+	var result []models.PositionedComment
+	for _, comment := range comments {
+		remapped, ok := h.gitlabService.RemapToMRDiff(comment, interdiff, mrChanges)
+		if !ok {
+			logrus.WithFields(logrus.Fields{
+				"file_path":   comment.FilePath,
+				"line_number": comment.LineNumber,
+				"line_type":   comment.LineType,
+			}).Debug("Dropping incremental comment that is not on a newly added line")
+			continue
+		}
+		result = append(result, remapped)
+	}
+	return result
+}
+
+// resolveOutdatedFindings resolves open bot findings whose anchor line no
+// longer exists in the MR diff, or whose file was deleted or dropped from the
+// MR. A finding the model did not raise again is left open, since LLM output
+// varies between runs and only a human should dismiss it.
+func (h *WebhookHandler) resolveOutdatedFindings(req reviewRequest, existing []models.BotDiscussion, mrChanges []models.MRChange) {
+	projectID, mrIID := req.ProjectID, req.MRIID
+	anchors := make(map[string]map[string]bool)
+	deleted := make(map[string]bool)
+	for _, change := range mrChanges {
+		if change.DeletedFile {
+			deleted[change.OldPath] = true
+			continue
+		}
+		anchors[change.NewPath] = services.AnchorHashes(change.Diff)
+	}
+	for _, discussion := range existing {
+		if discussion.Kind != services.BotNoteKindFinding || !discussion.Resolvable || discussion.Resolved {
+			continue
+		}
+
+		var reason string
+		fileAnchors, inMR := anchors[discussion.FilePath]
+		switch {
+		case discussion.FilePath == "":
+			continue
+		case deleted[discussion.FilePath]:
+			reason = "This file was deleted."
+		case !inMR:
+			reason = "This file is no longer part of the merge request."
+		case !fileAnchors[discussion.AnchorHash]:
+			reason = "The code this comment refers to has changed."
+		default:
+			continue
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"project_id":    projectID,
+			"mr_iid":        mrIID,
+			"discussion_id": discussion.DiscussionID,
+			"file_path":     discussion.FilePath,
+			"reason":        reason,
+		}).Info("Resolving outdated bot finding")
+
+		if req.DryRun != nil {
+			req.DryRun.AddNote(services.DryRunNoteResolve, discussion.DiscussionID, "🤖 Resolved automatically: "+reason)
+			continue
+		}
+		if err := h.gitlabService.ResolveDiscussion(projectID, mrIID, discussion.DiscussionID, "🤖 Resolved automatically: "+reason); err != nil {
+			logrus.WithError(err).WithFields(logrus.Fields{
+				"project_id":    projectID,
+				"mr_iid":        mrIID,
+				"discussion_id": discussion.DiscussionID,
+			}).Error("Failed to resolve outdated bot finding")
+		}
+	}
+}
+
+// reviewSummaryComment renders the summary note of a review, or "" when the
+// review has no summary.
+func reviewSummaryComment(req reviewRequest, review *models.CodeReview, incremental bool) string {
+	if review.Summary == "" {
+		return ""
+	}
+	if len(req.Paths) > 0 {
+		return fmt.Sprintf("## 🤖 AI Code Review Summary\n\n_Review limited to files matching `%s`._\n\n%s",
+			strings.Join(req.Paths, "`, `"), review.Summary)
+	}
+	if incremental {
+		return fmt.Sprintf("## 🤖 AI Code Review Summary\n\n_Incremental review of the commits pushed since `%s`._\n\n%s",
+			shortSHA(req.OldRev), review.Summary)
+	}
+	return fmt.Sprintf("## 🤖 AI Code Review Summary\n\n%s", review.Summary)
+}
+
+// postReviewSummary updates the existing summary note, or creates a new one
+// (as a draft in draft review mode), recording the reviewed head commit. It
+// reports whether that succeeded.
+func (h *WebhookHandler) postReviewSummary(projectID, mrIID int, summaryComment, headSHA string, summaryNoteID int) bool {
+	logrus.WithFields(logrus.Fields{
+		"project_id": projectID,
+		"mr_iid":     mrIID,
+		"update":     summaryNoteID != 0,
+		"draft":      h.draftReviews && summaryNoteID == 0,
+	}).Info("Posting review summary")
+
+	var err error
+	if h.draftReviews && summaryNoteID == 0 {
+		err = h.gitlabService.CreateDraftSummaryNote(projectID, mrIID, summaryComment, headSHA)
+	} else {
+		err = h.gitlabService.UpsertSummaryNote(projectID, mrIID, summaryComment, headSHA, summaryNoteID)
+	}
+	if err != nil {
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"project_id": projectID,
+			"mr_iid":     mrIID,
+		}).Error("Failed to post summary comment")
+		return false
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"project_id": projectID,
+		"mr_iid":     mrIID,
+	}).Info("Summary comment posted successfully")
+	return true
+}
+
+func shortSHA(sha string) string {
+	if len(sha) > 8 {
+		return sha[:8]
+	}
+	return sha
 }
 
 func HealthCheck(c *gin.Context) {
@@ -1,5 +1,7 @@
 package models
 
+import "gopkg.in/yaml.v3"
+
 type GitLabWebhook struct {
 	ObjectKind       string           `json:"object_kind"`
 	EventType        string           `json:"event_type"`
@@ -58,6 +60,36 @@ type ObjectAttributes struct {
 	OldRev          string `json:"oldrev,omitempty"` // Present when commits are pushed
 }
 
+// NoteWebhook is the payload of a GitLab "Note Hook" event.
+type NoteWebhook struct {
+	ObjectKind       string           `json:"object_kind"`
+	EventType        string           `json:"event_type"`
+	User             User             `json:"user"`
+	Project          Project          `json:"project"`
+	ObjectAttributes NoteAttributes   `json:"object_attributes"`
+	MergeRequest     ObjectAttributes `json:"merge_request"` // Only present for notes on merge requests
+}
+
+type NoteAttributes struct {
+	ID           int           `json:"id"`
+	Note         string        `json:"note"`
+	NoteableType string        `json:"noteable_type"` // "MergeRequest", "Issue", "Commit" or "Snippet"
+	AuthorID     int           `json:"author_id"`
+	System       bool          `json:"system"`
+	DiscussionID string        `json:"discussion_id"`
+	Type         string        `json:"type"` // "DiffNote" for comments on a diff line
+	Position     *NotePosition `json:"position"`
+	URL          string        `json:"url"`
+}
+
+// NotePosition locates a diff note. Lines are 0 when the side does not apply.
+type NotePosition struct {
+	OldPath string `json:"old_path"`
+	NewPath string `json:"new_path"`
+	OldLine int    `json:"old_line"`
+	NewLine int    `json:"new_line"`
+}
+
 type Source struct {
 	Name            string `json:"name"`
 	Description     string `json:"description"`
@@ -132,9 +164,36 @@ type PositionedComment struct {
 	LineNumber   int    `json:"line_number"`
 	LineType     string `json:"line_type"` // "old", "new", or "context"
 	Severity     string `json:"severity"`   // "LOW", "MEDIUM", "HIGH", or "CRITICAL"
+	Rule         string `json:"rule"`       // Short kebab-case identifier of the issue kind
 	Comment      string `json:"comment"`
 	OriginalLine string `json:"original_line"`
 	LineCode     string `json:"line_code"` // GitLab's line code for positioning
+
+	// StartLineNumber is the DIFF_LINE a multi-line comment starts at, LineNumber
+	// being its last line. 0 for single line comments
+	StartLineNumber int `json:"start_line_number,omitempty"`
+	// The start of a range is resolved from the MR diff before posting, like OldLineNumber and NewLineNumber
+	StartLineType      string `json:"-"`
+	StartOldLineNumber int    `json:"-"`
+	StartNewLineNumber int    `json:"-"`
+	StartLineCode      string `json:"-"`
+
+	// OldPath, OldLineNumber and NewLineNumber are resolved from the MR diff
+	// before posting. Context lines have both line numbers, which differ once
+	// lines were added or removed above them
+	OldPath       string `json:"old_path,omitempty"`
+	OldLineNumber int    `json:"old_line_number,omitempty"`
+	NewLineNumber int    `json:"new_line_number,omitempty"`
+
+	// Suggestion replaces the DIFF_LINE range SuggestionStartLine..SuggestionEndLine
+	// (defaulting to the commented line) and is rendered as a GitLab suggestion
+	Suggestion          string `json:"suggestion,omitempty"`
+	SuggestionStartLine int    `json:"suggestion_start_line,omitempty"`
+	SuggestionEndLine   int    `json:"suggestion_end_line,omitempty"`
+	// SuggestionLinesAbove and SuggestionLinesBelow are the validated range
+	// relative to the commented line, as used by ```suggestion:-N+M
+	SuggestionLinesAbove int `json:"suggestion_lines_above,omitempty"`
+	SuggestionLinesBelow int `json:"suggestion_lines_below,omitempty"`
 }
 
 type DiffLine struct {
@@ -143,8 +202,113 @@ type DiffLine struct {
 	OldLineNum int    `json:"old_line_num"` // Line number in old file (0 if new line)
 	NewLineNum int    `json:"new_line_num"` // Line number in new file (0 if deleted line)
 	Position   int    `json:"position"`     // Position in diff for GitLab API
+	NoNewline  bool   `json:"no_newline"`   // Followed by "\ No newline at end of file"
+}
+
+// BotDiscussion is a discussion started by the bot, identified by the hidden
+// marker embedded in its first note.
+type BotDiscussion struct {
+	DiscussionID string
+	NoteID       int
+	Kind         string // "finding", "comment", "summary", "ignore" or "config"
+	Fingerprint  string
+	AnchorHash   string
+	Severity     string
+	HeadSHA      string // Head commit a summary was written for
+	FilePath     string
+	Resolvable   bool
+	Resolved     bool
+	ResolvedByID int
 }
 
+// BotThread is a discussion started by the bot together with all its notes,
+// used to answer developers who reply to a finding.
+type BotThread struct {
+	Discussion BotDiscussion
+	// Position of the first note, nil for findings posted as general notes
+	Position *NotePosition
+	Notes    []ThreadNote
+}
+
+type ThreadNote struct {
+	Author  string
+	Body    string // Note body with bot markers stripped
+	FromBot bool
+}
+
+// FollowUpAnswer is the LLM's response to a reply on one of its findings.
+type FollowUpAnswer struct {
+	Reply string `json:"reply"`
+	// Resolve is set when the model concedes the finding is not valid or has been addressed
+	Resolve bool `json:"resolve"`
+}
+
+// WhyThoConfig is a repository's .whytho/config.yaml. Paths are
+// gitignore-style patterns, see services.MatchesPathPatterns.
 type WhyThoConfig struct {
+	// IncludePaths, when set, limits reviews to the matching files
+	IncludePaths []string `yaml:"includePaths"`
 	ExcludePaths []string `yaml:"excludePaths"`
+	// Overrides adjust the review of the files matching their paths, later entries winning
+	Overrides []PathOverride `yaml:"overrides"`
+	// Guidance maps path patterns to the guidance files applied to the matching files
+	Guidance map[string]GuidanceFiles `yaml:"guidance"`
+	Gating   *GatingPolicy            `yaml:"gating"`
+}
+
+// GuidanceFiles is a guidance file path, or a list of them.
+type GuidanceFiles []string
+
+func (g *GuidanceFiles) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*g = GuidanceFiles{value.Value}
+		return nil
+	}
+	var files []string
+	if err := value.Decode(&files); err != nil {
+		return err
+	}
+	*g = files
+	return nil
+}
+
+// PathOverride changes how the files matching Paths are reviewed. Unset
+// fields leave the setting unchanged.
+type PathOverride struct {
+	Paths []string `yaml:"paths"`
+	// Exclude excludes (true) or re-includes (false) the files
+	Exclude *bool `yaml:"exclude"`
+	// MinSeverity drops findings below this severity
+	MinSeverity string `yaml:"minSeverity"`
+	// Suggestions set to false posts findings without suggested changes
+	Suggestions *bool `yaml:"suggestions"`
+}
+
+// GatingPolicy decides, from the severities of the bot's open findings,
+// whether the review status fails and whether the bot approves the MR.
+type GatingPolicy struct {
+	// Block fails the review status while findings of these severities are open
+	Block []string `yaml:"block"`
+	// RequireResolution withholds the bot's approval while findings of these severities are open
+	RequireResolution []string `yaml:"requireResolution"`
+	// Approve lets the bot approve the MR when nothing blocks or awaits resolution
+	Approve bool `yaml:"approve"`
+}
+
+// PullRequest is the provider neutral view of a pull or merge request.
+type PullRequest struct {
+	Title        string
+	Description  string
+	TargetBranch string
+	HeadSHA      string
+	WebURL       string
+	Open         bool
+}
+
+// SCMComment is a comment on a pull request, general or inline.
+type SCMComment struct {
+	ID   int64
+	Body string
+	// Author is the username of the comment's author
+	Author string
 }
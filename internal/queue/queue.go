@@ -0,0 +1,416 @@
+package queue
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultBaseBackoff = 30 * time.Second
+	defaultMaxBackoff  = 10 * time.Minute
+	// defaultCancelGrace bounds how long Shutdown waits for cancelled jobs to return
+	defaultCancelGrace = 5 * time.Second
+)
+
+type Job struct {
+	ID          string          `json:"id"`
+	Type        string          `json:"type"`
+	Key         string          `json:"key"` // Jobs sharing a key never run concurrently
+	Payload     json.RawMessage `json:"payload"`
+	Attempts    int             `json:"attempts"`
+	MaxAttempts int             `json:"max_attempts"`
+	NextRunAt   time.Time       `json:"next_run_at"`
+	CreatedAt   time.Time       `json:"created_at"`
+	LastError   string          `json:"last_error,omitempty"`
+}
+
+type HandlerFunc func(ctx context.Context, job *Job) error
+
+// Queue runs jobs on a bounded worker pool, retrying failures with
+// exponential backoff. Jobs are written through to the Store so a durable
+// store lets pending work survive restarts.
+type Queue struct {
+	store       Store
+	workers     int
+	maxAttempts int
+	baseBackoff time.Duration
+	maxBackoff  time.Duration
+	cancelGrace time.Duration
+
+	mu       sync.Mutex
+	handlers map[string]HandlerFunc
+	pending  []*Job
+	running  map[string]bool
+	closing  bool
+	notify   chan struct{}
+	stopping chan struct{}
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func New(store Store, workers, maxAttempts int) *Queue {
+	if workers < 1 {
+		workers = 1
+	}
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Queue{
+		store:       store,
+		workers:     workers,
+		maxAttempts: maxAttempts,
+		baseBackoff: defaultBaseBackoff,
+		maxBackoff:  defaultMaxBackoff,
+		cancelGrace: defaultCancelGrace,
+		handlers:    make(map[string]HandlerFunc),
+		running:     make(map[string]bool),
+		notify:      make(chan struct{}, 1),
+		stopping:    make(chan struct{}),
+		ctx:         ctx,
+		cancel:      cancel,
+	}
+}
+
+func (q *Queue) Register(jobType string, handler HandlerFunc) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.handlers[jobType] = handler
+}
+
+// Start restores persisted jobs and launches the worker pool.
+func (q *Queue) Start() error {
+	jobs, err := q.store.List()
+	if err != nil {
+		return fmt.Errorf("failed to load persisted jobs: %w", err)
+	}
+
+	q.mu.Lock()
+	// Jobs enqueued before Start are already pending as well as stored
+	queued := make(map[string]bool, len(q.pending))
+	for _, job := range q.pending {
+		queued[job.ID] = true
+	}
+	restored := 0
+	for _, job := range jobs {
+		if !queued[job.ID] {
+			q.pending = append(q.pending, job)
+			restored++
+		}
+	}
+	q.sortPending()
+	q.mu.Unlock()
+
+	logrus.WithFields(logrus.Fields{
+		"workers":       q.workers,
+		"max_attempts":  q.maxAttempts,
+		"restored_jobs": restored,
+		"durable_store": q.store.Durable(),
+	}).Info("Starting job queue")
+
+	for i := 0; i < q.workers; i++ {
+		q.wg.Add(1)
+		go q.worker(i + 1)
+	}
+	q.wake()
+	return nil
+}
+
+// Enqueue schedules a job of the given type for immediate execution.
+func (q *Queue) Enqueue(jobType, key string, payload interface{}) (*Job, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode job payload: %w", err)
+	}
+
+	now := time.Now()
+	job := &Job{
+		ID:          newJobID(),
+		Type:        jobType,
+		Key:         key,
+		Payload:     data,
+		MaxAttempts: q.maxAttempts,
+		NextRunAt:   now,
+		CreatedAt:   now,
+	}
+
+	q.mu.Lock()
+	if q.closing {
+		q.mu.Unlock()
+		return nil, fmt.Errorf("queue is shutting down")
+	}
+	if err := q.store.Put(job); err != nil {
+		q.mu.Unlock()
+		return nil, fmt.Errorf("failed to persist job: %w", err)
+	}
+	q.pending = append(q.pending, job)
+	q.sortPending()
+	depth := len(q.pending)
+	q.mu.Unlock()
+
+	logrus.WithFields(logrus.Fields{
+		"job_id":      job.ID,
+		"job_type":    job.Type,
+		"job_key":     job.Key,
+		"queue_depth": depth,
+	}).Info("Job enqueued")
+
+	q.wake()
+	return job, nil
+}
+
+// Shutdown stops accepting new jobs and waits for the workers to finish.
+// With a durable store only in-flight jobs are completed and pending jobs
+// are left for the next start; with the in-memory store pending jobs are
+// drained as well. When ctx expires, the context passed to running jobs is
+// cancelled and Shutdown returns ctx.Err() after a short grace period, without
+// waiting for jobs that ignore the cancellation. Interrupted jobs stay in a
+// durable store and run again on the next start.
+func (q *Queue) Shutdown(ctx context.Context) error {
+	q.mu.Lock()
+	if !q.closing {
+		q.closing = true
+		close(q.stopping)
+	}
+	remaining := len(q.pending)
+	q.mu.Unlock()
+
+	logrus.WithFields(logrus.Fields{
+		"pending_jobs":  remaining,
+		"durable_store": q.store.Durable(),
+	}).Info("Draining job queue")
+
+	done := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+
+	var err error
+	select {
+	case <-done:
+		q.mu.Lock()
+		remaining = len(q.pending)
+		q.mu.Unlock()
+		if remaining > 0 && !q.store.Durable() {
+			logrus.WithField("pending_jobs", remaining).Warn("Job queue stopped with pending retries that will be lost")
+		} else {
+			logrus.WithField("pending_jobs", remaining).Info("Job queue drained")
+		}
+	case <-ctx.Done():
+		logrus.Warn("Job queue drain timed out, cancelling running jobs")
+		q.cancel()
+		grace := time.NewTimer(q.cancelGrace)
+		select {
+		case <-done:
+		case <-grace.C:
+			logrus.Warn("Running jobs did not stop after cancellation, abandoning them")
+		}
+		grace.Stop()
+		err = ctx.Err()
+	}
+
+	q.cancel()
+	if closeErr := q.store.Close(); closeErr != nil && err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func (q *Queue) worker(id int) {
+	defer q.wg.Done()
+
+	for {
+		job, wait, stop := q.next()
+		if stop {
+			logrus.WithField("worker", id).Debug("Queue worker stopped")
+			return
+		}
+		if job == nil {
+			q.sleep(wait)
+			continue
+		}
+
+		// Let another idle worker look at the rest of the queue
+		q.wake()
+		q.run(id, job)
+	}
+}
+
+// next picks the first due job whose key is not already running and that is
+// the oldest job for its key. When nothing is runnable it returns how long to
+// wait before checking again.
+func (q *Queue) next() (*Job, time.Duration, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.ctx.Err() != nil {
+		return nil, 0, true
+	}
+	if q.closing && (q.store.Durable() || len(q.pending) == 0) {
+		return nil, 0, true
+	}
+
+	// Jobs sharing a key run in creation order, so a retry is never overtaken
+	oldest := make(map[string]*Job)
+	for _, job := range q.pending {
+		if first, ok := oldest[job.Key]; job.Key != "" && (!ok || job.CreatedAt.Before(first.CreatedAt)) {
+			oldest[job.Key] = job
+		}
+	}
+
+	now := time.Now()
+	wait := time.Minute
+	for i, job := range q.pending {
+		if job.Key != "" && (q.running[job.Key] || oldest[job.Key] != job) {
+			continue
+		}
+		if job.NextRunAt.After(now) {
+			if until := job.NextRunAt.Sub(now); until < wait {
+				wait = until
+			}
+			continue
+		}
+
+		q.pending = append(q.pending[:i], q.pending[i+1:]...)
+		if job.Key != "" {
+			q.running[job.Key] = true
+		}
+		return job, 0, false
+	}
+
+	// While shutting down, idle workers exit instead of waiting for retries
+	if q.closing {
+		return nil, 0, true
+	}
+	return nil, wait, false
+}
+
+func (q *Queue) run(workerID int, job *Job) {
+	fields := logrus.Fields{
+		"worker":   workerID,
+		"job_id":   job.ID,
+		"job_type": job.Type,
+		"job_key":  job.Key,
+		"attempt":  job.Attempts + 1,
+	}
+
+	q.mu.Lock()
+	handler, ok := q.handlers[job.Type]
+	q.mu.Unlock()
+
+	var err error
+	if !ok {
+		err = fmt.Errorf("no handler registered for job type %q", job.Type)
+	} else {
+		logrus.WithFields(fields).Info("Running job")
+		err = q.safeRun(handler, job)
+	}
+
+	q.mu.Lock()
+	defer func() {
+		if job.Key != "" {
+			delete(q.running, job.Key)
+		}
+		q.mu.Unlock()
+		q.wake()
+	}()
+
+	if err == nil {
+		logrus.WithFields(fields).Info("Job completed")
+		if delErr := q.store.Delete(job.ID); delErr != nil {
+			logrus.WithError(delErr).WithFields(fields).Error("Failed to remove completed job from store")
+		}
+		return
+	}
+
+	// Jobs interrupted by shutdown stay in the store and run again on the next start
+	if q.ctx.Err() != nil {
+		logrus.WithError(err).WithFields(fields).Warn("Job interrupted by shutdown")
+		return
+	}
+
+	job.Attempts++
+	job.LastError = err.Error()
+	if job.Attempts >= job.MaxAttempts {
+		logrus.WithError(err).WithFields(fields).Error("Job failed permanently, giving up")
+		if delErr := q.store.Delete(job.ID); delErr != nil {
+			logrus.WithError(delErr).WithFields(fields).Error("Failed to remove failed job from store")
+		}
+		return
+	}
+
+	backoff := q.backoff(job.Attempts)
+	job.NextRunAt = time.Now().Add(backoff)
+	logrus.WithError(err).WithFields(fields).WithField("retry_in", backoff.String()).Warn("Job failed, scheduling retry")
+
+	if putErr := q.store.Put(job); putErr != nil {
+		logrus.WithError(putErr).WithFields(fields).Error("Failed to persist job retry")
+	}
+	q.pending = append(q.pending, job)
+	q.sortPending()
+}
+
+func (q *Queue) safeRun(handler HandlerFunc, job *Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+	return handler(q.ctx, job)
+}
+
+func (q *Queue) backoff(attempts int) time.Duration {
+	backoff := q.baseBackoff
+	for i := 1; i < attempts; i++ {
+		backoff *= 2
+		if backoff >= q.maxBackoff {
+			return q.maxBackoff
+		}
+	}
+	return backoff
+}
+
+func (q *Queue) sleep(wait time.Duration) {
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-q.notify:
+	case <-timer.C:
+	case <-q.stopping:
+	case <-q.ctx.Done():
+	}
+}
+
+func (q *Queue) wake() {
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (q *Queue) sortPending() {
+	sort.SliceStable(q.pending, func(i, j int) bool {
+		return q.pending[i].NextRunAt.Before(q.pending[j].NextRunAt)
+	})
+}
+
+func newJobID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
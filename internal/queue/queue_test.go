@@ -0,0 +1,283 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const testJobType = "test"
+
+// newTestQueue returns a queue whose retries are due almost immediately.
+func newTestQueue(store Store, workers, maxAttempts int) *Queue {
+	q := New(store, workers, maxAttempts)
+	q.baseBackoff = time.Millisecond
+	q.maxBackoff = 5 * time.Millisecond
+	return q
+}
+
+func waitFor(t *testing.T, done <-chan struct{}, what string) {
+	t.Helper()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for %s", what)
+	}
+}
+
+func shutdown(t *testing.T, q *Queue) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := q.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+}
+
+func TestQueueRetriesFailedJobs(t *testing.T) {
+	store := NewMemoryStore()
+	q := newTestQueue(store, 1, 3)
+
+	var attempts []int
+	done := make(chan struct{})
+	q.Register(testJobType, func(ctx context.Context, job *Job) error {
+		attempts = append(attempts, job.Attempts)
+		if job.Attempts < 2 {
+			return errors.New("temporary failure")
+		}
+		close(done)
+		return nil
+	})
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.Enqueue(testJobType, "", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	waitFor(t, done, "the third attempt")
+	shutdown(t, q)
+
+	if len(attempts) != 3 || attempts[0] != 0 || attempts[1] != 1 || attempts[2] != 2 {
+		t.Errorf("attempts = %v, want [0 1 2]", attempts)
+	}
+	if jobs, _ := store.List(); len(jobs) != 0 {
+		t.Errorf("store still holds %d job(s) after success", len(jobs))
+	}
+}
+
+func TestQueueGivesUpAfterMaxAttempts(t *testing.T) {
+	store := NewMemoryStore()
+	q := newTestQueue(store, 1, 2)
+
+	var calls atomic.Int32
+	done := make(chan struct{})
+	q.Register(testJobType, func(ctx context.Context, job *Job) error {
+		if calls.Add(1) == 2 {
+			defer close(done)
+		}
+		return errors.New("permanent failure")
+	})
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.Enqueue(testJobType, "", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	waitFor(t, done, "the last attempt")
+	shutdown(t, q)
+
+	if got := calls.Load(); got != 2 {
+		t.Errorf("handler called %d times, want 2", got)
+	}
+	if jobs, _ := store.List(); len(jobs) != 0 {
+		t.Errorf("store still holds %d job(s) after giving up", len(jobs))
+	}
+}
+
+func TestQueueBackoff(t *testing.T) {
+	q := New(NewMemoryStore(), 1, 1)
+
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{5, 8 * time.Minute},
+		{6, 10 * time.Minute},
+		{20, 10 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got := q.backoff(tt.attempts); got != tt.want {
+			t.Errorf("backoff(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestQueueRunsJobsSharingKeyInOrder(t *testing.T) {
+	q := newTestQueue(NewMemoryStore(), 4, 1)
+
+	var (
+		mu      sync.Mutex
+		order   []int
+		running atomic.Int32
+		overlap atomic.Bool
+		wg      sync.WaitGroup
+	)
+	q.Register(testJobType, func(ctx context.Context, job *Job) error {
+		defer wg.Done()
+		if running.Add(1) > 1 {
+			overlap.Store(true)
+		}
+		defer running.Add(-1)
+
+		var n int
+		if err := json.Unmarshal(job.Payload, &n); err != nil {
+			return err
+		}
+		time.Sleep(5 * time.Millisecond)
+		mu.Lock()
+		order = append(order, n)
+		mu.Unlock()
+		return nil
+	})
+
+	// Enqueue before starting so every worker competes for the same key
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		if _, err := q.Enqueue(testJobType, "gitlab:1:1", i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	waitFor(t, done, "the jobs to run")
+	shutdown(t, q)
+
+	if overlap.Load() {
+		t.Error("jobs sharing a key ran concurrently")
+	}
+	for i, n := range order {
+		if n != i {
+			t.Fatalf("jobs ran in order %v, want creation order", order)
+		}
+	}
+}
+
+func TestQueueRestoresJobsFromBoltStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jobs.db")
+
+	store, err := NewBoltStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := newTestQueue(store, 1, 3)
+	if _, err := q.Enqueue(testJobType, "gitlab:1:1", "payload"); err != nil {
+		t.Fatal(err)
+	}
+	// The queue was never started, so the job is left pending in the store
+	shutdown(t, q)
+
+	store, err = NewBoltStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q = newTestQueue(store, 1, 3)
+	payloads := make(chan string, 1)
+	q.Register(testJobType, func(ctx context.Context, job *Job) error {
+		var payload string
+		if err := json.Unmarshal(job.Payload, &payload); err != nil {
+			return err
+		}
+		payloads <- payload
+		return nil
+	})
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case payload := <-payloads:
+		if payload != "payload" {
+			t.Errorf("restored payload = %q, want %q", payload, "payload")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("restored job did not run")
+	}
+	shutdown(t, q)
+}
+
+func TestQueueShutdownDeadline(t *testing.T) {
+	tests := []struct {
+		name string
+		// handler blocks until the job is cancelled or released
+		handler func(ctx context.Context, release <-chan struct{}) error
+	}{
+		{
+			name: "job honours cancellation",
+			handler: func(ctx context.Context, release <-chan struct{}) error {
+				<-ctx.Done()
+				return ctx.Err()
+			},
+		},
+		{
+			name: "job ignores cancellation",
+			handler: func(ctx context.Context, release <-chan struct{}) error {
+				<-release
+				return errors.New("released")
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewMemoryStore()
+			q := newTestQueue(store, 1, 3)
+			q.cancelGrace = 10 * time.Millisecond
+
+			release := make(chan struct{})
+			defer close(release)
+			started := make(chan struct{})
+			q.Register(testJobType, func(ctx context.Context, job *Job) error {
+				close(started)
+				return tt.handler(ctx, release)
+			})
+			if err := q.Start(); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := q.Enqueue(testJobType, "", nil); err != nil {
+				t.Fatal(err)
+			}
+			waitFor(t, started, "the job to start")
+
+			ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+			defer cancel()
+			begin := time.Now()
+			err := q.Shutdown(ctx)
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("Shutdown() error = %v, want %v", err, context.DeadlineExceeded)
+			}
+			if elapsed := time.Since(begin); elapsed > time.Second {
+				t.Errorf("Shutdown() took %v after its deadline", elapsed)
+			}
+			if jobs, _ := store.List(); len(jobs) != 1 {
+				t.Errorf("store holds %d job(s), want the interrupted job", len(jobs))
+			}
+		})
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/sirupsen/logrus"
 	"github.com/vinamra28/whytho/internal/config"
 	"github.com/vinamra28/whytho/internal/handlers"
+	"github.com/vinamra28/whytho/internal/queue"
 	"github.com/vinamra28/whytho/internal/services"
 )
 
@@ -15,6 +16,7 @@ type Server struct {
 	config *config.Config
 	router *gin.Engine
 	server *http.Server
+	queue  *queue.Queue
 }
 
 func New(cfg *config.Config) *Server {
@@ -22,27 +24,70 @@ func New(cfg *config.Config) *Server {
 
 	router := gin.Default()
 
-	logrus.Info("Creating GitLab service")
-	gitlabService := services.NewGitLabService(cfg.GitLabToken, cfg.GitLabBaseURL)
+	logrus.Info("Creating LLM provider")
+	llmProvider, err := services.NewLLMProvider(cfg.LLMProvider, cfg.LLMModel, cfg.LLMAPIKey, cfg.LLMBaseURL)
+	if err != nil {
+		logrus.WithError(err).WithField("provider", cfg.LLMProvider).Fatal("Failed to create LLM provider")
+	}
 
 	logrus.Info("Creating review service")
-	reviewService := services.NewReviewService(cfg.GeminiAPIKey)
+	reviewService := services.NewReviewService(llmProvider, cfg.ReviewMaxPromptTokens, cfg.ReviewBatchConcurrency).
+		WithConfigResolver(services.NewConfigResolver(cfg.ConfigDefaultsDir, cfg.ConfigGroupRepo, cfg.ConfigCacheTTL))
 
-	logrus.Info("Creating webhook handler")
-	webhookHandler := handlers.NewWebhookHandler(gitlabService, reviewService, cfg.WebhookSecret)
+	logrus.Info("Creating job queue")
+	var store queue.Store = queue.NewMemoryStore()
+	if cfg.QueueStorePath != "" {
+		boltStore, err := queue.NewBoltStore(cfg.QueueStorePath)
+		if err != nil {
+			logrus.WithError(err).WithField("path", cfg.QueueStorePath).Fatal("Failed to open persistent job store")
+		}
+		store = boltStore
+	}
+	jobQueue := queue.New(store, cfg.QueueWorkers, cfg.QueueMaxAttempts)
 
 	logrus.Info("Setting up routes")
-	router.POST("/webhook", webhookHandler.HandleWebhook)
+	if cfg.GitLabToken != "" {
+		logrus.Info("Creating GitLab service")
+		gitlabService := services.NewGitLabService(cfg.GitLabToken, cfg.GitLabBaseURL)
+
+		logrus.Info("Creating webhook handler")
+		webhookHandler := handlers.NewWebhookHandler(gitlabService, reviewService, jobQueue, cfg)
+		router.POST("/webhook", webhookHandler.HandleWebhook)
+	}
+
+	pullRequestReviewer := handlers.NewPullRequestReviewer(reviewService, jobQueue, cfg)
+	if cfg.GitHubToken != "" {
+		githubService := services.NewGitHubService(cfg.GitHubToken, cfg.GitHubBaseURL)
+		pullRequestReviewer.AddProvider(githubService)
+		router.POST("/github/webhook", handlers.NewGitHubHandler(pullRequestReviewer, cfg.GitHubWebhookSecrets).HandleWebhook)
+	}
+	if cfg.GiteaToken != "" {
+		giteaService := services.NewGiteaService(cfg.GiteaToken, cfg.GiteaBaseURL)
+		pullRequestReviewer.AddProvider(giteaService)
+		router.POST("/gitea/webhook", handlers.NewGiteaHandler(pullRequestReviewer, cfg.GiteaWebhookSecrets).HandleWebhook)
+	}
+	if cfg.BitbucketToken != "" {
+		bitbucketService := services.NewBitbucketService(cfg.BitbucketToken, cfg.BitbucketBaseURL)
+		pullRequestReviewer.AddProvider(bitbucketService)
+		router.POST("/bitbucket/webhook", handlers.NewBitbucketHandler(pullRequestReviewer, cfg.BitbucketWebhookSecrets).HandleWebhook)
+	}
+	router.POST("/config/validate", handlers.ValidateConfig)
+	router.GET("/config/schema", handlers.ConfigSchema)
 	router.GET("/health", handlers.HealthCheck)
 
 	logrus.Info("Server initialized successfully")
 	return &Server{
 		config: cfg,
 		router: router,
+		queue:  jobQueue,
 	}
 }
 
 func (s *Server) Start(addr string) error {
+	if err := s.queue.Start(); err != nil {
+		return err
+	}
+
 	s.server = &http.Server{
 		Addr:    addr,
 		Handler: s.router,
@@ -54,5 +99,14 @@ func (s *Server) Start(addr string) error {
 
 func (s *Server) Shutdown(ctx context.Context) error {
 	logrus.Info("Shutting down HTTP server")
-	return s.server.Shutdown(ctx)
+	httpErr := s.server.Shutdown(ctx)
+	if httpErr != nil {
+		logrus.WithError(httpErr).Error("HTTP server did not shut down cleanly")
+	}
+
+	logrus.Info("Draining review job queue")
+	if err := s.queue.Shutdown(ctx); err != nil {
+		return err
+	}
+	return httpErr
 }
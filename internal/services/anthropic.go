@@ -0,0 +1,98 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultAnthropicModel    = "claude-sonnet-4-0"
+	defaultAnthropicBaseURL  = "https://api.anthropic.com"
+	anthropicAPIVersion      = "2023-06-01"
+	anthropicMaxOutputTokens = 8192
+)
+
+type AnthropicProvider struct {
+	apiKey  string
+	model   string
+	baseURL string
+}
+
+type anthropicMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type anthropicMessagesRequest struct {
+	Model       string             `json:"model"`
+	MaxTokens   int                `json:"max_tokens"`
+	Temperature float32            `json:"temperature"`
+	Messages    []anthropicMessage `json:"messages"`
+}
+
+type anthropicMessagesResponse struct {
+	Content []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"content"`
+}
+
+func NewAnthropicProvider(apiKey, model, baseURL string) *AnthropicProvider {
+	if model == "" {
+		model = defaultAnthropicModel
+	}
+	if baseURL == "" {
+		baseURL = defaultAnthropicBaseURL
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"model":    model,
+		"base_url": baseURL,
+	}).Info("Anthropic client created successfully")
+	return &AnthropicProvider{
+		apiKey:  apiKey,
+		model:   model,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+	}
+}
+
+func (p *AnthropicProvider) Name() string {
+	return LLMProviderAnthropic
+}
+
+func (p *AnthropicProvider) Generate(ctx context.Context, req LLMRequest) (string, error) {
+	payload := anthropicMessagesRequest{
+		Model:       p.model,
+		MaxTokens:   anthropicMaxOutputTokens,
+		Temperature: req.Temperature,
+		Messages:    []anthropicMessage{{Role: "user", Content: req.Prompt}},
+	}
+
+	headers := map[string]string{
+		"x-api-key":         p.apiKey,
+		"anthropic-version": anthropicAPIVersion,
+	}
+
+	// The Messages API has no native JSON mode, so structured output relies on
+	// the prompt and the review repair pass.
+	var resp anthropicMessagesResponse
+	if err := postLLMJSON(ctx, p.baseURL+"/v1/messages", headers, payload, &resp); err != nil {
+		return "", fmt.Errorf("anthropic request failed: %w", err)
+	}
+
+	var text strings.Builder
+	for _, block := range resp.Content {
+		if block.Type == "text" {
+			text.WriteString(block.Text)
+		}
+	}
+
+	if text.Len() == 0 {
+		return "", fmt.Errorf("no response generated")
+	}
+
+	return text.String(), nil
+}
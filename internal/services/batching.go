@@ -0,0 +1,234 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	"github.com/vinamra28/whytho/internal/models"
+)
+
+// batchNote tells the model that it only sees part of a large merge request.
+const batchNote = "## Note\nThis merge request is large and is reviewed in several batches. Only review the files shown below; other files are reviewed separately.\n\n"
+
+// reviewFile is a changed file rendered as a "## File:" section of the review prompt.
+type reviewFile struct {
+	Path    string
+	Content string
+	// DiffLines are the annotated diff lines keyed by DIFF_LINE, used to validate the review
+	DiffLines map[int]models.DiffLine
+	Tokens    int
+}
+
+// estimateTokens approximates the token count of a prompt. Roughly four
+// characters per token holds well enough for code across the supported models.
+func estimateTokens(text string) int {
+	return (len(text) + 3) / 4
+}
+
+func (r *ReviewService) renderReviewFile(change models.MRChange) reviewFile {
+	var content strings.Builder
+	content.WriteString(fmt.Sprintf("## File: %s\n", change.NewPath))
+	if change.NewFile {
+		content.WriteString("(New file)\n")
+	}
+	if change.RenamedFile {
+		content.WriteString(fmt.Sprintf("(Renamed from: %s)\n", change.OldPath))
+	}
+
+	// Process the diff to add line numbers for AI reference
+	content.WriteString("```diff\n")
+	processedDiff, diffLines := r.addLineNumbersToDiff(change.Diff)
+	content.WriteString(processedDiff)
+	content.WriteString("\n```\n\n")
+
+	return reviewFile{
+		Path:      change.NewPath,
+		Content:   content.String(),
+		DiffLines: diffLines,
+		Tokens:    estimateTokens(content.String()),
+	}
+}
+
+// planReviewBatches packs files into batches that fit the token budget.
+// Files of the same directory are kept together where possible so the model
+// sees related code side by side. Files that do not fit on their own are
+// returned as skipped. It fails when the budget leaves no room for any diff.
+func planReviewBatches(files []reviewFile, budget int) ([][]reviewFile, []string, error) {
+	if budget <= 0 && len(files) > 0 {
+		return nil, nil, fmt.Errorf("the review instructions and guidance fill the whole prompt budget, leaving no room for the diffs")
+	}
+
+	groups := make(map[string][]reviewFile)
+	var dirs []string
+	for _, file := range files {
+		dir := path.Dir(file.Path)
+		if _, ok := groups[dir]; !ok {
+			dirs = append(dirs, dir)
+		}
+		groups[dir] = append(groups[dir], file)
+	}
+	sort.Strings(dirs)
+
+	var batches [][]reviewFile
+	var skipped []string
+	var current []reviewFile
+	currentTokens := 0
+
+	flush := func() {
+		if len(current) > 0 {
+			batches = append(batches, current)
+		}
+		current = nil
+		currentTokens = 0
+	}
+
+	for _, dir := range dirs {
+		group := groups[dir]
+		groupTokens := 0
+		for _, file := range group {
+			groupTokens += file.Tokens
+		}
+
+		// Keep the directory in one batch if it fits, starting a new batch if needed
+		if groupTokens <= budget {
+			if currentTokens+groupTokens > budget {
+				flush()
+			}
+			current = append(current, group...)
+			currentTokens += groupTokens
+			continue
+		}
+
+		for _, file := range group {
+			if file.Tokens > budget {
+				skipped = append(skipped, file.Path)
+				continue
+			}
+			if currentTokens+file.Tokens > budget {
+				flush()
+			}
+			current = append(current, file)
+			currentTokens += file.Tokens
+		}
+	}
+	flush()
+
+	return batches, skipped, nil
+}
+
+// reviewBatches reviews the batches concurrently, at most batchConcurrency at
+// a time, each with the guidance relevant to its files. It fails when any
+// batch failed, so the review is retried rather than reported as complete
+// while some files were never reviewed.
+func (r *ReviewService) reviewBatches(ctx context.Context, guidance *pathGuidance, header string, batches [][]reviewFile) ([]*models.CodeReview, error) {
+	if len(batches) > 1 {
+		header += batchNote
+	}
+
+	reviews := make([]*models.CodeReview, len(batches))
+	errs := make([]error, len(batches))
+	semaphore := make(chan struct{}, r.batchConcurrency)
+	var wg sync.WaitGroup
+
+	for i, batch := range batches {
+		wg.Add(1)
+		go func(i int, batch []reviewFile) {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			var content strings.Builder
+			content.WriteString(header)
+			diffIndex := make(map[string]map[int]models.DiffLine)
+			for _, file := range batch {
+				content.WriteString(file.Content)
+				diffIndex[file.Path] = file.DiffLines
+			}
+
+			logrus.WithFields(logrus.Fields{
+				"batch":         i + 1,
+				"total_batches": len(batches),
+				"files":         len(batch),
+			}).Debug("Reviewing batch")
+
+			reviews[i], errs[i] = r.generateReview(ctx, buildReviewPrompt(guidance.forFiles(batch), content.String()), diffIndex)
+		}(i, batch)
+	}
+	wg.Wait()
+
+	failed := 0
+	var firstErr error
+	for i, err := range errs {
+		if err == nil {
+			continue
+		}
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"batch":         i + 1,
+			"total_batches": len(batches),
+		}).Error("Failed to review batch")
+		if firstErr == nil {
+			firstErr = err
+		}
+		failed++
+	}
+	if failed > 0 {
+		return nil, fmt.Errorf("review of %d of %d batch(es) failed: %w", failed, len(batches), firstErr)
+	}
+	return reviews, nil
+}
+
+// mergeReviews combines the batch reviews into one, consolidating the batch
+// summaries and noting the files that were too large to review.
+func (r *ReviewService) mergeReviews(ctx context.Context, title string, reviews []*models.CodeReview, skippedFiles []string) *models.CodeReview {
+	merged := &models.CodeReview{
+		Comments:           []string{},
+		PositionedComments: []models.PositionedComment{},
+	}
+
+	var summaries []string
+	for _, review := range reviews {
+		merged.Comments = append(merged.Comments, review.Comments...)
+		merged.PositionedComments = append(merged.PositionedComments, review.PositionedComments...)
+		if review.Summary != "" {
+			summaries = append(summaries, review.Summary)
+		}
+	}
+
+	switch len(summaries) {
+	case 0:
+	case 1:
+		merged.Summary = summaries[0]
+	default:
+		merged.Summary = r.consolidateSummaries(ctx, title, summaries)
+	}
+
+	if len(skippedFiles) > 0 {
+		note := fmt.Sprintf("**Not reviewed, too large for the model context:** `%s`", strings.Join(skippedFiles, "`, `"))
+		merged.Summary = strings.TrimSpace(merged.Summary + "\n\n" + note)
+	}
+
+	return merged
+}
+
+// consolidateSummaries asks the LLM to merge the summaries of the batches of
+// one merge request, falling back to listing them when that fails.
+func (r *ReviewService) consolidateSummaries(ctx context.Context, title string, summaries []string) string {
+	prompt := fmt.Sprintf(`The merge request "%s" was reviewed in %d batches of files. Combine the batch summaries below into a single summary paragraph highlighting the most important findings and the overall assessment. Respond with the summary text only.
+
+%s`, title, len(summaries), strings.Join(summaries, "\n\n---\n\n"))
+
+	summary, err := r.llm.Generate(ctx, LLMRequest{
+		Prompt:      prompt,
+		Temperature: 0.1,
+	})
+	if err != nil || strings.TrimSpace(summary) == "" {
+		logrus.WithError(err).WithField("batches", len(summaries)).Warn("Failed to consolidate batch summaries, listing them instead")
+		return strings.Join(summaries, "\n\n")
+	}
+	return strings.TrimSpace(summary)
+}
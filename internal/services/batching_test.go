@@ -0,0 +1,158 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPlanReviewBatches(t *testing.T) {
+	file := func(path string, tokens int) reviewFile {
+		return reviewFile{Path: path, Tokens: tokens}
+	}
+
+	tests := []struct {
+		name        string
+		files       []reviewFile
+		budget      int
+		wantBatches [][]string
+		wantSkipped []string
+		wantErr     bool
+	}{
+		{
+			name:        "everything fits in one batch",
+			files:       []reviewFile{file("b/x.go", 10), file("a/y.go", 10), file("a/z.go", 10)},
+			budget:      100,
+			wantBatches: [][]string{{"a/y.go", "a/z.go", "b/x.go"}},
+		},
+		{
+			name:        "directories are kept together",
+			files:       []reviewFile{file("a/1.go", 40), file("b/1.go", 40), file("a/2.go", 40), file("b/2.go", 40)},
+			budget:      100,
+			wantBatches: [][]string{{"a/1.go", "a/2.go"}, {"b/1.go", "b/2.go"}},
+		},
+		{
+			name:        "small directories share a batch",
+			files:       []reviewFile{file("a/1.go", 30), file("b/1.go", 30), file("c/1.go", 30), file("d/1.go", 30)},
+			budget:      100,
+			wantBatches: [][]string{{"a/1.go", "b/1.go", "c/1.go"}, {"d/1.go"}},
+		},
+		{
+			name:        "directory larger than the budget is split",
+			files:       []reviewFile{file("a/1.go", 60), file("a/2.go", 60), file("a/3.go", 30)},
+			budget:      100,
+			wantBatches: [][]string{{"a/1.go"}, {"a/2.go", "a/3.go"}},
+		},
+		{
+			name:        "oversize file is skipped",
+			files:       []reviewFile{file("a/huge.go", 150), file("a/small.go", 20), file("b/1.go", 20)},
+			budget:      100,
+			wantBatches: [][]string{{"a/small.go", "b/1.go"}},
+			wantSkipped: []string{"a/huge.go"},
+		},
+		{
+			name:        "file exactly at the budget fits",
+			files:       []reviewFile{file("main.go", 100)},
+			budget:      100,
+			wantBatches: [][]string{{"main.go"}},
+		},
+		{
+			name:   "no files",
+			budget: 100,
+		},
+		{
+			name:    "guidance alone exceeds the budget",
+			files:   []reviewFile{file("main.go", 1)},
+			budget:  -20,
+			wantErr: true,
+		},
+		{
+			name:    "no budget left",
+			files:   []reviewFile{file("main.go", 1)},
+			budget:  0,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			batches, skipped, err := planReviewBatches(tt.files, tt.budget)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("planReviewBatches() error = %v, want error %v", err, tt.wantErr)
+			}
+
+			var gotBatches [][]string
+			for _, batch := range batches {
+				var paths []string
+				for _, file := range batch {
+					paths = append(paths, file.Path)
+				}
+				gotBatches = append(gotBatches, paths)
+			}
+			if !reflect.DeepEqual(gotBatches, tt.wantBatches) {
+				t.Errorf("batches = %q, want %q", gotBatches, tt.wantBatches)
+			}
+			if !reflect.DeepEqual(skipped, tt.wantSkipped) {
+				t.Errorf("skipped = %q, want %q", skipped, tt.wantSkipped)
+			}
+		})
+	}
+}
+
+// fakeLLM answers every review prompt with an empty review, failing prompts
+// that contain failOn.
+type fakeLLM struct {
+	failOn string
+}
+
+func (f *fakeLLM) Name() string {
+	return "fake"
+}
+
+func (f *fakeLLM) Generate(_ context.Context, req LLMRequest) (string, error) {
+	if f.failOn != "" && strings.Contains(req.Prompt, f.failOn) {
+		return "", errors.New("model unavailable")
+	}
+	return `{"summary": "Looks good", "positioned_comments": [], "general_comments": []}`, nil
+}
+
+func TestReviewBatches(t *testing.T) {
+	batches := [][]reviewFile{
+		{{Path: "a/1.go", Content: "## File: a/1.go\n"}},
+		{{Path: "b/1.go", Content: "## File: b/1.go\n"}},
+	}
+
+	tests := []struct {
+		name    string
+		failOn  string
+		wantErr string
+	}{
+		{name: "all batches succeed"},
+		{name: "one batch fails", failOn: "## File: b/1.go", wantErr: "review of 1 of 2 batch(es) failed"},
+		{name: "every batch fails", failOn: "## File:", wantErr: "review of 2 of 2 batch(es) failed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewReviewService(&fakeLLM{failOn: tt.failOn}, 1000, 2)
+			reviews, err := r.reviewBatches(context.Background(), &pathGuidance{}, "", batches)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("reviewBatches() error = %v, want %q", err, tt.wantErr)
+				}
+				if reviews != nil {
+					t.Errorf("reviewBatches() = %v, want no reviews for a partial failure", reviews)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("reviewBatches() error = %v", err)
+			}
+			if len(reviews) != len(batches) {
+				t.Errorf("reviewBatches() returned %d reviews, want %d", len(reviews), len(batches))
+			}
+		})
+	}
+}
@@ -0,0 +1,380 @@
+package services
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/vinamra28/whytho/internal/diff"
+	"github.com/vinamra28/whytho/internal/models"
+)
+
+// BitbucketService implements SCMProvider for Bitbucket Server and Data
+// Center. Repositories are addressed as "PROJECT/slug".
+type BitbucketService struct {
+	api *restClient
+	// webURL is the instance URL, used as the build status link when there is no other
+	webURL  string
+	botUser botUserCache
+}
+
+func NewBitbucketService(token, baseURL string) *BitbucketService {
+	logrus.WithField("base_url", baseURL).Info("Creating Bitbucket client")
+	baseURL = strings.TrimSuffix(baseURL, "/")
+	return &BitbucketService{
+		api: newRESTClient("Bitbucket", baseURL+"/rest", map[string]string{
+			"Authorization": "Bearer " + token,
+		}),
+		webURL: baseURL,
+	}
+}
+
+func (b *BitbucketService) Name() string {
+	return "bitbucket"
+}
+
+// bitbucketRepoPath returns the API path of a "PROJECT/slug" repository.
+func bitbucketRepoPath(repo string) (string, error) {
+	project, slug, ok := strings.Cut(repo, "/")
+	if !ok || project == "" || slug == "" {
+		return "", fmt.Errorf("invalid Bitbucket repository %q, expected PROJECT/slug", repo)
+	}
+	return fmt.Sprintf("/api/1.0/projects/%s/repos/%s", url.PathEscape(project), url.PathEscape(slug)), nil
+}
+
+func (b *BitbucketService) pullRequestPath(repo string, number int) (string, error) {
+	repoPath, err := bitbucketRepoPath(repo)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s/pull-requests/%d", repoPath, number), nil
+}
+
+func (b *BitbucketService) GetPullRequest(repo string, number int) (*models.PullRequest, error) {
+	prPath, err := b.pullRequestPath(repo, number)
+	if err != nil {
+		return nil, err
+	}
+	var pr struct {
+		Title       string `json:"title"`
+		Description string `json:"description"`
+		State       string `json:"state"`
+		FromRef     struct {
+			LatestCommit string `json:"latestCommit"`
+		} `json:"fromRef"`
+		ToRef struct {
+			DisplayID string `json:"displayId"`
+		} `json:"toRef"`
+		Links struct {
+			Self []struct {
+				Href string `json:"href"`
+			} `json:"self"`
+		} `json:"links"`
+	}
+	if err := b.api.do(http.MethodGet, prPath, nil, &pr); err != nil {
+		return nil, fmt.Errorf("failed to get pull request: %w", err)
+	}
+
+	webURL := ""
+	if len(pr.Links.Self) > 0 {
+		webURL = pr.Links.Self[0].Href
+	}
+	return &models.PullRequest{
+		Title:        pr.Title,
+		Description:  pr.Description,
+		TargetBranch: pr.ToRef.DisplayID,
+		HeadSHA:      pr.FromRef.LatestCommit,
+		WebURL:       webURL,
+		Open:         pr.State == "OPEN",
+	}, nil
+}
+
+type bitbucketPath struct {
+	ToString string `json:"toString"`
+}
+
+type bitbucketDiff struct {
+	Source      *bitbucketPath `json:"source"`
+	Destination *bitbucketPath `json:"destination"`
+	Binary      bool           `json:"binary"`
+	Hunks       []struct {
+		SourceLine      int `json:"sourceLine"`
+		SourceSpan      int `json:"sourceSpan"`
+		DestinationLine int `json:"destinationLine"`
+		DestinationSpan int `json:"destinationSpan"`
+		Segments        []struct {
+			Type  string `json:"type"`
+			Lines []struct {
+				Line string `json:"line"`
+			} `json:"lines"`
+		} `json:"segments"`
+	} `json:"hunks"`
+}
+
+// GetChanges fetches the pull request's structured diff and renders every
+// file as a unified diff, the format the review pipeline works on.
+func (b *BitbucketService) GetChanges(repo string, number int) ([]models.MRChange, error) {
+	logrus.WithFields(logrus.Fields{
+		"project_id": repo,
+		"mr_iid":     number,
+	}).Debug("Fetching pull request diff")
+
+	prPath, err := b.pullRequestPath(repo, number)
+	if err != nil {
+		return nil, err
+	}
+	var response struct {
+		Diffs []bitbucketDiff `json:"diffs"`
+	}
+	if err := b.api.do(http.MethodGet, prPath+"/diff?contextLines=3", nil, &response); err != nil {
+		return nil, fmt.Errorf("failed to get pull request diff: %w", err)
+	}
+
+	var changes []models.MRChange
+	for _, fileDiff := range response.Diffs {
+		change := models.MRChange{
+			NewFile:     fileDiff.Source == nil,
+			DeletedFile: fileDiff.Destination == nil,
+		}
+		if fileDiff.Source != nil {
+			change.OldPath = fileDiff.Source.ToString
+		}
+		if fileDiff.Destination != nil {
+			change.NewPath = fileDiff.Destination.ToString
+		}
+		if change.OldPath == "" {
+			change.OldPath = change.NewPath
+		}
+		if change.NewPath == "" {
+			change.NewPath = change.OldPath
+		}
+		change.RenamedFile = !change.NewFile && !change.DeletedFile && change.OldPath != change.NewPath
+
+		if fileDiff.Binary {
+			change.Diff = fmt.Sprintf("Binary files a/%s and b/%s differ", change.OldPath, change.NewPath)
+			changes = append(changes, change)
+			continue
+		}
+
+		var lines []string
+		for _, hunk := range fileDiff.Hunks {
+			lines = append(lines, fmt.Sprintf("@@ -%d,%d +%d,%d @@",
+				hunk.SourceLine, hunk.SourceSpan, hunk.DestinationLine, hunk.DestinationSpan))
+			for _, segment := range hunk.Segments {
+				prefix := " "
+				switch segment.Type {
+				case "ADDED":
+					prefix = "+"
+				case "REMOVED":
+					prefix = "-"
+				}
+				for _, line := range segment.Lines {
+					lines = append(lines, prefix+line.Line)
+				}
+			}
+		}
+		change.Diff = strings.Join(lines, "\n")
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+func (b *BitbucketService) GetFile(repo, path, ref string) (string, error) {
+	repoPath, err := bitbucketRepoPath(repo)
+	if err != nil {
+		return "", err
+	}
+	content, err := b.api.send(http.MethodGet,
+		fmt.Sprintf("%s/raw/%s%s", repoPath, escapePath(path), refQuery("at", ref)), nil, "*/*")
+	if err != nil {
+		if isNotFound(err) {
+			return "", ErrFileNotFound
+		}
+		return "", fmt.Errorf("failed to get file %s: %w", path, err)
+	}
+	return string(content), nil
+}
+
+// PostInlineComment posts the finding as a comment anchored to its line.
+// Ranges are anchored at their last line, with suggestions as code blocks.
+func (b *BitbucketService) PostInlineComment(repo string, number int, comment models.PositionedComment) error {
+	prPath, err := b.pullRequestPath(repo, number)
+	if err != nil {
+		return err
+	}
+	changes, err := b.GetChanges(repo, number)
+	if err != nil {
+		return err
+	}
+
+	resolved, err := ResolveDiffPosition(changes, comment)
+	if err != nil {
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"project_id":  repo,
+			"mr_iid":      number,
+			"file_path":   comment.FilePath,
+			"line_number": comment.LineNumber,
+		}).Warn("Failed to convert diff line to actual line, falling back to general comment")
+		return b.PostGeneralComment(repo, number, UnpositionedFindingBody(comment))
+	}
+
+	lineType, fileType, line := bitbucketLineAnchor(diffLineType(resolved.LineType), resolved.OldLineNumber, resolved.NewLineNumber)
+	anchor := map[string]any{
+		"diffType": "EFFECTIVE",
+		"path":     resolved.FilePath,
+		"line":     line,
+		"lineType": lineType,
+		"fileType": fileType,
+	}
+	if resolved.OldPath != "" && resolved.OldPath != resolved.FilePath {
+		anchor["srcPath"] = resolved.OldPath
+	}
+	request := map[string]any{
+		"text":   InlineCommentBody(resolved, formatSuggestion(resolved, false)),
+		"anchor": anchor,
+	}
+
+	if err := b.api.do(http.MethodPost, prPath+"/comments", request, nil); err != nil {
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"project_id": repo,
+			"mr_iid":     number,
+			"file_path":  resolved.FilePath,
+			"line":       line,
+			"line_type":  lineType,
+		}).Error("Failed to post review comment to Bitbucket, falling back to general comment")
+		return b.PostGeneralComment(repo, number, UnpositionedFindingBody(comment))
+	}
+	return nil
+}
+
+// bitbucketLineAnchor maps a diff line to the line type, file type and line
+// number Bitbucket anchors comments by.
+func bitbucketLineAnchor(lineType string, oldLine, newLine int) (string, string, int) {
+	switch lineType {
+	case diff.LineRemoved:
+		return "REMOVED", "FROM", oldLine
+	case diff.LineAdded:
+		return "ADDED", "TO", newLine
+	default:
+		return "CONTEXT", "TO", newLine
+	}
+}
+
+func (b *BitbucketService) PostGeneralComment(repo string, number int, body string) error {
+	prPath, err := b.pullRequestPath(repo, number)
+	if err != nil {
+		return err
+	}
+	if err := b.api.do(http.MethodPost, prPath+"/comments", map[string]string{"text": body}, nil); err != nil {
+		return fmt.Errorf("failed to post comment: %w", err)
+	}
+	return nil
+}
+
+// ListComments returns the top-level comments of the pull request's activity
+// stream, which holds both general and anchored comments.
+func (b *BitbucketService) ListComments(repo string, number int) ([]models.SCMComment, error) {
+	prPath, err := b.pullRequestPath(repo, number)
+	if err != nil {
+		return nil, err
+	}
+
+	var comments []models.SCMComment
+	for start := 0; ; {
+		var page struct {
+			Values []struct {
+				Action  string `json:"action"`
+				Comment struct {
+					ID     int64  `json:"id"`
+					Text   string `json:"text"`
+					Author struct {
+						Name string `json:"name"`
+					} `json:"author"`
+				} `json:"comment"`
+			} `json:"values"`
+			IsLastPage    bool `json:"isLastPage"`
+			NextPageStart int  `json:"nextPageStart"`
+		}
+		path := fmt.Sprintf("%s/activities?start=%d&limit=100", prPath, start)
+		if err := b.api.do(http.MethodGet, path, nil, &page); err != nil {
+			return nil, fmt.Errorf("failed to list comments: %w", err)
+		}
+		for _, activity := range page.Values {
+			if activity.Action == "COMMENTED" {
+				comments = append(comments, models.SCMComment{ID: activity.Comment.ID, Body: activity.Comment.Text, Author: activity.Comment.Author.Name})
+			}
+		}
+		if page.IsLastPage {
+			return comments, nil
+		}
+		start = page.NextPageStart
+	}
+}
+
+// UpdateComment edits a comment. Bitbucket requires the comment's current
+// version, so the comment is fetched first.
+func (b *BitbucketService) UpdateComment(repo string, number int, commentID int64, body string) error {
+	prPath, err := b.pullRequestPath(repo, number)
+	if err != nil {
+		return err
+	}
+	commentPath := fmt.Sprintf("%s/comments/%d", prPath, commentID)
+
+	var current struct {
+		Version int `json:"version"`
+	}
+	if err := b.api.do(http.MethodGet, commentPath, nil, &current); err != nil {
+		return fmt.Errorf("failed to get comment: %w", err)
+	}
+	request := map[string]any{"text": body, "version": current.Version}
+	if err := b.api.do(http.MethodPut, commentPath, request, nil); err != nil {
+		return fmt.Errorf("failed to update comment: %w", err)
+	}
+	return nil
+}
+
+// SetStatus reports a build status on the commit, which Bitbucket shows on
+// the pull request.
+func (b *BitbucketService) SetStatus(repo, sha, state, description, targetURL string) error {
+	bitbucketState := "INPROGRESS"
+	switch state {
+	case ReviewStatusSuccess:
+		bitbucketState = "SUCCESSFUL"
+	case ReviewStatusFailed:
+		bitbucketState = "FAILED"
+	}
+	// Build statuses require a link
+	if targetURL == "" {
+		targetURL = b.webURL
+	}
+
+	request := map[string]string{
+		"state":       bitbucketState,
+		"key":         ReviewStatusName,
+		"name":        ReviewStatusName,
+		"url":         targetURL,
+		"description": description,
+	}
+	if err := b.api.do(http.MethodPost, "/build-status/1.0/commits/"+url.PathEscape(sha), request, nil); err != nil {
+		return fmt.Errorf("failed to set commit status: %w", err)
+	}
+	return nil
+}
+
+// BotUsername returns the user the token authenticates as, which Bitbucket
+// reports in the X-AUSERNAME header of every authenticated response.
+func (b *BitbucketService) BotUsername() (string, error) {
+	return b.botUser.get(func() (string, error) {
+		_, header, err := b.api.exchange(http.MethodGet, "/api/1.0/application-properties", nil, "application/json")
+		if err != nil {
+			return "", err
+		}
+		username := header.Get("X-AUSERNAME")
+		if username == "" {
+			return "", fmt.Errorf("Bitbucket did not report the authenticated user")
+		}
+		return username, nil
+	})
+}
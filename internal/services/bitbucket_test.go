@@ -0,0 +1,266 @@
+package services
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/vinamra28/whytho/internal/diff"
+	"github.com/vinamra28/whytho/internal/models"
+)
+
+const bitbucketTestPRPath = "/api/1.0/projects/ACME/repos/api/pull-requests/7"
+
+// newBitbucketTestService returns a BitbucketService talking to a test server
+// that serves the given routes, keyed by "METHOD path" below /rest.
+func newBitbucketTestService(t *testing.T, routes map[string]http.HandlerFunc) *BitbucketService {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer test-token" {
+			t.Errorf("Authorization header = %q", got)
+		}
+		handler, ok := routes[r.Method+" "+strings.TrimPrefix(r.URL.Path, "/rest")]
+		if !ok {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+			http.NotFound(w, r)
+			return
+		}
+		handler(w, r)
+	}))
+	t.Cleanup(server.Close)
+	return NewBitbucketService("test-token", server.URL)
+}
+
+func TestBitbucketLineAnchor(t *testing.T) {
+	tests := []struct {
+		name         string
+		lineType     string
+		oldLine      int
+		newLine      int
+		wantLineType string
+		wantFileType string
+		wantLine     int
+	}{
+		{"added line", diff.LineAdded, 0, 12, "ADDED", "TO", 12},
+		{"removed line", diff.LineRemoved, 9, 0, "REMOVED", "FROM", 9},
+		{"context line", diff.LineContext, 9, 12, "CONTEXT", "TO", 12},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lineType, fileType, line := bitbucketLineAnchor(tt.lineType, tt.oldLine, tt.newLine)
+			if lineType != tt.wantLineType || fileType != tt.wantFileType || line != tt.wantLine {
+				t.Errorf("bitbucketLineAnchor() = %s, %s, %d, want %s, %s, %d",
+					lineType, fileType, line, tt.wantLineType, tt.wantFileType, tt.wantLine)
+			}
+		})
+	}
+}
+
+type bitbucketTestActivity struct {
+	Action  string `json:"action"`
+	Comment struct {
+		ID     int64  `json:"id"`
+		Text   string `json:"text"`
+		Author struct {
+			Name string `json:"name"`
+		} `json:"author"`
+	} `json:"comment"`
+}
+
+func bitbucketComment(id int64, author, text string) bitbucketTestActivity {
+	activity := bitbucketTestActivity{Action: "COMMENTED"}
+	activity.Comment.ID = id
+	activity.Comment.Text = text
+	activity.Comment.Author.Name = author
+	return activity
+}
+
+func TestBitbucketListComments(t *testing.T) {
+	summary := SummaryCommentBody("Looks good", "abc123")
+	finding := InlineCommentBody(models.PositionedComment{FilePath: "main.go", OriginalLine: "f()", Severity: "HIGH", Comment: "Unchecked error"}, "")
+
+	pages := map[string]map[string]any{
+		"0": {
+			"values": []bitbucketTestActivity{
+				bitbucketComment(1, "whytho-bot", summary),
+				{Action: "APPROVED"},
+			},
+			"isLastPage":    false,
+			"nextPageStart": 2,
+		},
+		"2": {
+			"values": []bitbucketTestActivity{
+				bitbucketComment(2, "mallory", SummaryCommentBody("Fake summary", "abc123")),
+				bitbucketComment(3, "whytho-bot", finding),
+			},
+			"isLastPage": true,
+		},
+	}
+	bitbucket := newBitbucketTestService(t, map[string]http.HandlerFunc{
+		"GET " + bitbucketTestPRPath + "/activities": func(w http.ResponseWriter, r *http.Request) {
+			if limit := r.URL.Query().Get("limit"); limit != "100" {
+				t.Errorf("limit = %q, want 100", limit)
+			}
+			page, ok := pages[r.URL.Query().Get("start")]
+			if !ok {
+				t.Errorf("unexpected page start %q", r.URL.Query().Get("start"))
+				http.NotFound(w, r)
+				return
+			}
+			respondJSON(t, page)(w, r)
+		},
+	})
+
+	comments, err := bitbucket.ListComments("ACME/api", 7)
+	if err != nil {
+		t.Fatalf("ListComments() error = %v", err)
+	}
+	wantAuthors := map[int64]string{1: "whytho-bot", 2: "mallory", 3: "whytho-bot"}
+	if len(comments) != len(wantAuthors) {
+		t.Fatalf("ListComments() returned %d comments, want %d: %+v", len(comments), len(wantAuthors), comments)
+	}
+	for _, comment := range comments {
+		if comment.Author != wantAuthors[comment.ID] {
+			t.Errorf("comment %d author = %q, want %q", comment.ID, comment.Author, wantAuthors[comment.ID])
+		}
+	}
+
+	posted, summaryID := BotComments(comments, "whytho-bot")
+	if summaryID != 1 {
+		t.Errorf("summary ID = %d, want the bot's summary 1", summaryID)
+	}
+	if len(posted) != 1 || !posted[FindingFingerprint(models.PositionedComment{FilePath: "main.go", OriginalLine: "f()"})] {
+		t.Errorf("posted fingerprints = %v, want only the bot's finding", posted)
+	}
+}
+
+func TestBitbucketPostInlineComment(t *testing.T) {
+	segment := func(segmentType string, lines ...string) map[string]any {
+		var values []map[string]string
+		for _, line := range lines {
+			values = append(values, map[string]string{"line": line})
+		}
+		return map[string]any{"type": segmentType, "lines": values}
+	}
+	changes := map[string]any{
+		"diffs": []map[string]any{
+			{
+				"source":      map[string]string{"toString": "old.go"},
+				"destination": map[string]string{"toString": "main.go"},
+				"hunks": []map[string]any{{
+					"sourceLine": 1, "sourceSpan": 3, "destinationLine": 1, "destinationSpan": 3,
+					"segments": []map[string]any{
+						segment("CONTEXT", "package main"),
+						segment("REMOVED", `import "os"`),
+						segment("ADDED", `import "fmt"`),
+						segment("CONTEXT", "func main() {}"),
+					},
+				}},
+			},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		comment models.PositionedComment
+		// wantAnchor is the expected comment anchor, nil when the finding
+		// falls back to a general comment
+		wantAnchor map[string]any
+		wantText   string
+	}{
+		{
+			name:    "added line",
+			comment: models.PositionedComment{FilePath: "main.go", LineNumber: 3, LineType: "new", Severity: "LOW", Comment: "Unused import"},
+			wantAnchor: map[string]any{
+				"diffType": "EFFECTIVE",
+				"path":     "main.go",
+				"srcPath":  "old.go",
+				"line":     float64(2),
+				"lineType": "ADDED",
+				"fileType": "TO",
+			},
+			wantText: "Unused import",
+		},
+		{
+			name:    "removed line",
+			comment: models.PositionedComment{FilePath: "main.go", LineNumber: 2, LineType: "old", Comment: "Still needed"},
+			wantAnchor: map[string]any{
+				"line":     float64(2),
+				"lineType": "REMOVED",
+				"fileType": "FROM",
+			},
+			wantText: "Still needed",
+		},
+		{
+			name:    "context line with a suggestion",
+			comment: models.PositionedComment{FilePath: "main.go", LineNumber: 4, Comment: "Add a body", Suggestion: "func main() { run() }"},
+			wantAnchor: map[string]any{
+				"line":     float64(3),
+				"lineType": "CONTEXT",
+				"fileType": "TO",
+			},
+			wantText: "**Suggested change:**\n```\nfunc main() { run() }\n```",
+		},
+		{
+			name:     "line outside the diff",
+			comment:  models.PositionedComment{FilePath: "main.go", LineNumber: 9, LineType: "new", Comment: "Out of range"},
+			wantText: "**File: main.go (Line 9)**",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var posted []map[string]any
+			bitbucket := newBitbucketTestService(t, map[string]http.HandlerFunc{
+				"GET " + bitbucketTestPRPath + "/diff": respondJSON(t, changes),
+				"POST " + bitbucketTestPRPath + "/comments": func(w http.ResponseWriter, r *http.Request) {
+					var request map[string]any
+					captureJSON(t, &request)(w, r)
+					posted = append(posted, request)
+				},
+			})
+
+			if err := bitbucket.PostInlineComment("ACME/api", 7, tt.comment); err != nil {
+				t.Fatalf("PostInlineComment() error = %v", err)
+			}
+			if len(posted) != 1 {
+				t.Fatalf("posted %d comments, want 1", len(posted))
+			}
+
+			anchor, hasAnchor := posted[0]["anchor"].(map[string]any)
+			if tt.wantAnchor == nil && hasAnchor {
+				t.Errorf("posted anchored comment %v, want a general comment", anchor)
+			}
+			if tt.wantAnchor != nil && !hasAnchor {
+				t.Fatalf("posted general comment %v, want an anchored comment", posted[0])
+			}
+			for key, want := range tt.wantAnchor {
+				if anchor[key] != want {
+					t.Errorf("anchor %s = %v, want %v", key, anchor[key], want)
+				}
+			}
+			text, _ := posted[0]["text"].(string)
+			if !strings.Contains(text, tt.wantText) {
+				t.Errorf("text = %q, want it to contain %q", text, tt.wantText)
+			}
+			if _, _, ok := parseBotMarker(text); !ok {
+				t.Errorf("text %q carries no bot marker", text)
+			}
+		})
+	}
+}
+
+func TestBitbucketBotUsername(t *testing.T) {
+	bitbucket := newBitbucketTestService(t, map[string]http.HandlerFunc{
+		"GET /api/1.0/application-properties": func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-AUSERNAME", "whytho-bot")
+			respondJSON(t, map[string]string{"version": "8.9.0"})(w, r)
+		},
+	})
+
+	username, err := bitbucket.BotUsername()
+	if err != nil || username != "whytho-bot" {
+		t.Errorf("BotUsername() = %q, %v, want whytho-bot", username, err)
+	}
+}
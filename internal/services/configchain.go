@@ -0,0 +1,276 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/vinamra28/whytho/internal/models"
+)
+
+// ConfigResolver assembles the .whytho/ configuration of a review from a
+// chain of layers, lowest precedence first: the server defaults, the config
+// repository of each group the repository belongs to, outermost first, and
+// the repository itself. The files of the shared layers, the defaults and the
+// group config repositories, are cached.
+type ConfigResolver struct {
+	// defaults is the directory holding the server default .whytho/ files
+	defaults *LocalRepository
+	// groupRepo is the name of the config repository in each group
+	groupRepo string
+	ttl       time.Duration
+
+	mu     sync.Mutex
+	files  map[string]cachedFile
+	groups map[string]cachedGroups
+}
+
+type cachedFile struct {
+	content string
+	expires time.Time
+}
+
+type cachedGroups struct {
+	groups  []string
+	expires time.Time
+}
+
+// NewConfigResolver creates a resolver. An empty defaultsDir or groupRepo
+// disables that layer, so the zero configuration reads the repository only.
+func NewConfigResolver(defaultsDir, groupRepo string, ttl time.Duration) *ConfigResolver {
+	c := &ConfigResolver{
+		groupRepo: groupRepo,
+		ttl:       ttl,
+		files:     make(map[string]cachedFile),
+		groups:    make(map[string]cachedGroups),
+	}
+	if defaultsDir != "" {
+		c.defaults = NewLocalRepository(defaultsDir, "")
+	}
+	return c
+}
+
+// configLayer is a repository .whytho/ files are read from.
+type configLayer struct {
+	// Name describes the layer in logs and guidance headings
+	Name     string
+	provider SCMProvider
+	repo     string
+	// ref is the branch the files are read from, "" for the default branch
+	ref string
+	// shared layers apply to many repositories and are cached
+	shared bool
+}
+
+// ResolvedConfig is the configuration merged from every layer.
+//
+// Later layers replace includePaths and gating and the files of a guidance
+// pattern they also map, and add their excludePaths and overrides after the
+// earlier ones, so a "!" pattern or an override can undo an inherited one.
+type ResolvedConfig struct {
+	Config *models.WhyThoConfig
+	// Guidance combines the guidance.md of every layer, later layers last
+	Guidance string
+	// Layers names the project and the shared layers that had .whytho/ files,
+	// lowest precedence first
+	Layers []string
+	// guidanceLayers is the layer that mapped each guidance pattern, which
+	// the pattern's files are read from
+	guidanceLayers map[string]configLayer
+	guidance       []layerGuidance
+}
+
+type layerGuidance struct {
+	layer   string
+	content string
+}
+
+// Resolve loads and merges the configuration of a review. The repository's
+// own config.yaml is the version modified by the pull request, if any, see
+// LoadWhyThoConfig. Layers that fail to load are skipped with a warning.
+func (c *ConfigResolver) Resolve(target ReviewTarget, changes []models.MRChange) *ResolvedConfig {
+	resolved := &ResolvedConfig{
+		Config:         &models.WhyThoConfig{ExcludePaths: []string{}},
+		guidanceLayers: make(map[string]configLayer),
+	}
+
+	for _, layer := range c.sharedLayers(target) {
+		content, err := c.readFile(layer, whyThoConfigPath)
+		if err != nil {
+			logrus.WithError(err).WithFields(logrus.Fields{
+				"project_id": target.Repo,
+				"layer":      layer.Name,
+			}).Warn("Failed to fetch inherited WhyTho config, skipping it")
+			continue
+		}
+		config := &models.WhyThoConfig{}
+		if content != "" {
+			if config, err = parseWhyThoConfig(content); err != nil {
+				logrus.WithError(err).WithFields(logrus.Fields{
+					"project_id": target.Repo,
+					"layer":      layer.Name,
+				}).Warn("Failed to parse inherited WhyTho config, skipping it")
+				continue
+			}
+		}
+		guidance, err := c.readFile(layer, whyThoGuidancePath)
+		if err != nil {
+			logrus.WithError(err).WithFields(logrus.Fields{
+				"project_id": target.Repo,
+				"layer":      layer.Name,
+			}).Warn("Failed to fetch inherited review guidance, skipping it")
+			guidance = ""
+		}
+		if content != "" || guidance != "" {
+			resolved.add(layer, config, guidance)
+		}
+	}
+
+	project := configLayer{Name: "project", provider: target.Provider, repo: target.Repo, ref: target.TargetBranch}
+	config, err := LoadWhyThoConfig(target.Provider, target.Repo, target.Number, target.TargetBranch, target.HeadRef, changes)
+	if err != nil {
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"project_id": target.Repo,
+			"mr_iid":     target.Number,
+		}).Warn("Failed to fetch WhyTho config, using the inherited configuration only")
+		config = &models.WhyThoConfig{}
+	}
+	guidance, err := LoadReviewGuidance(target.Provider, target.Repo, target.TargetBranch)
+	if err != nil {
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"project_id": target.Repo,
+			"branch":     target.TargetBranch,
+		}).Warn("Failed to fetch custom review guidance, using the inherited guidance only")
+		guidance = ""
+	}
+	resolved.add(project, config, guidance)
+	resolved.Guidance = resolved.combinedGuidance()
+
+	if len(resolved.Layers) > 1 {
+		logrus.WithFields(logrus.Fields{
+			"project_id": target.Repo,
+			"mr_iid":     target.Number,
+			"layers":     resolved.Layers,
+		}).Info("Resolved inherited WhyTho config")
+	}
+	return resolved
+}
+
+// sharedLayers lists the server defaults and the group config repositories
+// that apply to the target, lowest precedence first.
+func (c *ConfigResolver) sharedLayers(target ReviewTarget) []configLayer {
+	var layers []configLayer
+	if c.defaults != nil {
+		layers = append(layers, configLayer{Name: "server defaults", provider: c.defaults, shared: true})
+	}
+	if c.groupRepo == "" {
+		return layers
+	}
+
+	groups, err := c.repoGroups(target.Provider, target.Repo)
+	if err != nil {
+		logrus.WithError(err).WithField("project_id", target.Repo).Warn("Failed to look up repository groups, skipping group config")
+		return layers
+	}
+	for _, group := range groups {
+		repo := group + "/" + c.groupRepo
+		if repo == target.Repo {
+			continue
+		}
+		layers = append(layers, configLayer{Name: "group " + group, provider: target.Provider, repo: repo, shared: true})
+	}
+	return layers
+}
+
+// repoGroups looks up the groups of a repository through the cache.
+func (c *ConfigResolver) repoGroups(provider SCMProvider, repo string) ([]string, error) {
+	key := provider.Name() + ":" + repo
+	c.mu.Lock()
+	entry, ok := c.groups[key]
+	c.mu.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry.groups, nil
+	}
+
+	groups, err := repoGroups(provider, repo)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.groups[key] = cachedGroups{groups: groups, expires: time.Now().Add(c.ttl)}
+	c.mu.Unlock()
+	return groups, nil
+}
+
+// readFile reads a file of the layer, returning "" when it does not exist.
+// Files of shared layers, missing ones included, are cached.
+func (c *ConfigResolver) readFile(layer configLayer, filePath string) (string, error) {
+	key := fmt.Sprintf("%s:%s@%s:%s", layer.provider.Name(), layer.repo, layer.ref, filePath)
+	if layer.shared {
+		c.mu.Lock()
+		entry, ok := c.files[key]
+		c.mu.Unlock()
+		if ok && time.Now().Before(entry.expires) {
+			return entry.content, nil
+		}
+	}
+
+	content, err := layer.provider.GetFile(layer.repo, filePath, layer.ref)
+	if err != nil && !errors.Is(err, ErrFileNotFound) {
+		return "", fmt.Errorf("failed to fetch %s from %s: %w", filePath, layer.Name, err)
+	}
+
+	if layer.shared {
+		c.mu.Lock()
+		c.files[key] = cachedFile{content: content, expires: time.Now().Add(c.ttl)}
+		c.mu.Unlock()
+	}
+	return content, nil
+}
+
+// add merges a layer over the configuration resolved so far.
+func (r *ResolvedConfig) add(layer configLayer, config *models.WhyThoConfig, guidance string) {
+	r.Layers = append(r.Layers, layer.Name)
+	merged := r.Config
+
+	if len(config.IncludePaths) > 0 {
+		merged.IncludePaths = config.IncludePaths
+	}
+	merged.ExcludePaths = append(merged.ExcludePaths, config.ExcludePaths...)
+	merged.Overrides = append(merged.Overrides, config.Overrides...)
+	for pattern, files := range config.Guidance {
+		if merged.Guidance == nil {
+			merged.Guidance = make(map[string]models.GuidanceFiles)
+		}
+		merged.Guidance[pattern] = files
+		r.guidanceLayers[pattern] = layer
+	}
+	if config.Gating != nil {
+		merged.Gating = config.Gating
+	}
+
+	if strings.TrimSpace(guidance) != "" {
+		r.guidance = append(r.guidance, layerGuidance{layer: layer.Name, content: strings.TrimSpace(guidance)})
+	}
+}
+
+// combinedGuidance joins the guidance of the layers, headed by their names
+// when there are several.
+func (r *ResolvedConfig) combinedGuidance() string {
+	switch len(r.guidance) {
+	case 0:
+		return ""
+	case 1:
+		return r.guidance[0].content
+	}
+
+	var combined strings.Builder
+	combined.WriteString("Where the sections below conflict, later sections take precedence.")
+	for _, section := range r.guidance {
+		combined.WriteString(fmt.Sprintf("\n\n## Guidance from %s\n\n%s", section.layer, section.content))
+	}
+	return combined.String()
+}
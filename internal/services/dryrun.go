@@ -0,0 +1,198 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/vinamra28/whytho/internal/models"
+)
+
+// Kinds of the writes recorded by a dry run.
+const (
+	DryRunNoteInline  = "inline"
+	DryRunNoteGeneral = "general"
+	DryRunNoteUpdate  = "update"
+	DryRunNoteResolve = "resolve"
+	DryRunNoteStatus  = "status"
+)
+
+// DryRunArtifact collects what a review would have posted, together with
+// every prompt and raw LLM response, so a review can be inspected without
+// touching the merge request.
+type DryRunArtifact struct {
+	mu sync.Mutex
+
+	Provider  string    `json:"provider"`
+	Repo      string    `json:"repo"`
+	Number    int       `json:"number"`
+	StartedAt time.Time `json:"started_at"`
+	// Payload is the webhook or job payload that started the review
+	Payload      json.RawMessage `json:"payload,omitempty"`
+	Notes        []DryRunNote    `json:"notes"`
+	LLMExchanges []LLMExchange   `json:"llm_exchanges"`
+	Error        string          `json:"error,omitempty"`
+}
+
+// DryRunNote is a write the review would have made.
+type DryRunNote struct {
+	Kind string `json:"kind"`
+	// Target is the comment, discussion or commit an update, resolution or status applies to
+	Target   string `json:"target,omitempty"`
+	FilePath string `json:"file_path,omitempty"`
+	OldLine  int    `json:"old_line,omitempty"`
+	NewLine  int    `json:"new_line,omitempty"`
+	Body     string `json:"body"`
+}
+
+// LLMExchange is a prompt sent to the LLM and its raw response.
+type LLMExchange struct {
+	Prompt   string `json:"prompt"`
+	Response string `json:"response"`
+	Error    string `json:"error,omitempty"`
+}
+
+func NewDryRunArtifact(provider, repo string, number int, payload []byte) *DryRunArtifact {
+	artifact := &DryRunArtifact{
+		Provider:  provider,
+		Repo:      repo,
+		Number:    number,
+		StartedAt: time.Now().UTC(),
+		// Empty lists are written as [] rather than null
+		Notes:        []DryRunNote{},
+		LLMExchanges: []LLMExchange{},
+	}
+	if json.Valid(payload) {
+		artifact.Payload = payload
+	}
+	return artifact
+}
+
+// AddNote records a write other than an inline comment.
+func (a *DryRunArtifact) AddNote(kind, target, body string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.Notes = append(a.Notes, DryRunNote{Kind: kind, Target: target, Body: body})
+}
+
+// AddInlineComment records a finding with the file lines it would be posted on.
+func (a *DryRunArtifact) AddInlineComment(changes []models.MRChange, comment models.PositionedComment) {
+	note := DryRunNote{Kind: DryRunNoteInline, FilePath: comment.FilePath}
+	if resolved, err := ResolveDiffPosition(changes, comment); err == nil {
+		note.OldLine = resolved.OldLineNumber
+		note.NewLine = resolved.NewLineNumber
+		note.Body = InlineCommentBody(resolved, formatSuggestion(resolved, false))
+	} else {
+		// The provider would fall back to a general comment
+		note.Body = UnpositionedFindingBody(comment)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.Notes = append(a.Notes, note)
+}
+
+func (a *DryRunArtifact) addLLMExchange(prompt, response string, err error) {
+	exchange := LLMExchange{Prompt: prompt, Response: response}
+	if err != nil {
+		exchange.Error = err.Error()
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.LLMExchanges = append(a.LLMExchanges, exchange)
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// Write records the review's error, if any, and writes the artifact as JSON
+// to dir, returning the file's path.
+func (a *DryRunArtifact) Write(dir string, reviewErr error) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if reviewErr != nil {
+		a.Error = reviewErr.Error()
+	}
+
+	content, err := json.MarshalIndent(a, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to encode dry run artifact: %w", err)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create dry run directory: %w", err)
+	}
+
+	name := fmt.Sprintf("%s-%s-%s-%s.json", a.StartedAt.Format("20060102T150405.000"), a.Provider,
+		unsafeFileChars.ReplaceAllString(a.Repo, "_"), strconv.Itoa(a.Number))
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, content, 0o644); err != nil {
+		return "", fmt.Errorf("failed to write dry run artifact: %w", err)
+	}
+	return path, nil
+}
+
+// recordingLLM records every exchange with the wrapped provider in an artifact.
+type recordingLLM struct {
+	LLMProvider
+	artifact *DryRunArtifact
+}
+
+func (l *recordingLLM) Generate(ctx context.Context, req LLMRequest) (string, error) {
+	response, err := l.LLMProvider.Generate(ctx, req)
+	l.artifact.addLLMExchange(req.Prompt, response, err)
+	return response, err
+}
+
+// WithDryRun returns a copy of the service that records its LLM exchanges in
+// the artifact.
+func (r *ReviewService) WithDryRun(artifact *DryRunArtifact) *ReviewService {
+	recording := *r
+	recording.llm = &recordingLLM{LLMProvider: r.llm, artifact: artifact}
+	return &recording
+}
+
+// dryRunProvider reads through the wrapped provider and records its writes.
+type dryRunProvider struct {
+	SCMProvider
+	artifact *DryRunArtifact
+}
+
+// NewDryRunProvider wraps a provider so that comments and statuses are
+// recorded in the artifact instead of being posted.
+func NewDryRunProvider(provider SCMProvider, artifact *DryRunArtifact) SCMProvider {
+	return &dryRunProvider{SCMProvider: provider, artifact: artifact}
+}
+
+func (p *dryRunProvider) RepoGroups(repo string) ([]string, error) {
+	return repoGroups(p.SCMProvider, repo)
+}
+
+func (p *dryRunProvider) PostInlineComment(repo string, number int, comment models.PositionedComment) error {
+	changes, err := p.GetChanges(repo, number)
+	if err != nil {
+		return err
+	}
+	p.artifact.AddInlineComment(changes, comment)
+	return nil
+}
+
+func (p *dryRunProvider) PostGeneralComment(repo string, number int, body string) error {
+	p.artifact.AddNote(DryRunNoteGeneral, "", body)
+	return nil
+}
+
+func (p *dryRunProvider) UpdateComment(repo string, number int, commentID int64, body string) error {
+	p.artifact.AddNote(DryRunNoteUpdate, strconv.FormatInt(commentID, 10), body)
+	return nil
+}
+
+func (p *dryRunProvider) SetStatus(repo, sha, state, description, targetURL string) error {
+	p.artifact.AddNote(DryRunNoteStatus, sha, state+": "+description)
+	return nil
+}
@@ -0,0 +1,108 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/vinamra28/whytho/internal/diff"
+	"github.com/vinamra28/whytho/internal/models"
+)
+
+const (
+	BotNoteKindFinding = "finding"
+	BotNoteKindComment = "comment"
+	BotNoteKindSummary = "summary"
+	// BotNoteKindIgnore marks a merge request opted out of automatic reviews
+	BotNoteKindIgnore = "ignore"
+	// BotNoteKindConfig marks the note reporting invalid .whytho/ files
+	BotNoteKindConfig = "config"
+)
+
+// Bot notes carry a hidden HTML comment so later runs can recognise them, e.g.
+// <!-- whytho:finding fingerprint=3f2a.. anchor=9c1b.. severity=HIGH -->
+var botMarkerPattern = regexp.MustCompile(`<!-- whytho:(\w+)((?: \w+=[^ ]*)*) -->`)
+
+// FindingFingerprint identifies a positioned finding by file, anchor line
+// content and rule, so it stays stable when surrounding lines shift.
+func FindingFingerprint(comment models.PositionedComment) string {
+	return shortHash(comment.FilePath + "\n" + normalizeAnchor(comment.OriginalLine) + "\n" + comment.Rule)
+}
+
+// CommentFingerprint identifies a general comment by its text.
+func CommentFingerprint(comment string) string {
+	return shortHash(strings.Join(strings.Fields(comment), " "))
+}
+
+// AnchorHash hashes the content of the line a finding is attached to.
+func AnchorHash(line string) string {
+	return shortHash(normalizeAnchor(line))
+}
+
+// AnchorHashes returns the anchor hashes of every line present on the new
+// side of a diff (added and context lines).
+func AnchorHashes(rawDiff string) map[string]bool {
+	hashes := make(map[string]bool)
+	for _, line := range diff.Parse(rawDiff).Lines() {
+		if line.Type != diff.LineRemoved {
+			hashes[AnchorHash(line.Content)] = true
+		}
+	}
+	return hashes
+}
+
+func findingMarker(comment models.PositionedComment) string {
+	return fmt.Sprintf("<!-- whytho:%s fingerprint=%s anchor=%s severity=%s -->",
+		BotNoteKindFinding, FindingFingerprint(comment), AnchorHash(comment.OriginalLine), comment.Severity)
+}
+
+func commentMarker(comment string) string {
+	return fmt.Sprintf("<!-- whytho:%s fingerprint=%s -->", BotNoteKindComment, CommentFingerprint(comment))
+}
+
+func summaryMarker(headSHA string) string {
+	if headSHA == "" {
+		return fmt.Sprintf("<!-- whytho:%s -->", BotNoteKindSummary)
+	}
+	return fmt.Sprintf("<!-- whytho:%s sha=%s -->", BotNoteKindSummary, headSHA)
+}
+
+func ignoreMarker() string {
+	return fmt.Sprintf("<!-- whytho:%s -->", BotNoteKindIgnore)
+}
+
+func configMarker(fingerprint string) string {
+	return fmt.Sprintf("<!-- whytho:%s fingerprint=%s -->", BotNoteKindConfig, fingerprint)
+}
+
+// parseBotMarker extracts the kind and attributes of a bot marker in a note body.
+func parseBotMarker(body string) (string, map[string]string, bool) {
+	match := botMarkerPattern.FindStringSubmatch(body)
+	if match == nil {
+		return "", nil, false
+	}
+
+	attributes := make(map[string]string)
+	for _, field := range strings.Fields(match[2]) {
+		if key, value, ok := strings.Cut(field, "="); ok {
+			attributes[key] = value
+		}
+	}
+	return match[1], attributes, true
+}
+
+// stripBotMarkers removes the hidden bot markers from a note body.
+func stripBotMarkers(body string) string {
+	return strings.TrimSpace(botMarkerPattern.ReplaceAllString(body, ""))
+}
+
+func normalizeAnchor(line string) string {
+	return strings.Join(strings.Fields(line), " ")
+}
+
+func shortHash(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])[:16]
+}
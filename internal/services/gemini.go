@@ -0,0 +1,63 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/genai"
+)
+
+const defaultGeminiModel = "gemini-2.5-pro"
+
+type GeminiProvider struct {
+	client *genai.Client
+	model  string
+}
+
+func NewGeminiProvider(apiKey, model string) (*GeminiProvider, error) {
+	logrus.Info("Creating Gemini AI client for code review")
+	if model == "" {
+		model = defaultGeminiModel
+	}
+
+	client, err := genai.NewClient(context.Background(), &genai.ClientConfig{
+		APIKey: apiKey,
+	})
+	if err != nil {
+		logrus.WithError(err).Error("Failed to create Gemini client")
+		return nil, fmt.Errorf("failed to create Gemini client: %w", err)
+	}
+
+	logrus.WithField("model", model).Info("Gemini AI client created successfully")
+	return &GeminiProvider{
+		client: client,
+		model:  model,
+	}, nil
+}
+
+func (p *GeminiProvider) Name() string {
+	return LLMProviderGemini
+}
+
+func (p *GeminiProvider) Generate(ctx context.Context, req LLMRequest) (string, error) {
+	content := genai.NewContentFromText(req.Prompt, "user")
+	config := &genai.GenerateContentConfig{
+		Temperature: genai.Ptr(req.Temperature),
+	}
+	if req.ResponseSchema != nil {
+		config.ResponseMIMEType = "application/json"
+		config.ResponseSchema = req.ResponseSchema
+	}
+
+	resp, err := p.client.Models.GenerateContent(ctx, p.model, []*genai.Content{content}, config)
+	if err != nil {
+		return "", fmt.Errorf("gemini request failed: %w", err)
+	}
+
+	if len(resp.Candidates) == 0 {
+		return "", fmt.Errorf("no response generated")
+	}
+
+	return resp.Text(), nil
+}
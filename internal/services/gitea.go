@@ -0,0 +1,247 @@
+package services
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/vinamra28/whytho/internal/diff"
+	"github.com/vinamra28/whytho/internal/models"
+)
+
+// GiteaService implements SCMProvider for Gitea and Forgejo, whose APIs are
+// compatible. Repositories are addressed as "owner/name".
+type GiteaService struct {
+	api     *restClient
+	botUser botUserCache
+}
+
+func NewGiteaService(token, baseURL string) *GiteaService {
+	logrus.WithField("base_url", baseURL).Info("Creating Gitea client")
+	return &GiteaService{
+		api: newRESTClient("Gitea", strings.TrimSuffix(baseURL, "/")+"/api/v1", map[string]string{
+			"Authorization": "token " + token,
+		}),
+	}
+}
+
+func (g *GiteaService) Name() string {
+	return "gitea"
+}
+
+func (g *GiteaService) GetPullRequest(repo string, number int) (*models.PullRequest, error) {
+	var pr struct {
+		Title   string `json:"title"`
+		Body    string `json:"body"`
+		State   string `json:"state"`
+		HTMLURL string `json:"html_url"`
+		Base    struct {
+			Ref string `json:"ref"`
+		} `json:"base"`
+		Head struct {
+			SHA string `json:"sha"`
+		} `json:"head"`
+	}
+	if err := g.api.do(http.MethodGet, fmt.Sprintf("/repos/%s/pulls/%d", repo, number), nil, &pr); err != nil {
+		return nil, fmt.Errorf("failed to get pull request: %w", err)
+	}
+	return &models.PullRequest{
+		Title:        pr.Title,
+		Description:  pr.Body,
+		TargetBranch: pr.Base.Ref,
+		HeadSHA:      pr.Head.SHA,
+		WebURL:       pr.HTMLURL,
+		Open:         pr.State == "open",
+	}, nil
+}
+
+// GetChanges downloads the pull request's unified diff, as Gitea's file list
+// API carries no patches.
+func (g *GiteaService) GetChanges(repo string, number int) ([]models.MRChange, error) {
+	logrus.WithFields(logrus.Fields{
+		"project_id": repo,
+		"mr_iid":     number,
+	}).Debug("Fetching pull request diff")
+
+	raw, err := g.api.send(http.MethodGet, fmt.Sprintf("/repos/%s/pulls/%d.diff", repo, number), nil, "text/plain")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pull request diff: %w", err)
+	}
+	return diff.SplitFiles(string(raw)), nil
+}
+
+func (g *GiteaService) GetFile(repo, path, ref string) (string, error) {
+	content, err := g.api.send(http.MethodGet,
+		fmt.Sprintf("/repos/%s/raw/%s%s", repo, escapePath(path), refQuery("ref", ref)), nil, "*/*")
+	if err != nil {
+		if isNotFound(err) {
+			return "", ErrFileNotFound
+		}
+		return "", fmt.Errorf("failed to get file %s: %w", path, err)
+	}
+	return string(content), nil
+}
+
+// PostInlineComment posts the finding as a single-comment pull request
+// review. Gitea has no suggestion blocks or line ranges, so suggestions are
+// plain code blocks and ranges are anchored at their last line.
+func (g *GiteaService) PostInlineComment(repo string, number int, comment models.PositionedComment) error {
+	pr, err := g.GetPullRequest(repo, number)
+	if err != nil {
+		return err
+	}
+	changes, err := g.GetChanges(repo, number)
+	if err != nil {
+		return err
+	}
+
+	resolved, err := ResolveDiffPosition(changes, comment)
+	if err != nil {
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"project_id":  repo,
+			"mr_iid":      number,
+			"file_path":   comment.FilePath,
+			"line_number": comment.LineNumber,
+		}).Warn("Failed to convert diff line to actual line, falling back to general comment")
+		return g.PostGeneralComment(repo, number, UnpositionedFindingBody(comment))
+	}
+
+	reviewComment := map[string]any{
+		"path": resolved.FilePath,
+		"body": InlineCommentBody(resolved, formatSuggestion(resolved, false)),
+	}
+	if resolved.LineType == "old" {
+		reviewComment["old_position"] = resolved.OldLineNumber
+	} else {
+		reviewComment["new_position"] = resolved.NewLineNumber
+	}
+	request := map[string]any{
+		"commit_id": pr.HeadSHA,
+		"event":     "COMMENT",
+		"comments":  []map[string]any{reviewComment},
+	}
+
+	if err := g.api.do(http.MethodPost, fmt.Sprintf("/repos/%s/pulls/%d/reviews", repo, number), request, nil); err != nil {
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"project_id": repo,
+			"mr_iid":     number,
+			"file_path":  resolved.FilePath,
+			"old_line":   resolved.OldLineNumber,
+			"new_line":   resolved.NewLineNumber,
+		}).Error("Failed to post review comment to Gitea, falling back to general comment")
+		return g.PostGeneralComment(repo, number, UnpositionedFindingBody(comment))
+	}
+	return nil
+}
+
+func (g *GiteaService) PostGeneralComment(repo string, number int, body string) error {
+	if err := g.api.do(http.MethodPost, fmt.Sprintf("/repos/%s/issues/%d/comments", repo, number), map[string]string{"body": body}, nil); err != nil {
+		return fmt.Errorf("failed to post comment: %w", err)
+	}
+	return nil
+}
+
+// ListComments returns the issue comments and the comments of every review.
+func (g *GiteaService) ListComments(repo string, number int) ([]models.SCMComment, error) {
+	type giteaComment struct {
+		ID   int64  `json:"id"`
+		Body string `json:"body"`
+		User struct {
+			Login string `json:"login"`
+		} `json:"user"`
+	}
+	var comments []models.SCMComment
+
+	var issueComments []giteaComment
+	if err := g.api.do(http.MethodGet, fmt.Sprintf("/repos/%s/issues/%d/comments", repo, number), nil, &issueComments); err != nil {
+		return nil, fmt.Errorf("failed to list comments: %w", err)
+	}
+	for _, comment := range issueComments {
+		comments = append(comments, models.SCMComment{ID: comment.ID, Body: comment.Body, Author: comment.User.Login})
+	}
+
+	reviewIDs, err := g.listReviewIDs(repo, number)
+	if err != nil {
+		return nil, err
+	}
+	for _, reviewID := range reviewIDs {
+		var reviewComments []giteaComment
+		if err := g.api.do(http.MethodGet, fmt.Sprintf("/repos/%s/pulls/%d/reviews/%d/comments", repo, number, reviewID), nil, &reviewComments); err != nil {
+			return nil, fmt.Errorf("failed to list review comments: %w", err)
+		}
+		for _, comment := range reviewComments {
+			comments = append(comments, models.SCMComment{ID: comment.ID, Body: comment.Body, Author: comment.User.Login})
+		}
+	}
+	return comments, nil
+}
+
+// giteaPageLimit is the page size requested from paginated Gitea APIs.
+const giteaPageLimit = 50
+
+// listReviewIDs pages through the pull request's reviews. Every inline
+// finding is its own review, so there are often more than fit on one page.
+// The server may cap the page size below giteaPageLimit, so paging stops at
+// the first empty page rather than the first short one.
+func (g *GiteaService) listReviewIDs(repo string, number int) ([]int64, error) {
+	var ids []int64
+	for page := 1; ; page++ {
+		var reviews []struct {
+			ID int64 `json:"id"`
+		}
+		path := fmt.Sprintf("/repos/%s/pulls/%d/reviews?page=%d&limit=%d", repo, number, page, giteaPageLimit)
+		if err := g.api.do(http.MethodGet, path, nil, &reviews); err != nil {
+			return nil, fmt.Errorf("failed to list reviews: %w", err)
+		}
+		if len(reviews) == 0 {
+			return ids, nil
+		}
+		for _, review := range reviews {
+			ids = append(ids, review.ID)
+		}
+	}
+}
+
+func (g *GiteaService) UpdateComment(repo string, number int, commentID int64, body string) error {
+	if err := g.api.do(http.MethodPatch, fmt.Sprintf("/repos/%s/issues/comments/%d", repo, commentID), map[string]string{"body": body}, nil); err != nil {
+		return fmt.Errorf("failed to update comment: %w", err)
+	}
+	return nil
+}
+
+func (g *GiteaService) SetStatus(repo, sha, state, description, targetURL string) error {
+	// Gitea has no running state and calls failures "failure"
+	giteaState := state
+	switch state {
+	case ReviewStatusRunning:
+		giteaState = "pending"
+	case ReviewStatusFailed:
+		giteaState = "failure"
+	}
+
+	request := map[string]string{
+		"state":       giteaState,
+		"description": description,
+		"context":     ReviewStatusName,
+	}
+	if targetURL != "" {
+		request["target_url"] = targetURL
+	}
+	if err := g.api.do(http.MethodPost, fmt.Sprintf("/repos/%s/statuses/%s", repo, sha), request, nil); err != nil {
+		return fmt.Errorf("failed to set commit status: %w", err)
+	}
+	return nil
+}
+
+func (g *GiteaService) BotUsername() (string, error) {
+	return g.botUser.get(func() (string, error) {
+		var user struct {
+			Login string `json:"login"`
+		}
+		if err := g.api.do(http.MethodGet, "/user", nil, &user); err != nil {
+			return "", err
+		}
+		return user.Login, nil
+	})
+}
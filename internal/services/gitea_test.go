@@ -0,0 +1,231 @@
+package services
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/vinamra28/whytho/internal/models"
+)
+
+// newGiteaTestService returns a GiteaService talking to a test server that
+// serves the given routes, keyed by "METHOD path" below /api/v1.
+func newGiteaTestService(t *testing.T, routes map[string]http.HandlerFunc) *GiteaService {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "token test-token" {
+			t.Errorf("Authorization header = %q", got)
+		}
+		handler, ok := routes[r.Method+" "+strings.TrimPrefix(r.URL.Path, "/api/v1")]
+		if !ok {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+			http.NotFound(w, r)
+			return
+		}
+		handler(w, r)
+	}))
+	t.Cleanup(server.Close)
+	return NewGiteaService("test-token", server.URL)
+}
+
+func TestGiteaListComments(t *testing.T) {
+	summary := SummaryCommentBody("Looks good", "abc123")
+	finding := func(anchor string) string {
+		return InlineCommentBody(models.PositionedComment{FilePath: "main.go", OriginalLine: anchor, Severity: "HIGH", Comment: "Unchecked error"}, "")
+	}
+
+	// The server caps pages at two reviews, below the requested limit
+	reviewPages := map[string][]map[string]int64{
+		"1": {{"id": 10}, {"id": 11}},
+		"2": {{"id": 12}},
+	}
+	routes := map[string]http.HandlerFunc{
+		"GET /repos/acme/api/issues/7/comments": respondJSON(t, []githubTestComment{
+			githubComment(1, "whytho-bot", summary),
+			githubComment(2, "mallory", SummaryCommentBody("Fake summary", "abc123")),
+		}),
+		"GET /repos/acme/api/pulls/7/reviews": func(w http.ResponseWriter, r *http.Request) {
+			if limit := r.URL.Query().Get("limit"); limit != strconv.Itoa(giteaPageLimit) {
+				t.Errorf("limit = %q, want %d", limit, giteaPageLimit)
+			}
+			page, ok := reviewPages[r.URL.Query().Get("page")]
+			if !ok {
+				page = []map[string]int64{}
+			}
+			respondJSON(t, page)(w, r)
+		},
+		"GET /repos/acme/api/pulls/7/reviews/10/comments": respondJSON(t, []githubTestComment{githubComment(3, "whytho-bot", finding("f()"))}),
+		"GET /repos/acme/api/pulls/7/reviews/11/comments": respondJSON(t, []githubTestComment{githubComment(4, "mallory", finding("g()"))}),
+		"GET /repos/acme/api/pulls/7/reviews/12/comments": respondJSON(t, []githubTestComment{githubComment(5, "whytho-bot", finding("h()"))}),
+	}
+	gitea := newGiteaTestService(t, routes)
+
+	comments, err := gitea.ListComments("acme/api", 7)
+	if err != nil {
+		t.Fatalf("ListComments() error = %v", err)
+	}
+	wantAuthors := map[int64]string{1: "whytho-bot", 2: "mallory", 3: "whytho-bot", 4: "mallory", 5: "whytho-bot"}
+	if len(comments) != len(wantAuthors) {
+		t.Fatalf("ListComments() returned %d comments, want %d", len(comments), len(wantAuthors))
+	}
+	for _, comment := range comments {
+		if comment.Author != wantAuthors[comment.ID] {
+			t.Errorf("comment %d author = %q, want %q", comment.ID, comment.Author, wantAuthors[comment.ID])
+		}
+	}
+
+	posted, summaryID := BotComments(comments, "whytho-bot")
+	if summaryID != 1 {
+		t.Errorf("summary ID = %d, want the bot's summary 1", summaryID)
+	}
+	// The finding on the second page must still be recognised as posted
+	if len(posted) != 2 {
+		t.Errorf("posted fingerprints = %v, want the bot's two findings", posted)
+	}
+}
+
+func TestGiteaPostInlineComment(t *testing.T) {
+	rawDiff := "diff --git a/main.go b/main.go\n" +
+		"--- a/main.go\n" +
+		"+++ b/main.go\n" +
+		"@@ -1,3 +1,3 @@\n" +
+		" package main\n" +
+		"-import \"os\"\n" +
+		"+import \"fmt\"\n" +
+		" func main() {}\n"
+
+	tests := []struct {
+		name    string
+		comment models.PositionedComment
+		// wantPosition is the expected position field of the review comment,
+		// empty when the finding falls back to a general comment
+		wantPosition string
+		wantLine     float64
+		wantBody     string
+	}{
+		{
+			name:         "added line",
+			comment:      models.PositionedComment{FilePath: "main.go", LineNumber: 3, LineType: "new", Severity: "LOW", Comment: "Unused import"},
+			wantPosition: "new_position",
+			wantLine:     2,
+			wantBody:     "Unused import",
+		},
+		{
+			name:         "removed line",
+			comment:      models.PositionedComment{FilePath: "main.go", LineNumber: 2, LineType: "old", Comment: "Still needed"},
+			wantPosition: "old_position",
+			wantLine:     2,
+			wantBody:     "Still needed",
+		},
+		{
+			name:         "suggestion is a plain code block",
+			comment:      models.PositionedComment{FilePath: "main.go", LineNumber: 3, LineType: "new", Comment: "Use os", Suggestion: `import "os"`},
+			wantPosition: "new_position",
+			wantLine:     2,
+			wantBody:     "**Suggested change:**\n```\nimport \"os\"\n```",
+		},
+		{
+			name:     "line type mismatch",
+			comment:  models.PositionedComment{FilePath: "main.go", LineNumber: 2, LineType: "new", Comment: "Wrong side"},
+			wantBody: "**File: main.go (Line 2)**",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var review, general map[string]any
+			gitea := newGiteaTestService(t, map[string]http.HandlerFunc{
+				"GET /repos/acme/api/pulls/7": respondJSON(t, map[string]any{
+					"title": "Add greeting",
+					"state": "open",
+					"head":  map[string]string{"sha": "abc123"},
+					"base":  map[string]string{"ref": "main"},
+				}),
+				"GET /repos/acme/api/pulls/7.diff": func(w http.ResponseWriter, r *http.Request) {
+					w.Write([]byte(rawDiff))
+				},
+				"POST /repos/acme/api/pulls/7/reviews":   captureJSON(t, &review),
+				"POST /repos/acme/api/issues/7/comments": captureJSON(t, &general),
+			})
+
+			if err := gitea.PostInlineComment("acme/api", 7, tt.comment); err != nil {
+				t.Fatalf("PostInlineComment() error = %v", err)
+			}
+
+			var body string
+			if tt.wantPosition == "" {
+				if review != nil {
+					t.Fatalf("posted review %v, want a general comment", review)
+				}
+				if general == nil {
+					t.Fatal("nothing was posted")
+				}
+				body, _ = general["body"].(string)
+			} else {
+				if general != nil {
+					t.Fatalf("posted general comment %v, want a review", general)
+				}
+				if review == nil {
+					t.Fatal("nothing was posted")
+				}
+				if review["commit_id"] != "abc123" || review["event"] != "COMMENT" {
+					t.Errorf("review = %v, want a COMMENT review on abc123", review)
+				}
+				reviewComments, _ := review["comments"].([]any)
+				if len(reviewComments) != 1 {
+					t.Fatalf("review comments = %v, want one", review["comments"])
+				}
+				comment, _ := reviewComments[0].(map[string]any)
+				if comment["path"] != "main.go" {
+					t.Errorf("path = %v, want main.go", comment["path"])
+				}
+				if comment[tt.wantPosition] != tt.wantLine {
+					t.Errorf("%s = %v, want %v (comment %v)", tt.wantPosition, comment[tt.wantPosition], tt.wantLine, comment)
+				}
+				body, _ = comment["body"].(string)
+			}
+			if !strings.Contains(body, tt.wantBody) {
+				t.Errorf("body = %q, want it to contain %q", body, tt.wantBody)
+			}
+			if _, _, ok := parseBotMarker(body); !ok {
+				t.Errorf("body %q carries no bot marker", body)
+			}
+		})
+	}
+}
+
+func TestGiteaSetStatus(t *testing.T) {
+	tests := []struct {
+		state     string
+		wantState string
+	}{
+		{ReviewStatusPending, "pending"},
+		{ReviewStatusRunning, "pending"},
+		{ReviewStatusSuccess, "success"},
+		{ReviewStatusFailed, "failure"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.state, func(t *testing.T) {
+			var request map[string]string
+			gitea := newGiteaTestService(t, map[string]http.HandlerFunc{
+				"POST /repos/acme/api/statuses/abc123": captureJSON(t, &request),
+			})
+
+			if err := gitea.SetStatus("acme/api", "abc123", tt.state, "Review completed", ""); err != nil {
+				t.Fatalf("SetStatus() error = %v", err)
+			}
+			if request["state"] != tt.wantState {
+				t.Errorf("state = %q, want %q", request["state"], tt.wantState)
+			}
+			if request["context"] != ReviewStatusName {
+				t.Errorf("context = %q, want %q", request["context"], ReviewStatusName)
+			}
+			if _, ok := request["target_url"]; ok {
+				t.Errorf("target_url = %q, want it omitted", request["target_url"])
+			}
+		})
+	}
+}
@@ -0,0 +1,281 @@
+package services
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/vinamra28/whytho/internal/models"
+)
+
+// GitHubService implements SCMProvider for GitHub and GitHub Enterprise
+// Server. Repositories are addressed as "owner/name".
+type GitHubService struct {
+	api     *restClient
+	botUser botUserCache
+}
+
+func NewGitHubService(token, baseURL string) *GitHubService {
+	logrus.WithField("base_url", baseURL).Info("Creating GitHub client")
+	return &GitHubService{
+		api: newRESTClient("GitHub", baseURL, map[string]string{
+			"Authorization":        "Bearer " + token,
+			"X-GitHub-Api-Version": "2022-11-28",
+		}),
+	}
+}
+
+func (g *GitHubService) Name() string {
+	return "github"
+}
+
+type githubPullRequest struct {
+	Title   string `json:"title"`
+	Body    string `json:"body"`
+	State   string `json:"state"`
+	HTMLURL string `json:"html_url"`
+	Base    struct {
+		Ref string `json:"ref"`
+	} `json:"base"`
+	Head struct {
+		SHA string `json:"sha"`
+	} `json:"head"`
+}
+
+func (g *GitHubService) GetPullRequest(repo string, number int) (*models.PullRequest, error) {
+	var pr githubPullRequest
+	if err := g.api.do(http.MethodGet, fmt.Sprintf("/repos/%s/pulls/%d", repo, number), nil, &pr); err != nil {
+		return nil, fmt.Errorf("failed to get pull request: %w", err)
+	}
+	return &models.PullRequest{
+		Title:        pr.Title,
+		Description:  pr.Body,
+		TargetBranch: pr.Base.Ref,
+		HeadSHA:      pr.Head.SHA,
+		WebURL:       pr.HTMLURL,
+		Open:         pr.State == "open",
+	}, nil
+}
+
+func (g *GitHubService) GetChanges(repo string, number int) ([]models.MRChange, error) {
+	logrus.WithFields(logrus.Fields{
+		"project_id": repo,
+		"mr_iid":     number,
+	}).Debug("Fetching pull request files")
+
+	var changes []models.MRChange
+	for page := 1; ; page++ {
+		var files []struct {
+			Filename         string `json:"filename"`
+			PreviousFilename string `json:"previous_filename"`
+			Status           string `json:"status"`
+			Patch            string `json:"patch"`
+		}
+		path := fmt.Sprintf("/repos/%s/pulls/%d/files?per_page=100&page=%d", repo, number, page)
+		if err := g.api.do(http.MethodGet, path, nil, &files); err != nil {
+			return nil, fmt.Errorf("failed to get pull request files: %w", err)
+		}
+
+		for _, file := range files {
+			oldPath := file.Filename
+			if file.PreviousFilename != "" {
+				oldPath = file.PreviousFilename
+			}
+			changes = append(changes, models.MRChange{
+				OldPath:     oldPath,
+				NewPath:     file.Filename,
+				NewFile:     file.Status == "added",
+				RenamedFile: file.Status == "renamed",
+				DeletedFile: file.Status == "removed",
+				Diff:        file.Patch,
+			})
+		}
+		if len(files) < 100 {
+			return changes, nil
+		}
+	}
+}
+
+func (g *GitHubService) GetFile(repo, path, ref string) (string, error) {
+	content, err := g.api.send(http.MethodGet,
+		fmt.Sprintf("/repos/%s/contents/%s%s", repo, escapePath(path), refQuery("ref", ref)),
+		nil, "application/vnd.github.raw+json")
+	if err != nil {
+		if isNotFound(err) {
+			return "", ErrFileNotFound
+		}
+		return "", fmt.Errorf("failed to get file %s: %w", path, err)
+	}
+	return string(content), nil
+}
+
+// PostInlineComment posts a pull request review comment on the finding's
+// line. Suggestions become GitHub suggestion blocks spanning the replaced lines.
+func (g *GitHubService) PostInlineComment(repo string, number int, comment models.PositionedComment) error {
+	pr, err := g.GetPullRequest(repo, number)
+	if err != nil {
+		return err
+	}
+	changes, err := g.GetChanges(repo, number)
+	if err != nil {
+		return err
+	}
+
+	resolved, err := ResolveDiffPosition(changes, comment)
+	if err != nil {
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"project_id":  repo,
+			"mr_iid":      number,
+			"file_path":   comment.FilePath,
+			"line_number": comment.LineNumber,
+		}).Warn("Failed to convert diff line to actual line, falling back to general comment")
+		return g.PostGeneralComment(repo, number, UnpositionedFindingBody(comment))
+	}
+
+	request := map[string]any{
+		"commit_id": pr.HeadSHA,
+		"path":      resolved.FilePath,
+	}
+	suggestion := ""
+	side, line := githubSide(diffLineType(resolved.LineType), resolved.OldLineNumber, resolved.NewLineNumber)
+	switch {
+	case resolved.Suggestion != "" && side == "RIGHT":
+		// The suggestion replaces every selected line
+		start := resolved.NewLineNumber - resolved.SuggestionLinesAbove
+		line = resolved.NewLineNumber + resolved.SuggestionLinesBelow
+		if start < line {
+			request["start_line"] = start
+			request["start_side"] = "RIGHT"
+		}
+		suggestion = fmt.Sprintf("\n\n```suggestion\n%s\n```", resolved.Suggestion)
+	case resolved.StartLineCode != "":
+		startSide, startLine := githubSide(resolved.StartLineType, resolved.StartOldLineNumber, resolved.StartNewLineNumber)
+		request["start_line"] = startLine
+		request["start_side"] = startSide
+		suggestion = formatSuggestion(resolved, false)
+	default:
+		suggestion = formatSuggestion(resolved, false)
+	}
+	request["line"] = line
+	request["side"] = side
+	request["body"] = InlineCommentBody(resolved, suggestion)
+
+	if err := g.api.do(http.MethodPost, fmt.Sprintf("/repos/%s/pulls/%d/comments", repo, number), request, nil); err != nil {
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"project_id": repo,
+			"mr_iid":     number,
+			"file_path":  resolved.FilePath,
+			"line":       line,
+			"side":       side,
+		}).Error("Failed to post review comment to GitHub, falling back to general comment")
+		return g.PostGeneralComment(repo, number, UnpositionedFindingBody(comment))
+	}
+	return nil
+}
+
+// githubSide maps a diff line to the side and line number GitHub addresses it by.
+func githubSide(lineType string, oldLine, newLine int) (string, int) {
+	if lineType == "-" {
+		return "LEFT", oldLine
+	}
+	return "RIGHT", newLine
+}
+
+func (g *GitHubService) PostGeneralComment(repo string, number int, body string) error {
+	if err := g.api.do(http.MethodPost, fmt.Sprintf("/repos/%s/issues/%d/comments", repo, number), map[string]string{"body": body}, nil); err != nil {
+		return fmt.Errorf("failed to post comment: %w", err)
+	}
+	return nil
+}
+
+func (g *GitHubService) ListComments(repo string, number int) ([]models.SCMComment, error) {
+	var comments []models.SCMComment
+	for _, endpoint := range []string{"issues", "pulls"} {
+		for page := 1; ; page++ {
+			var batch []struct {
+				ID   int64  `json:"id"`
+				Body string `json:"body"`
+				User struct {
+					Login string `json:"login"`
+				} `json:"user"`
+			}
+			path := fmt.Sprintf("/repos/%s/%s/%d/comments?per_page=100&page=%d", repo, endpoint, number, page)
+			if err := g.api.do(http.MethodGet, path, nil, &batch); err != nil {
+				return nil, fmt.Errorf("failed to list comments: %w", err)
+			}
+			for _, comment := range batch {
+				comments = append(comments, models.SCMComment{ID: comment.ID, Body: comment.Body, Author: comment.User.Login})
+			}
+			if len(batch) < 100 {
+				break
+			}
+		}
+	}
+	return comments, nil
+}
+
+func (g *GitHubService) UpdateComment(repo string, number int, commentID int64, body string) error {
+	if err := g.api.do(http.MethodPatch, fmt.Sprintf("/repos/%s/issues/comments/%d", repo, commentID), map[string]string{"body": body}, nil); err != nil {
+		return fmt.Errorf("failed to update comment: %w", err)
+	}
+	return nil
+}
+
+func (g *GitHubService) SetStatus(repo, sha, state, description, targetURL string) error {
+	// GitHub has no running state and calls failures "failure"
+	githubState := state
+	switch state {
+	case ReviewStatusRunning:
+		githubState = "pending"
+	case ReviewStatusFailed:
+		githubState = "failure"
+	}
+	// The limit counts characters, so cut on runes to keep the text valid UTF-8
+	if runes := []rune(description); len(runes) > 140 {
+		description = string(runes[:137]) + "..."
+	}
+
+	request := map[string]string{
+		"state":       githubState,
+		"description": description,
+		"context":     ReviewStatusName,
+	}
+	if targetURL != "" {
+		request["target_url"] = targetURL
+	}
+	if err := g.api.do(http.MethodPost, fmt.Sprintf("/repos/%s/statuses/%s", repo, sha), request, nil); err != nil {
+		return fmt.Errorf("failed to set commit status: %w", err)
+	}
+	return nil
+}
+
+func (g *GitHubService) BotUsername() (string, error) {
+	return g.botUser.get(func() (string, error) {
+		var user struct {
+			Login string `json:"login"`
+		}
+		if err := g.api.do(http.MethodGet, "/user", nil, &user); err != nil {
+			return "", err
+		}
+		return user.Login, nil
+	})
+}
+
+// refQuery renders the query selecting a ref, none for the default branch.
+func refQuery(param, ref string) string {
+	if ref == "" {
+		return ""
+	}
+	return "?" + param + "=" + url.QueryEscape(ref)
+}
+
+// escapePath escapes each segment of a repository file path.
+func escapePath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,310 @@
+package services
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/vinamra28/whytho/internal/models"
+)
+
+// newGitHubTestService returns a GitHubService talking to a test server that
+// serves the given routes, keyed by "METHOD path".
+func newGitHubTestService(t *testing.T, routes map[string]http.HandlerFunc) *GitHubService {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer test-token" {
+			t.Errorf("Authorization header = %q", got)
+		}
+		handler, ok := routes[r.Method+" "+r.URL.Path]
+		if !ok {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+			http.NotFound(w, r)
+			return
+		}
+		handler(w, r)
+	}))
+	t.Cleanup(server.Close)
+	return NewGitHubService("test-token", server.URL)
+}
+
+func respondJSON(t *testing.T, value any) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(value); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+// captureJSON decodes the request body into out and answers with an empty object.
+func captureJSON(t *testing.T, out any) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(out); err != nil {
+			t.Error(err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte("{}"))
+	}
+}
+
+type githubTestComment struct {
+	ID   int64  `json:"id"`
+	Body string `json:"body"`
+	User struct {
+		Login string `json:"login"`
+	} `json:"user"`
+}
+
+func githubComment(id int64, login, body string) githubTestComment {
+	comment := githubTestComment{ID: id, Body: body}
+	comment.User.Login = login
+	return comment
+}
+
+func TestGitHubListComments(t *testing.T) {
+	summary := SummaryCommentBody("Looks good", "abc123")
+	finding := InlineCommentBody(models.PositionedComment{FilePath: "main.go", LineNumber: 2, Severity: "HIGH", Comment: "Unchecked error"}, "")
+
+	github := newGitHubTestService(t, map[string]http.HandlerFunc{
+		"GET /repos/acme/api/issues/7/comments": respondJSON(t, []githubTestComment{
+			githubComment(1, "whytho-bot", summary),
+			// A marker copied into someone else's comment
+			githubComment(2, "mallory", SummaryCommentBody("Fake summary", "abc123")),
+		}),
+		"GET /repos/acme/api/pulls/7/comments": respondJSON(t, []githubTestComment{
+			githubComment(3, "whytho-bot", finding),
+			githubComment(4, "mallory", InlineCommentBody(models.PositionedComment{FilePath: "main.go", LineNumber: 3, Comment: "Hidden"}, "")),
+		}),
+		"GET /user": respondJSON(t, map[string]string{"login": "whytho-bot"}),
+	})
+
+	comments, err := github.ListComments("acme/api", 7)
+	if err != nil {
+		t.Fatalf("ListComments() error = %v", err)
+	}
+	wantAuthors := map[int64]string{1: "whytho-bot", 2: "mallory", 3: "whytho-bot", 4: "mallory"}
+	if len(comments) != len(wantAuthors) {
+		t.Fatalf("ListComments() returned %d comments, want %d", len(comments), len(wantAuthors))
+	}
+	for _, comment := range comments {
+		if comment.Author != wantAuthors[comment.ID] {
+			t.Errorf("comment %d author = %q, want %q", comment.ID, comment.Author, wantAuthors[comment.ID])
+		}
+	}
+
+	botUsername, err := github.BotUsername()
+	if err != nil {
+		t.Fatalf("BotUsername() error = %v", err)
+	}
+	posted, summaryID := BotComments(comments, botUsername)
+	if summaryID != 1 {
+		t.Errorf("summary ID = %d, want the bot's summary 1", summaryID)
+	}
+	if len(posted) != 1 {
+		t.Errorf("posted fingerprints = %v, want only the bot's finding", posted)
+	}
+}
+
+func TestGitHubBotUsernameIsCached(t *testing.T) {
+	calls := 0
+	github := newGitHubTestService(t, map[string]http.HandlerFunc{
+		"GET /user": func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			respondJSON(t, map[string]string{"login": "whytho-bot"})(w, r)
+		},
+	})
+
+	for i := 0; i < 2; i++ {
+		username, err := github.BotUsername()
+		if err != nil || username != "whytho-bot" {
+			t.Fatalf("BotUsername() = %q, %v", username, err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("GET /user called %d times, want 1", calls)
+	}
+}
+
+func TestGitHubPostInlineComment(t *testing.T) {
+	patch := "@@ -1,3 +1,4 @@\n package main\n+import \"fmt\"\n \n func main() {}"
+
+	tests := []struct {
+		name    string
+		comment models.PositionedComment
+		// wantInline is the expected review comment, nil when the finding
+		// falls back to a general comment
+		wantInline map[string]any
+		wantBody   string
+	}{
+		{
+			name:    "added line",
+			comment: models.PositionedComment{FilePath: "main.go", LineNumber: 2, LineType: "new", Severity: "LOW", Comment: "Unused import"},
+			wantInline: map[string]any{
+				"commit_id": "abc123",
+				"path":      "main.go",
+				"line":      float64(2),
+				"side":      "RIGHT",
+			},
+			wantBody: "Unused import",
+		},
+		{
+			name:    "suggestion",
+			comment: models.PositionedComment{FilePath: "main.go", LineNumber: 2, LineType: "new", Comment: "Use os", Suggestion: `import "os"`},
+			wantInline: map[string]any{
+				"path": "main.go",
+				"line": float64(2),
+				"side": "RIGHT",
+			},
+			wantBody: "```suggestion\nimport \"os\"\n```",
+		},
+		{
+			name:     "line outside the diff",
+			comment:  models.PositionedComment{FilePath: "main.go", LineNumber: 9, LineType: "new", Comment: "Out of range"},
+			wantBody: "**File: main.go (Line 9)**",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var inline, general map[string]any
+			github := newGitHubTestService(t, map[string]http.HandlerFunc{
+				"GET /repos/acme/api/pulls/7": respondJSON(t, map[string]any{
+					"title": "Add greeting",
+					"state": "open",
+					"head":  map[string]string{"sha": "abc123"},
+					"base":  map[string]string{"ref": "main"},
+				}),
+				"GET /repos/acme/api/pulls/7/files": respondJSON(t, []map[string]string{
+					{"filename": "main.go", "status": "modified", "patch": patch},
+				}),
+				"POST /repos/acme/api/pulls/7/comments":  captureJSON(t, &inline),
+				"POST /repos/acme/api/issues/7/comments": captureJSON(t, &general),
+			})
+
+			if err := github.PostInlineComment("acme/api", 7, tt.comment); err != nil {
+				t.Fatalf("PostInlineComment() error = %v", err)
+			}
+
+			posted := inline
+			if tt.wantInline == nil {
+				if inline != nil {
+					t.Fatalf("posted review comment %v, want a general comment", inline)
+				}
+				posted = general
+			} else if general != nil {
+				t.Fatalf("posted general comment %v, want a review comment", general)
+			}
+			if posted == nil {
+				t.Fatal("nothing was posted")
+			}
+			for key, want := range tt.wantInline {
+				if posted[key] != want {
+					t.Errorf("%s = %v, want %v", key, posted[key], want)
+				}
+			}
+			body, _ := posted["body"].(string)
+			if !strings.Contains(body, tt.wantBody) {
+				t.Errorf("body = %q, want it to contain %q", body, tt.wantBody)
+			}
+			if _, _, ok := parseBotMarker(body); !ok {
+				t.Errorf("body %q carries no bot marker", body)
+			}
+		})
+	}
+}
+
+func TestGitHubSetStatus(t *testing.T) {
+	tests := []struct {
+		name            string
+		state           string
+		description     string
+		targetURL       string
+		wantState       string
+		wantDescription string
+	}{
+		{
+			name:            "running is pending",
+			state:           ReviewStatusRunning,
+			description:     "Review in progress",
+			targetURL:       "https://github.com/acme/api/pull/7",
+			wantState:       "pending",
+			wantDescription: "Review in progress",
+		},
+		{
+			name:            "failed is failure",
+			state:           ReviewStatusFailed,
+			description:     "Review failed",
+			wantState:       "failure",
+			wantDescription: "Review failed",
+		},
+		{
+			name:            "success",
+			state:           ReviewStatusSuccess,
+			description:     "Review completed",
+			wantState:       "success",
+			wantDescription: "Review completed",
+		},
+		{
+			name:            "long description is truncated",
+			state:           ReviewStatusSuccess,
+			description:     strings.Repeat("a", 150),
+			wantState:       "success",
+			wantDescription: strings.Repeat("a", 137) + "...",
+		},
+		{
+			name:            "multibyte description is truncated by characters",
+			state:           ReviewStatusSuccess,
+			description:     strings.Repeat("é", 150),
+			wantState:       "success",
+			wantDescription: strings.Repeat("é", 137) + "...",
+		},
+		{
+			name:            "multibyte description within the limit is kept",
+			state:           ReviewStatusSuccess,
+			description:     strings.Repeat("🔍", 140),
+			wantState:       "success",
+			wantDescription: strings.Repeat("🔍", 140),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var request map[string]string
+			github := newGitHubTestService(t, map[string]http.HandlerFunc{
+				"POST /repos/acme/api/statuses/abc123": captureJSON(t, &request),
+			})
+
+			if err := github.SetStatus("acme/api", "abc123", tt.state, tt.description, tt.targetURL); err != nil {
+				t.Fatalf("SetStatus() error = %v", err)
+			}
+			if request["state"] != tt.wantState {
+				t.Errorf("state = %q, want %q", request["state"], tt.wantState)
+			}
+			if request["description"] != tt.wantDescription {
+				t.Errorf("description = %q, want %q", request["description"], tt.wantDescription)
+			}
+			if request["context"] != ReviewStatusName {
+				t.Errorf("context = %q, want %q", request["context"], ReviewStatusName)
+			}
+			if request["target_url"] != tt.targetURL {
+				t.Errorf("target_url = %q, want %q", request["target_url"], tt.targetURL)
+			}
+		})
+	}
+}
+
+func TestGitHubSetStatusError(t *testing.T) {
+	github := newGitHubTestService(t, map[string]http.HandlerFunc{
+		"POST /repos/acme/api/statuses/abc123": func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, `{"message":"Not Found"}`, http.StatusNotFound)
+		},
+	})
+
+	err := github.SetStatus("acme/api", "abc123", ReviewStatusSuccess, "Review completed", "")
+	if err == nil || !isNotFound(err) {
+		t.Errorf("SetStatus() error = %v, want a not found API error", err)
+	}
+}
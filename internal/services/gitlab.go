@@ -2,24 +2,29 @@ package services
 
 import (
 	"bytes"
-	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"io"
 	"mime/multipart"
 	"net/http"
+	neturl "net/url"
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/sirupsen/logrus"
+	"github.com/vinamra28/whytho/internal/diff"
 	"github.com/vinamra28/whytho/internal/models"
 	"github.com/xanzy/go-gitlab"
-	"gopkg.in/yaml.v3"
 )
 
 type GitLabService struct {
 	client  *gitlab.Client
 	token   string
 	baseURL string
+
+	botUserMu sync.Mutex
+	botUser   *gitlab.User
 }
 
 func NewGitLabService(token, baseURL string) *GitLabService {
@@ -77,6 +82,98 @@ func (g *GitLabService) GetMRChanges(projectID, mrIID int) ([]models.MRChange, e
 	return mrChanges, nil
 }
 
+func (g *GitLabService) GetCompareChanges(projectID int, from, to string) ([]models.MRChange, error) {
+	logrus.WithFields(logrus.Fields{
+		"project_id": projectID,
+		"from":       from,
+		"to":         to,
+	}).Debug("Fetching changes between revisions")
+
+	compare, _, err := g.client.Repositories.Compare(projectID, &gitlab.CompareOptions{
+		From: &from,
+		To:   &to,
+	})
+	if err != nil {
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"project_id": projectID,
+			"from":       from,
+			"to":         to,
+		}).Error("Failed to compare revisions via GitLab API")
+		return nil, fmt.Errorf("failed to compare revisions: %w", err)
+	}
+
+	var changes []models.MRChange
+	for _, diff := range compare.Diffs {
+		changes = append(changes, models.MRChange{
+			OldPath:     diff.OldPath,
+			NewPath:     diff.NewPath,
+			AMode:       diff.AMode,
+			BMode:       diff.BMode,
+			NewFile:     diff.NewFile,
+			RenamedFile: diff.RenamedFile,
+			DeletedFile: diff.DeletedFile,
+			Diff:        diff.Diff,
+		})
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"project_id":    projectID,
+		"from":          from,
+		"to":            to,
+		"changes_count": len(changes),
+	}).Debug("Successfully fetched changes between revisions")
+
+	return changes, nil
+}
+
+// RemapToMRDiff translates a positioned comment made against an interdiff
+// into the DIFF_LINE numbering of the full MR diff. Only lines that were
+// added by the interdiff and are still added lines in the MR can be remapped.
+func (g *GitLabService) RemapToMRDiff(positionedComment models.PositionedComment, interdiffChanges, mrChanges []models.MRChange) (models.PositionedComment, bool) {
+	if positionedComment.LineType != "new" {
+		return positionedComment, false
+	}
+
+	var interdiff, mrDiff string
+	for _, change := range interdiffChanges {
+		if change.NewPath == positionedComment.FilePath {
+			interdiff = change.Diff
+		}
+	}
+	for _, change := range mrChanges {
+		if change.NewPath == positionedComment.FilePath {
+			mrDiff = change.Diff
+		}
+	}
+	if interdiff == "" || mrDiff == "" {
+		return positionedComment, false
+	}
+
+	newLine, err := g.findActualLineNumber(interdiff, positionedComment)
+	if err != nil {
+		return positionedComment, false
+	}
+
+	diffLine, ok := g.findDiffLineForNewLine(mrDiff, newLine)
+	if !ok {
+		return positionedComment, false
+	}
+
+	remapped := positionedComment
+	remapped.LineNumber = diffLine
+
+	// The start of a range only has to still be on the new side of the MR diff
+	if positionedComment.StartLineNumber != 0 {
+		remapped.StartLineNumber = 0
+		if startLine, ok := diff.Parse(interdiff).Line(positionedComment.StartLineNumber); ok && startLine.NewLineNum != 0 {
+			if mrStart, ok := diff.Parse(mrDiff).LineAtNew(startLine.NewLineNum); ok && mrStart.Position < diffLine {
+				remapped.StartLineNumber = mrStart.Position
+			}
+		}
+	}
+	return remapped, true
+}
+
 func (g *GitLabService) PostMRComment(projectID, mrIID int, comment string) error {
 	logrus.WithFields(logrus.Fields{
 		"project_id": projectID,
@@ -104,13 +201,381 @@ func (g *GitLabService) PostMRComment(projectID, mrIID int, comment string) erro
 	return nil
 }
 
+// PostGeneralReviewComment posts a general review comment tagged with its fingerprint.
+func (g *GitLabService) PostGeneralReviewComment(projectID, mrIID int, comment string) error {
+	return g.PostMRComment(projectID, mrIID, GeneralCommentBody(comment))
+}
+
+// UpsertSummaryNote edits the existing summary note in place, or creates it
+// when existingNoteID is 0.
+func (g *GitLabService) UpsertSummaryNote(projectID, mrIID int, summary, headSHA string, existingNoteID int) error {
+	body := SummaryCommentBody(summary, headSHA)
+	if existingNoteID == 0 {
+		return g.PostMRComment(projectID, mrIID, body)
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"project_id": projectID,
+		"mr_iid":     mrIID,
+		"note_id":    existingNoteID,
+	}).Debug("Updating existing summary note")
+
+	_, _, err := g.client.Notes.UpdateMergeRequestNote(projectID, mrIID, existingNoteID, &gitlab.UpdateMergeRequestNoteOptions{
+		Body: &body,
+	})
+	if err != nil {
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"project_id": projectID,
+			"mr_iid":     mrIID,
+			"note_id":    existingNoteID,
+		}).Error("Failed to update summary note")
+		return fmt.Errorf("failed to update summary note: %w", err)
+	}
+
+	return nil
+}
+
+// ReviewStatusName is the name of the commit status the bot reports reviews under.
+const ReviewStatusName = "whytho/review"
+
+const (
+	ReviewStatusPending = string(gitlab.Pending)
+	ReviewStatusRunning = string(gitlab.Running)
+	ReviewStatusSuccess = string(gitlab.Success)
+	ReviewStatusFailed  = string(gitlab.Failed)
+)
+
+// SetReviewStatus sets the "whytho/review" commit status of a commit.
+func (g *GitLabService) SetReviewStatus(projectID int, sha, state, description, targetURL string) error {
+	logrus.WithFields(logrus.Fields{
+		"project_id": projectID,
+		"sha":        sha,
+		"state":      state,
+	}).Debug("Setting review commit status")
+
+	opts := &gitlab.SetCommitStatusOptions{
+		State:       gitlab.BuildStateValue(state),
+		Name:        gitlab.Ptr(ReviewStatusName),
+		Description: gitlab.Ptr(description),
+	}
+	if targetURL != "" {
+		opts.TargetURL = gitlab.Ptr(targetURL)
+	}
+
+	if _, _, err := g.client.Commits.SetCommitStatus(projectID, sha, opts); err != nil {
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"project_id": projectID,
+			"sha":        sha,
+			"state":      state,
+		}).Error("Failed to set review commit status")
+		return fmt.Errorf("failed to set commit status: %w", err)
+	}
+	return nil
+}
+
+// SetBotApproval approves the merge request as the bot, or revokes the bot's
+// approval. It reports whether the approval state changed. headSHA, when set,
+// makes GitLab refuse the approval if new commits were pushed meanwhile.
+func (g *GitLabService) SetBotApproval(projectID, mrIID int, approve bool, headSHA string) (bool, error) {
+	botUser, err := g.BotUser()
+	if err != nil {
+		return false, err
+	}
+
+	approvals, _, err := g.client.MergeRequestApprovals.GetConfiguration(projectID, mrIID)
+	if err != nil {
+		return false, fmt.Errorf("failed to get merge request approvals: %w", err)
+	}
+	approved := false
+	for _, approver := range approvals.ApprovedBy {
+		if approver.User != nil && approver.User.ID == botUser.ID {
+			approved = true
+			break
+		}
+	}
+	if approved == approve {
+		return false, nil
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"project_id": projectID,
+		"mr_iid":     mrIID,
+		"approve":    approve,
+	}).Info("Updating bot approval")
+
+	if approve {
+		opts := &gitlab.ApproveMergeRequestOptions{}
+		if headSHA != "" {
+			opts.SHA = &headSHA
+		}
+		if _, _, err := g.client.MergeRequestApprovals.ApproveMergeRequest(projectID, mrIID, opts); err != nil {
+			return false, fmt.Errorf("failed to approve merge request: %w", err)
+		}
+		return true, nil
+	}
+
+	if _, err := g.client.MergeRequestApprovals.UnapproveMergeRequest(projectID, mrIID); err != nil {
+		return false, fmt.Errorf("failed to revoke approval: %w", err)
+	}
+	return true, nil
+}
+
+// BotUser returns the GitLab user the bot authenticates as.
+func (g *GitLabService) BotUser() (*gitlab.User, error) {
+	g.botUserMu.Lock()
+	defer g.botUserMu.Unlock()
+
+	if g.botUser != nil {
+		return g.botUser, nil
+	}
+
+	user, _, err := g.client.Users.CurrentUser()
+	if err != nil {
+		logrus.WithError(err).Error("Failed to fetch bot user from GitLab API")
+		return nil, fmt.Errorf("failed to get current user: %w", err)
+	}
+
+	g.botUser = user
+	return user, nil
+}
+
+// ListBotDiscussions returns every discussion on the merge request that was
+// started by the bot and carries a whytho marker.
+func (g *GitLabService) ListBotDiscussions(projectID, mrIID int) ([]models.BotDiscussion, error) {
+	logrus.WithFields(logrus.Fields{
+		"project_id": projectID,
+		"mr_iid":     mrIID,
+	}).Debug("Fetching existing bot discussions")
+
+	botUser, err := g.BotUser()
+	if err != nil {
+		return nil, err
+	}
+
+	var botDiscussions []models.BotDiscussion
+	opts := &gitlab.ListMergeRequestDiscussionsOptions{PerPage: 100, Page: 1}
+	for {
+		discussions, resp, err := g.client.Discussions.ListMergeRequestDiscussions(projectID, mrIID, opts)
+		if err != nil {
+			logrus.WithError(err).WithFields(logrus.Fields{
+				"project_id": projectID,
+				"mr_iid":     mrIID,
+			}).Error("Failed to fetch merge request discussions from GitLab API")
+			return nil, fmt.Errorf("failed to list MR discussions: %w", err)
+		}
+
+		for _, discussion := range discussions {
+			if len(discussion.Notes) == 0 {
+				continue
+			}
+
+			// Opt-outs are replies to the command that requested them, so look past the first note
+			for _, reply := range discussion.Notes[1:] {
+				if reply.Author.ID != botUser.ID {
+					continue
+				}
+				if kind, _, ok := parseBotMarker(reply.Body); ok && kind == BotNoteKindIgnore {
+					botDiscussions = append(botDiscussions, models.BotDiscussion{
+						DiscussionID: discussion.ID,
+						NoteID:       reply.ID,
+						Kind:         kind,
+					})
+				}
+			}
+
+			note := discussion.Notes[0]
+			if note.Author.ID != botUser.ID {
+				continue
+			}
+
+			kind, attributes, ok := parseBotMarker(note.Body)
+			if !ok {
+				continue
+			}
+
+			botDiscussions = append(botDiscussions, newBotDiscussion(discussion.ID, note, kind, attributes))
+		}
+
+		if resp == nil || resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"project_id":      projectID,
+		"mr_iid":          mrIID,
+		"bot_discussions": len(botDiscussions),
+	}).Debug("Successfully fetched existing bot discussions")
+
+	return botDiscussions, nil
+}
+
+// GetBotThread returns a discussion with all its notes if it was started by
+// the bot, or nil when it was not.
+func (g *GitLabService) GetBotThread(projectID, mrIID int, discussionID string) (*models.BotThread, error) {
+	logrus.WithFields(logrus.Fields{
+		"project_id":    projectID,
+		"mr_iid":        mrIID,
+		"discussion_id": discussionID,
+	}).Debug("Fetching merge request discussion")
+
+	botUser, err := g.BotUser()
+	if err != nil {
+		return nil, err
+	}
+
+	discussion, _, err := g.client.Discussions.GetMergeRequestDiscussion(projectID, mrIID, discussionID)
+	if err != nil {
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"project_id":    projectID,
+			"mr_iid":        mrIID,
+			"discussion_id": discussionID,
+		}).Error("Failed to fetch merge request discussion from GitLab API")
+		return nil, fmt.Errorf("failed to get MR discussion: %w", err)
+	}
+
+	if len(discussion.Notes) == 0 || discussion.Notes[0].Author.ID != botUser.ID {
+		return nil, nil
+	}
+	first := discussion.Notes[0]
+	kind, attributes, ok := parseBotMarker(first.Body)
+	if !ok {
+		return nil, nil
+	}
+
+	thread := &models.BotThread{
+		Discussion: newBotDiscussion(discussion.ID, first, kind, attributes),
+	}
+	if first.Position != nil {
+		thread.Position = &models.NotePosition{
+			OldPath: first.Position.OldPath,
+			NewPath: first.Position.NewPath,
+			OldLine: first.Position.OldLine,
+			NewLine: first.Position.NewLine,
+		}
+	}
+	for _, note := range discussion.Notes {
+		if note.System {
+			continue
+		}
+		thread.Notes = append(thread.Notes, models.ThreadNote{
+			Author:  note.Author.Username,
+			Body:    stripBotMarkers(note.Body),
+			FromBot: note.Author.ID == botUser.ID,
+		})
+	}
+
+	return thread, nil
+}
+
+func newBotDiscussion(discussionID string, note *gitlab.Note, kind string, attributes map[string]string) models.BotDiscussion {
+	botDiscussion := models.BotDiscussion{
+		DiscussionID: discussionID,
+		NoteID:       note.ID,
+		Kind:         kind,
+		Fingerprint:  attributes["fingerprint"],
+		AnchorHash:   attributes["anchor"],
+		Severity:     attributes["severity"],
+		HeadSHA:      attributes["sha"],
+		Resolvable:   note.Resolvable,
+		Resolved:     note.Resolved,
+		ResolvedByID: note.ResolvedBy.ID,
+	}
+	if note.Position != nil {
+		botDiscussion.FilePath = note.Position.NewPath
+	}
+	return botDiscussion
+}
+
+// ReplyToDiscussion adds a note to an existing discussion, or posts a new
+// note when discussionID is empty.
+func (g *GitLabService) ReplyToDiscussion(projectID, mrIID int, discussionID, body string) error {
+	if discussionID == "" {
+		return g.PostMRComment(projectID, mrIID, body)
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"project_id":    projectID,
+		"mr_iid":        mrIID,
+		"discussion_id": discussionID,
+	}).Debug("Replying to merge request discussion")
+
+	_, _, err := g.client.Discussions.AddMergeRequestDiscussionNote(projectID, mrIID, discussionID, &gitlab.AddMergeRequestDiscussionNoteOptions{
+		Body: &body,
+	})
+	if err != nil {
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"project_id":    projectID,
+			"mr_iid":        mrIID,
+			"discussion_id": discussionID,
+		}).Error("Failed to reply to merge request discussion")
+		return fmt.Errorf("failed to reply to discussion: %w", err)
+	}
+
+	return nil
+}
+
+// DisableAutomaticReviews replies to a discussion with a note carrying the
+// ignore marker, which makes later automatic reviews of the MR a no-op.
+func (g *GitLabService) DisableAutomaticReviews(projectID, mrIID int, discussionID, reply string) error {
+	return g.ReplyToDiscussion(projectID, mrIID, discussionID, fmt.Sprintf("%s\n\n%s", reply, ignoreMarker()))
+}
+
+// ResolveDiscussion replies to a discussion with the given reason and resolves it.
+func (g *GitLabService) ResolveDiscussion(projectID, mrIID int, discussionID, reason string) error {
+	logrus.WithFields(logrus.Fields{
+		"project_id":    projectID,
+		"mr_iid":        mrIID,
+		"discussion_id": discussionID,
+	}).Debug("Resolving merge request discussion")
+
+	if reason != "" {
+		if _, _, err := g.client.Discussions.AddMergeRequestDiscussionNote(projectID, mrIID, discussionID, &gitlab.AddMergeRequestDiscussionNoteOptions{
+			Body: &reason,
+		}); err != nil {
+			logrus.WithError(err).WithFields(logrus.Fields{
+				"project_id":    projectID,
+				"mr_iid":        mrIID,
+				"discussion_id": discussionID,
+			}).Warn("Failed to reply to discussion before resolving it")
+		}
+	}
+
+	resolved := true
+	_, _, err := g.client.Discussions.ResolveMergeRequestDiscussion(projectID, mrIID, discussionID, &gitlab.ResolveMergeRequestDiscussionOptions{
+		Resolved: &resolved,
+	})
+	if err != nil {
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"project_id":    projectID,
+			"mr_iid":        mrIID,
+			"discussion_id": discussionID,
+		}).Error("Failed to resolve merge request discussion")
+		return fmt.Errorf("failed to resolve discussion: %w", err)
+	}
+
+	return nil
+}
+
 func (g *GitLabService) PostPositionedMRComment(projectID, mrIID int, positionedComment models.PositionedComment) error {
+	return g.postPositionedComment(projectID, mrIID, positionedComment, false)
+}
+
+// CreateDraftPositionedNote creates a finding as a draft note, which stays
+// invisible to others until PublishDraftNotes is called.
+func (g *GitLabService) CreateDraftPositionedNote(projectID, mrIID int, positionedComment models.PositionedComment) error {
+	return g.postPositionedComment(projectID, mrIID, positionedComment, true)
+}
+
+func (g *GitLabService) postPositionedComment(projectID, mrIID int, positionedComment models.PositionedComment, draft bool) error {
 	logrus.WithFields(logrus.Fields{
 		"project_id":  projectID,
 		"mr_iid":      mrIID,
 		"file_path":   positionedComment.FilePath,
 		"line_number": positionedComment.LineNumber,
 		"line_type":   positionedComment.LineType,
+		"draft":       draft,
 	}).Debug("Posting positioned comment to merge request")
 
 	// Get merge request details to get SHA values
@@ -123,8 +588,8 @@ func (g *GitLabService) PostPositionedMRComment(projectID, mrIID int, positioned
 		return fmt.Errorf("failed to get MR details: %w", err)
 	}
 
-	// Convert diff line number to actual line number if needed
-	actualLineNumber, err := g.convertDiffLineToActualLine(projectID, mrIID, positionedComment)
+	// Resolve the DIFF_LINE number to the old/new file line pair GitLab expects
+	actualComment, err := g.resolveDiffPosition(projectID, mrIID, positionedComment)
 	if err != nil {
 		logrus.WithError(err).WithFields(logrus.Fields{
 			"project_id":  projectID,
@@ -133,24 +598,19 @@ func (g *GitLabService) PostPositionedMRComment(projectID, mrIID int, positioned
 			"line_number": positionedComment.LineNumber,
 		}).Warn("Failed to convert diff line to actual line, falling back to general comment")
 
-		severityFormatted := formatSeverity(positionedComment.Severity)
-		return g.PostMRComment(projectID, mrIID, fmt.Sprintf("**File: %s (Line %d)** - %s\n\n%s",
-			positionedComment.FilePath, positionedComment.LineNumber, severityFormatted, positionedComment.Comment))
+		return g.postUnpositionedFinding(projectID, mrIID, positionedComment, draft)
 	}
 
-	// Create updated positioned comment with actual line number
-	actualComment := positionedComment
-	actualComment.LineNumber = actualLineNumber
-
 	// Use the discussions API with proper SHA values
 	err = g.postPositionedCommentHTTP(projectID, mrIID, actualComment,
-		mr.DiffRefs.BaseSha, mr.DiffRefs.HeadSha, mr.DiffRefs.StartSha)
+		mr.DiffRefs.BaseSha, mr.DiffRefs.HeadSha, mr.DiffRefs.StartSha, draft)
 	if err != nil {
 		logrus.WithError(err).WithFields(logrus.Fields{
-			"project_id":  projectID,
-			"mr_iid":      mrIID,
-			"file_path":   positionedComment.FilePath,
-			"line_number": actualLineNumber,
+			"project_id": projectID,
+			"mr_iid":     mrIID,
+			"file_path":  positionedComment.FilePath,
+			"old_line":   actualComment.OldLineNumber,
+			"new_line":   actualComment.NewLineNumber,
 		}).Error("Failed to post positioned comment to GitLab")
 
 		// Fall back to posting a general comment
@@ -160,54 +620,79 @@ func (g *GitLabService) PostPositionedMRComment(projectID, mrIID int, positioned
 			"file_path":  positionedComment.FilePath,
 		}).Info("Falling back to general comment")
 
-		severityFormatted := formatSeverity(positionedComment.Severity)
-		return g.PostMRComment(projectID, mrIID, fmt.Sprintf("**File: %s (Line %d)** - %s\n\n%s",
-			positionedComment.FilePath, positionedComment.LineNumber, severityFormatted, positionedComment.Comment))
+		return g.postUnpositionedFinding(projectID, mrIID, positionedComment, draft)
 	}
 
 	logrus.WithFields(logrus.Fields{
-		"project_id":         projectID,
-		"mr_iid":             mrIID,
-		"file_path":          positionedComment.FilePath,
-		"diff_line_number":   positionedComment.LineNumber,
-		"actual_line_number": actualLineNumber,
+		"project_id":       projectID,
+		"mr_iid":           mrIID,
+		"file_path":        positionedComment.FilePath,
+		"diff_line_number": positionedComment.LineNumber,
+		"old_line":         actualComment.OldLineNumber,
+		"new_line":         actualComment.NewLineNumber,
 	}).Debug("Positioned comment posted successfully to merge request")
 
 	return nil
 }
 
-func (g *GitLabService) postPositionedCommentHTTP(projectID, mrIID int, positionedComment models.PositionedComment, baseSHA, headSHA, startSHA string) error {
-	// Construct the API URL for discussions
+// postUnpositionedFinding posts a finding that could not be anchored to the
+// diff as a general note naming its file and line.
+func (g *GitLabService) postUnpositionedFinding(projectID, mrIID int, positionedComment models.PositionedComment, draft bool) error {
+	body := UnpositionedFindingBody(positionedComment)
+	if draft {
+		return g.CreateDraftNote(projectID, mrIID, body)
+	}
+	return g.PostMRComment(projectID, mrIID, body)
+}
+
+func (g *GitLabService) postPositionedCommentHTTP(projectID, mrIID int, positionedComment models.PositionedComment, baseSHA, headSHA, startSHA string, draft bool) error {
+	// Construct the API URL for discussions, or draft notes which take the body as "note"
 	url := fmt.Sprintf("%s/api/v4/projects/%d/merge_requests/%d/discussions",
 		strings.TrimSuffix(g.baseURL, "/"), projectID, mrIID)
+	bodyField := "body"
+	if draft {
+		url = g.draftNotesURL(projectID, mrIID)
+		bodyField = "note"
+	}
 
 	// Create form data
 	var buf bytes.Buffer
 	writer := multipart.NewWriter(&buf)
 
 	// Add body with severity and color formatting
-	severityFormatted := formatSeverity(positionedComment.Severity)
-	commentBody := fmt.Sprintf("%s\n\n%s", severityFormatted, positionedComment.Comment)
-	_ = writer.WriteField("body", commentBody)
+	_ = writer.WriteField(bodyField, InlineCommentBody(positionedComment, formatSuggestion(positionedComment, true)))
 
 	// Add position fields
 	_ = writer.WriteField("position[position_type]", "text")
 	_ = writer.WriteField("position[base_sha]", baseSHA)
 	_ = writer.WriteField("position[head_sha]", headSHA)
 	_ = writer.WriteField("position[start_sha]", startSHA)
+	// Renamed files must be addressed by their old path on the old side
+	oldPath := positionedComment.OldPath
+	if oldPath == "" {
+		oldPath = positionedComment.FilePath
+	}
 	_ = writer.WriteField("position[new_path]", positionedComment.FilePath)
-	_ = writer.WriteField("position[old_path]", positionedComment.FilePath)
+	_ = writer.WriteField("position[old_path]", oldPath)
 
 	// Add line number based on line type
 	switch positionedComment.LineType {
 	case "new":
-		_ = writer.WriteField("position[new_line]", strconv.Itoa(positionedComment.LineNumber))
+		_ = writer.WriteField("position[new_line]", strconv.Itoa(positionedComment.NewLineNumber))
 	case "old":
-		_ = writer.WriteField("position[old_line]", strconv.Itoa(positionedComment.LineNumber))
+		_ = writer.WriteField("position[old_line]", strconv.Itoa(positionedComment.OldLineNumber))
 	default:
-		// For context lines, include both line numbers
-		_ = writer.WriteField("position[new_line]", strconv.Itoa(positionedComment.LineNumber))
-		_ = writer.WriteField("position[old_line]", strconv.Itoa(positionedComment.LineNumber))
+		// Context lines need both numbers, which differ once lines were added or removed above
+		_ = writer.WriteField("position[new_line]", strconv.Itoa(positionedComment.NewLineNumber))
+		_ = writer.WriteField("position[old_line]", strconv.Itoa(positionedComment.OldLineNumber))
+	}
+
+	// Multi-line comments highlight every line from the start of the range to the commented line
+	if positionedComment.StartLineCode != "" && positionedComment.LineCode != "" {
+		writeLineRangeEnd(writer, "start", positionedComment.StartLineCode, positionedComment.StartLineType,
+			positionedComment.StartOldLineNumber, positionedComment.StartNewLineNumber)
+		writeLineRangeEnd(writer, "end", positionedComment.LineCode, diffLineType(positionedComment.LineType),
+			positionedComment.OldLineNumber, positionedComment.NewLineNumber)
 	}
 
 	_ = writer.Close()
@@ -239,100 +724,195 @@ func (g *GitLabService) postPositionedCommentHTTP(projectID, mrIID int, position
 	return nil
 }
 
-func (g *GitLabService) convertDiffLineToActualLine(projectID, mrIID int, positionedComment models.PositionedComment) (int, error) {
+func (g *GitLabService) draftNotesURL(projectID, mrIID int) string {
+	return fmt.Sprintf("%s/api/v4/projects/%d/merge_requests/%d/draft_notes",
+		strings.TrimSuffix(g.baseURL, "/"), projectID, mrIID)
+}
+
+// CreateDraftNote creates an unpositioned draft note.
+func (g *GitLabService) CreateDraftNote(projectID, mrIID int, body string) error {
+	logrus.WithFields(logrus.Fields{
+		"project_id": projectID,
+		"mr_iid":     mrIID,
+	}).Debug("Creating draft note on merge request")
+
+	form := neturl.Values{"note": {body}}
+	if _, err := g.doAPIRequest(http.MethodPost, g.draftNotesURL(projectID, mrIID), form, http.StatusCreated); err != nil {
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"project_id": projectID,
+			"mr_iid":     mrIID,
+		}).Error("Failed to create draft note")
+		return fmt.Errorf("failed to create draft note: %w", err)
+	}
+	return nil
+}
+
+// CreateDraftGeneralReviewNote is the draft counterpart of PostGeneralReviewComment.
+func (g *GitLabService) CreateDraftGeneralReviewNote(projectID, mrIID int, comment string) error {
+	return g.CreateDraftNote(projectID, mrIID, GeneralCommentBody(comment))
+}
+
+// CreateDraftSummaryNote creates the review summary as a draft note.
+func (g *GitLabService) CreateDraftSummaryNote(projectID, mrIID int, summary, headSHA string) error {
+	return g.CreateDraftNote(projectID, mrIID, SummaryCommentBody(summary, headSHA))
+}
+
+// PublishDraftNotes publishes all of the bot's draft notes on the merge
+// request at once, so watchers are notified about the review only once.
+func (g *GitLabService) PublishDraftNotes(projectID, mrIID int) error {
+	logrus.WithFields(logrus.Fields{
+		"project_id": projectID,
+		"mr_iid":     mrIID,
+	}).Debug("Publishing draft notes")
+
+	if _, err := g.doAPIRequest(http.MethodPost, g.draftNotesURL(projectID, mrIID)+"/bulk_publish", nil, http.StatusNoContent); err != nil {
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"project_id": projectID,
+			"mr_iid":     mrIID,
+		}).Error("Failed to publish draft notes")
+		return fmt.Errorf("failed to publish draft notes: %w", err)
+	}
+	return nil
+}
+
+// DiscardDraftNotes deletes draft notes left behind by an interrupted review,
+// so a retry does not publish them twice.
+func (g *GitLabService) DiscardDraftNotes(projectID, mrIID int) error {
+	body, err := g.doAPIRequest(http.MethodGet, g.draftNotesURL(projectID, mrIID), nil, http.StatusOK)
+	if err != nil {
+		return fmt.Errorf("failed to list draft notes: %w", err)
+	}
+
+	var drafts []struct {
+		ID int `json:"id"`
+	}
+	if err := json.Unmarshal(body, &drafts); err != nil {
+		return fmt.Errorf("failed to decode draft notes: %w", err)
+	}
+
+	for _, draft := range drafts {
+		logrus.WithFields(logrus.Fields{
+			"project_id": projectID,
+			"mr_iid":     mrIID,
+			"draft_id":   draft.ID,
+		}).Debug("Discarding stale draft note")
+		if _, err := g.doAPIRequest(http.MethodDelete, fmt.Sprintf("%s/%d", g.draftNotesURL(projectID, mrIID), draft.ID), nil, http.StatusNoContent); err != nil {
+			return fmt.Errorf("failed to delete draft note %d: %w", draft.ID, err)
+		}
+	}
+	return nil
+}
+
+// doAPIRequest calls a GitLab REST endpoint that the client library does not
+// cover, sending form as a URL encoded body when set.
+func (g *GitLabService) doAPIRequest(method, url string, form neturl.Values, expectedStatus int) ([]byte, error) {
+	var body io.Reader
+	if form != nil {
+		body = strings.NewReader(form.Encode())
+	}
+
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	req.Header.Set("PRIVATE-TOKEN", g.token)
+
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make HTTP request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	if resp.StatusCode != expectedStatus {
+		return nil, fmt.Errorf("GitLab API returned status %d: %s", resp.StatusCode, string(respBody))
+	}
+	return respBody, nil
+}
+
+// resolveDiffPosition looks up the comment's DIFF_LINE in the current MR diff
+// and fills in the old and new file line numbers and the file's old path.
+func (g *GitLabService) resolveDiffPosition(projectID, mrIID int, positionedComment models.PositionedComment) (models.PositionedComment, error) {
 	logrus.WithFields(logrus.Fields{
 		"project_id":       projectID,
 		"mr_iid":           mrIID,
 		"file_path":        positionedComment.FilePath,
 		"diff_line_number": positionedComment.LineNumber,
 		"line_type":        positionedComment.LineType,
-	}).Debug("Converting diff line number to actual line number")
+	}).Debug("Resolving diff line to file line numbers")
 
-	// Get merge request changes
-	diffs, _, err := g.client.MergeRequests.ListMergeRequestDiffs(projectID, mrIID, nil)
+	changes, err := g.GetMRChanges(projectID, mrIID)
 	if err != nil {
-		return 0, fmt.Errorf("failed to get MR changes: %w", err)
+		return positionedComment, fmt.Errorf("failed to get MR changes: %w", err)
 	}
+	return ResolveDiffPosition(changes, positionedComment)
+}
 
-	// Find the specific file
-	for _, diff := range diffs {
-		if diff.NewPath == positionedComment.FilePath || diff.OldPath == positionedComment.FilePath {
-			return g.findActualLineNumber(diff.Diff, positionedComment)
-		}
+func (g *GitLabService) findActualLineNumber(rawDiff string, positionedComment models.PositionedComment) (int, error) {
+	line, ok := diff.Parse(rawDiff).Line(positionedComment.LineNumber)
+	if !ok || line.Type != diffLineType(positionedComment.LineType) {
+		return 0, fmt.Errorf("could not find actual line number for diff line %d", positionedComment.LineNumber)
 	}
 
-	return 0, fmt.Errorf("file %s not found in merge request changes", positionedComment.FilePath)
-}
-
-func (g *GitLabService) findActualLineNumber(diff string, positionedComment models.PositionedComment) (int, error) {
-	lines := strings.Split(diff, "\n")
-	oldLineNum := 0
-	newLineNum := 0
-	diffLineNum := 0
+	actualLine := line.NewLineNum
+	if line.Type == diff.LineRemoved {
+		actualLine = line.OldLineNum
+	}
 
-	for _, line := range lines {
-		if strings.HasPrefix(line, "@@") {
-			// Parse hunk header
-			parts := strings.Split(line, " ")
-			if len(parts) >= 3 {
-				oldPart := strings.TrimPrefix(parts[1], "-")
-				newPart := strings.TrimPrefix(parts[2], "+")
+	logrus.WithFields(logrus.Fields{
+		"diff_line":   positionedComment.LineNumber,
+		"actual_line": actualLine,
+		"line_type":   positionedComment.LineType,
+	}).Debug("Found actual line number for diff line")
+	return actualLine, nil
+}
 
-				if oldComma := strings.Index(oldPart, ","); oldComma > 0 {
-					oldPart = oldPart[:oldComma]
-				}
-				if newComma := strings.Index(newPart, ","); newComma > 0 {
-					newPart = newPart[:newComma]
-				}
+// findDiffLineForNewLine returns the DIFF_LINE number of the added line that
+// has the given line number in the new file.
+func (g *GitLabService) findDiffLineForNewLine(rawDiff string, newLine int) (int, bool) {
+	line, ok := diff.Parse(rawDiff).LineAtNew(newLine)
+	if !ok || line.Type != diff.LineAdded {
+		return 0, false
+	}
+	return line.Position, true
+}
 
-				if oldStart, err := strconv.Atoi(oldPart); err == nil {
-					oldLineNum = oldStart - 1
-				}
-				if newStart, err := strconv.Atoi(newPart); err == nil {
-					newLineNum = newStart - 1
-				}
-			}
-			continue
-		}
+// writeLineRangeEnd writes one end of position[line_range]. GitLab expects the
+// type "new" for added lines and "old" for everything else.
+func writeLineRangeEnd(writer *multipart.Writer, end, lineCode, lineType string, oldLine, newLine int) {
+	rangeType := "old"
+	if lineType == diff.LineAdded {
+		rangeType = "new"
+	}
 
-		if strings.HasPrefix(line, "+") {
-			newLineNum++
-			diffLineNum++
-			if positionedComment.LineType == "new" && diffLineNum == positionedComment.LineNumber {
-				logrus.WithFields(logrus.Fields{
-					"diff_line":   diffLineNum,
-					"actual_line": newLineNum,
-					"line_type":   "new",
-				}).Debug("Found actual line number for new line")
-				return newLineNum, nil
-			}
-		} else if strings.HasPrefix(line, "-") {
-			oldLineNum++
-			diffLineNum++
-			if positionedComment.LineType == "old" && diffLineNum == positionedComment.LineNumber {
-				logrus.WithFields(logrus.Fields{
-					"diff_line":   diffLineNum,
-					"actual_line": oldLineNum,
-					"line_type":   "old",
-				}).Debug("Found actual line number for old line")
-				return oldLineNum, nil
-			}
-		} else if strings.HasPrefix(line, " ") {
-			oldLineNum++
-			newLineNum++
-			diffLineNum++
-			if positionedComment.LineType == "context" && diffLineNum == positionedComment.LineNumber {
-				logrus.WithFields(logrus.Fields{
-					"diff_line":   diffLineNum,
-					"actual_line": newLineNum,
-					"line_type":   "context",
-				}).Debug("Found actual line number for context line")
-				return newLineNum, nil
-			}
-		}
+	prefix := fmt.Sprintf("position[line_range][%s]", end)
+	_ = writer.WriteField(prefix+"[line_code]", lineCode)
+	_ = writer.WriteField(prefix+"[type]", rangeType)
+	if oldLine != 0 {
+		_ = writer.WriteField(prefix+"[old_line]", strconv.Itoa(oldLine))
 	}
+	if newLine != 0 {
+		_ = writer.WriteField(prefix+"[new_line]", strconv.Itoa(newLine))
+	}
+}
 
-	return 0, fmt.Errorf("could not find actual line number for diff line %d", positionedComment.LineNumber)
+// diffLineType maps a comment's line type to the diff line prefix it refers to.
+func diffLineType(lineType string) string {
+	switch lineType {
+	case "new":
+		return diff.LineAdded
+	case "old":
+		return diff.LineRemoved
+	default:
+		return diff.LineContext
+	}
 }
 
 func (g *GitLabService) GetMRDetails(projectID, mrIID int) (*gitlab.MergeRequest, error) {
@@ -359,175 +939,154 @@ func (g *GitLabService) GetMRDetails(projectID, mrIID int) (*gitlab.MergeRequest
 	return mr, nil
 }
 
-func (g *GitLabService) GetReviewGuidance(projectID int, branch string) (string, error) {
-	logrus.WithFields(logrus.Fields{
-		"project_id": projectID,
-		"branch":     branch,
-	}).Debug("Fetching review guidance from .whytho directory")
-
-	guidancePath := ".whytho/guidance.md"
+// Name implements SCMProvider. The GitLabService methods below adapt it to
+// the provider neutral interface, with repo being the numeric project ID.
+func (g *GitLabService) Name() string {
+	return "gitlab"
+}
 
-	// Try to fetch .whytho/guidance.md from the repository
-	file, _, err := g.client.RepositoryFiles.GetFile(projectID, guidancePath, &gitlab.GetFileOptions{
-		Ref: &branch,
-	})
+func gitlabProjectID(repo string) (int, error) {
+	projectID, err := strconv.Atoi(repo)
 	if err != nil {
-		// Check if it's a 404 error (file not found)
-		if strings.Contains(err.Error(), "404") {
-			logrus.WithFields(logrus.Fields{
-				"project_id":    projectID,
-				"branch":        branch,
-				"guidance_path": guidancePath,
-			}).Debug("No .whytho/guidance.md file found in repository")
-			return "", nil // Return empty string, not an error
-		}
-
-		logrus.WithError(err).WithFields(logrus.Fields{
-			"project_id":    projectID,
-			"branch":        branch,
-			"guidance_path": guidancePath,
-		}).Error("Failed to fetch .whytho/guidance.md from repository")
-		return "", fmt.Errorf("failed to fetch .whytho/guidance.md: %w", err)
+		return 0, fmt.Errorf("invalid GitLab project ID %q: %w", repo, err)
 	}
+	return projectID, nil
+}
 
-	// Decode the file content (GitLab returns base64 encoded content)
-	content := file.Content
-	if file.Encoding == "base64" {
-		decoded, err := base64.StdEncoding.DecodeString(content)
-		if err != nil {
-			logrus.WithError(err).WithFields(logrus.Fields{
-				"project_id":    projectID,
-				"branch":        branch,
-				"guidance_path": guidancePath,
-			}).Error("Failed to decode .whytho/guidance.md content")
-			return "", fmt.Errorf("failed to decode .whytho/guidance.md: %w", err)
-		}
-		content = string(decoded)
+// ProjectPath returns the path with namespace of a project.
+func (g *GitLabService) ProjectPath(projectID int) (string, error) {
+	project, _, err := g.client.Projects.GetProject(projectID, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to get project %d: %w", projectID, err)
 	}
-
-	logrus.WithFields(logrus.Fields{
-		"project_id":      projectID,
-		"branch":          branch,
-		"guidance_path":   guidancePath,
-		"guidance_length": len(content),
-	}).Info("Successfully fetched review guidance from .whytho directory")
-
-	return content, nil
+	return project.PathWithNamespace, nil
 }
 
-func (g *GitLabService) GetWhyThoConfig(projectID, mrIID int, targetBranch string, changes []models.MRChange) (*models.WhyThoConfig, error) {
-	logrus.WithFields(logrus.Fields{
-		"project_id": projectID,
-		"mr_iid":     mrIID,
-		"branch":     targetBranch,
-	}).Debug("Fetching WhyTho config")
-
-	configPath := ".whytho/config.yaml"
-
-	// First, check if .whytho/config.yaml is modified in the MR diff
-	for _, change := range changes {
-		if change.NewPath == configPath && !change.DeletedFile {
-			logrus.WithFields(logrus.Fields{
-				"project_id": projectID,
-				"mr_iid":     mrIID,
-			}).Info("WhyTho config found in MR diff, using modified version")
-
-			// Parse the new version from the diff
-			config, err := g.parseWhyThoConfigFromDiff(change.Diff)
-			if err != nil {
-				logrus.WithError(err).WithFields(logrus.Fields{
-					"project_id": projectID,
-					"mr_iid":     mrIID,
-				}).Warn("Failed to parse WhyTho config from diff, falling back to target branch")
-				break // Fall through to target branch lookup
-			}
-			return config, nil
-		}
+// RepoGroups returns the groups of the project's namespace. Projects in a
+// user namespace belong to no group.
+func (g *GitLabService) RepoGroups(repo string) ([]string, error) {
+	project, _, err := g.client.Projects.GetProject(repo, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get project %s: %w", repo, err)
 	}
+	if project.Namespace == nil || project.Namespace.Kind != "group" {
+		return nil, nil
+	}
+	return pathGroups(project.Namespace.FullPath), nil
+}
 
-	// If not in diff, fetch from target branch
-	return g.getWhyThoConfigFromBranch(projectID, targetBranch)
+func (g *GitLabService) GetPullRequest(repo string, number int) (*models.PullRequest, error) {
+	projectID, err := gitlabProjectID(repo)
+	if err != nil {
+		return nil, err
+	}
+	mr, err := g.GetMRDetails(projectID, number)
+	if err != nil {
+		return nil, err
+	}
+	return &models.PullRequest{
+		Title:        mr.Title,
+		Description:  mr.Description,
+		TargetBranch: mr.TargetBranch,
+		HeadSHA:      mr.SHA,
+		WebURL:       mr.WebURL,
+		Open:         mr.State == "opened",
+	}, nil
 }
 
-func (g *GitLabService) parseWhyThoConfigFromDiff(diff string) (*models.WhyThoConfig, error) {
-	lines := strings.Split(diff, "\n")
-	var yamlContent strings.Builder
+func (g *GitLabService) GetChanges(repo string, number int) ([]models.MRChange, error) {
+	projectID, err := gitlabProjectID(repo)
+	if err != nil {
+		return nil, err
+	}
+	return g.GetMRChanges(projectID, number)
+}
 
-	for _, line := range lines {
-		if strings.HasPrefix(line, "+") && !strings.HasPrefix(line, "+++") {
-			// Remove the '+' prefix and add to YAML content
-			yamlContent.WriteString(strings.TrimPrefix(line, "+") + "\n")
+func (g *GitLabService) GetFile(repo, path, ref string) (string, error) {
+	opts := &gitlab.GetRawFileOptions{}
+	if ref != "" {
+		opts.Ref = &ref
+	}
+	content, resp, err := g.client.RepositoryFiles.GetRawFile(repo, path, opts)
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			return "", ErrFileNotFound
 		}
+		return "", fmt.Errorf("failed to get file %s: %w", path, err)
 	}
+	return string(content), nil
+}
 
-	var config models.WhyThoConfig
-	if err := yaml.Unmarshal([]byte(yamlContent.String()), &config); err != nil {
-		return nil, fmt.Errorf("failed to parse YAML from diff: %w", err)
+func (g *GitLabService) PostInlineComment(repo string, number int, comment models.PositionedComment) error {
+	projectID, err := gitlabProjectID(repo)
+	if err != nil {
+		return err
 	}
-
-	return &config, nil
+	return g.PostPositionedMRComment(projectID, number, comment)
 }
 
-func (g *GitLabService) getWhyThoConfigFromBranch(projectID int, branch string) (*models.WhyThoConfig, error) {
-	logrus.WithFields(logrus.Fields{
-		"project_id": projectID,
-		"branch":     branch,
-	}).Debug("Fetching WhyTho config from target branch")
-
-	configPath := ".whytho/config.yaml"
-
-	// Try to fetch .whytho/config.yaml from the repository
-	file, _, err := g.client.RepositoryFiles.GetFile(projectID, configPath, &gitlab.GetFileOptions{
-		Ref: &branch,
-	})
+func (g *GitLabService) PostGeneralComment(repo string, number int, body string) error {
+	projectID, err := gitlabProjectID(repo)
 	if err != nil {
-		// Check if it's a 404 error (file not found)
-		if strings.Contains(err.Error(), "404") {
-			logrus.WithFields(logrus.Fields{
-				"project_id": projectID,
-				"branch":     branch,
-			}).Debug("No .whytho/config.yaml file found in repository")
-			return &models.WhyThoConfig{ExcludePaths: []string{}}, nil // Return empty config
-		}
-
-		logrus.WithError(err).WithFields(logrus.Fields{
-			"project_id": projectID,
-			"branch":     branch,
-		}).Error("Failed to fetch .whytho/config.yaml from repository")
-		return nil, fmt.Errorf("failed to fetch .whytho/config.yaml: %w", err)
+		return err
 	}
+	return g.PostMRComment(projectID, number, body)
+}
 
-	// Decode the file content (GitLab returns base64 encoded content)
-	content := file.Content
-	if file.Encoding == "base64" {
-		decoded, err := base64.StdEncoding.DecodeString(content)
+func (g *GitLabService) ListComments(repo string, number int) ([]models.SCMComment, error) {
+	opts := &gitlab.ListMergeRequestNotesOptions{ListOptions: gitlab.ListOptions{PerPage: 100}}
+	var comments []models.SCMComment
+	for {
+		notes, resp, err := g.client.Notes.ListMergeRequestNotes(repo, number, opts)
 		if err != nil {
-			logrus.WithError(err).WithFields(logrus.Fields{
-				"project_id": projectID,
-				"branch":     branch,
-			}).Error("Failed to decode .whytho/config.yaml content")
-			return nil, fmt.Errorf("failed to decode .whytho/config.yaml: %w", err)
+			return nil, fmt.Errorf("failed to list merge request notes: %w", err)
 		}
-		content = string(decoded)
+		for _, note := range notes {
+			comments = append(comments, models.SCMComment{ID: int64(note.ID), Body: note.Body, Author: note.Author.Username})
+		}
+		if resp == nil || resp.NextPage == 0 {
+			return comments, nil
+		}
+		opts.Page = resp.NextPage
 	}
+}
 
-	// Parse YAML content
-	var config models.WhyThoConfig
-	if err := yaml.Unmarshal([]byte(content), &config); err != nil {
-		logrus.WithError(err).WithFields(logrus.Fields{
-			"project_id": projectID,
-			"branch":     branch,
-		}).Error("Failed to parse .whytho/config.yaml content")
-		return nil, fmt.Errorf("failed to parse .whytho/config.yaml: %w", err)
+func (g *GitLabService) UpdateComment(repo string, number int, commentID int64, body string) error {
+	if _, _, err := g.client.Notes.UpdateMergeRequestNote(repo, number, int(commentID), &gitlab.UpdateMergeRequestNoteOptions{
+		Body: &body,
+	}); err != nil {
+		return fmt.Errorf("failed to update note: %w", err)
+	}
+	return nil
+}
+
+func (g *GitLabService) SetStatus(repo, sha, state, description, targetURL string) error {
+	projectID, err := gitlabProjectID(repo)
+	if err != nil {
+		return err
 	}
+	return g.SetReviewStatus(projectID, sha, state, description, targetURL)
+}
 
-	logrus.WithFields(logrus.Fields{
-		"project_id":    projectID,
-		"branch":        branch,
-		"exclude_paths": len(config.ExcludePaths),
-	}).Info("Successfully fetched WhyTho config from repository")
+func (g *GitLabService) BotUsername() (string, error) {
+	user, err := g.BotUser()
+	if err != nil {
+		return "", err
+	}
+	return user.Username, nil
+}
 
-	return &config, nil
+// formatSuggestion renders a comment's replacement code. On diff notes it is a
+// GitLab suggestion covering the validated range around the commented line,
+// elsewhere a plain code block since GitLab cannot apply it.
+func formatSuggestion(comment models.PositionedComment, applicable bool) string {
+	if comment.Suggestion == "" {
+		return ""
+	}
+	if !applicable {
+		return fmt.Sprintf("\n\n**Suggested change:**\n```\n%s\n```", comment.Suggestion)
+	}
+	return fmt.Sprintf("\n\n```suggestion:-%d+%d\n%s\n```", comment.SuggestionLinesAbove, comment.SuggestionLinesBelow, comment.Suggestion)
 }
 
 func formatSeverity(severity string) string {
@@ -0,0 +1,124 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// pathGuidance holds the guidance files that the config's guidance mapping
+// applies to the reviewed files, so each batch gets only the relevant ones.
+type pathGuidance struct {
+	base  string
+	rules []guidanceRule
+	// contents holds the loaded guidance files by name, their path
+	// followed by the layer for inherited files
+	contents map[string]string
+}
+
+type guidanceRule struct {
+	Pattern string
+	Files   []string
+}
+
+// loadPathGuidance loads the guidance files mapped to any of the reviewed
+// files from the layer that mapped them. Files that cannot be loaded are
+// skipped.
+func (c *ConfigResolver) loadPathGuidance(resolved *ResolvedConfig, files []reviewFile) *pathGuidance {
+	g := &pathGuidance{base: resolved.Guidance, contents: make(map[string]string)}
+	config := resolved.Config
+	if len(config.Guidance) == 0 {
+		return g
+	}
+
+	for _, pattern := range sortedGuidancePatterns(config) {
+		if !matchesAnyFile(pattern, files) {
+			continue
+		}
+		layer := resolved.guidanceLayers[pattern]
+		rule := guidanceRule{Pattern: pattern}
+		for _, guidancePath := range config.Guidance[pattern] {
+			// Inherited files are named by their layer, as its paths may clash with the project's
+			name := guidancePath
+			if layer.shared {
+				name = fmt.Sprintf("%s from %s", guidancePath, layer.Name)
+			}
+			if _, loaded := g.contents[name]; !loaded {
+				content, err := c.readFile(layer, guidancePath)
+				if err != nil {
+					logrus.WithError(err).WithFields(logrus.Fields{
+						"project_id":    layer.repo,
+						"guidance_path": guidancePath,
+					}).Warn("Failed to fetch path guidance, skipping it")
+					continue
+				}
+				if content == "" {
+					logrus.WithFields(logrus.Fields{
+						"project_id":    layer.repo,
+						"layer":         layer.Name,
+						"guidance_path": guidancePath,
+						"pattern":       pattern,
+					}).Warn("Path guidance file not found, skipping it")
+					continue
+				}
+				g.contents[name] = content
+			}
+			rule.Files = append(rule.Files, name)
+		}
+		if len(rule.Files) > 0 {
+			g.rules = append(g.rules, rule)
+		}
+	}
+	return g
+}
+
+func matchesAnyFile(pattern string, files []reviewFile) bool {
+	for _, file := range files {
+		if MatchesPathPatterns(file.Path, []string{pattern}) {
+			return true
+		}
+	}
+	return false
+}
+
+// forFiles assembles the repository guidance with the sections of the
+// guidance files mapped to the given files. A file mapped by several
+// patterns is included once.
+func (g *pathGuidance) forFiles(files []reviewFile) string {
+	var sections strings.Builder
+	included := make(map[string]bool)
+	for _, rule := range g.rules {
+		if !matchesAnyFile(rule.Pattern, files) {
+			continue
+		}
+		for _, guidancePath := range rule.Files {
+			if included[guidancePath] {
+				continue
+			}
+			included[guidancePath] = true
+			sections.WriteString(fmt.Sprintf("### Guidance for files matching `%s` (%s)\n\n%s\n\n",
+				rule.Pattern, guidancePath, strings.TrimSpace(g.contents[guidancePath])))
+		}
+	}
+
+	if sections.Len() == 0 {
+		return g.base
+	}
+	pathSections := "## Path-specific guidance\nApply each section below only to the files matching its pattern.\n\n" + sections.String()
+	if g.base == "" {
+		return pathSections
+	}
+	return g.base + "\n\n" + pathSections
+}
+
+// paths lists the loaded guidance files for logging.
+func (g *pathGuidance) paths() []string {
+	paths := make([]string, 0, len(g.contents))
+	for guidancePath := range g.contents {
+		paths = append(paths, guidancePath)
+	}
+	sort.Strings(paths)
+	return paths
+}
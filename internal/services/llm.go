@@ -0,0 +1,130 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/genai"
+)
+
+const (
+	LLMProviderGemini    = "gemini"
+	LLMProviderOpenAI    = "openai"
+	LLMProviderAnthropic = "anthropic"
+	LLMProviderOllama    = "ollama"
+)
+
+// LLMProvider is a backend capable of turning a review prompt into model output.
+type LLMProvider interface {
+	Name() string
+	Generate(ctx context.Context, req LLMRequest) (string, error)
+}
+
+type LLMRequest struct {
+	Prompt      string
+	Temperature float32
+	// ResponseSchema, when set, asks the provider for JSON output matching the schema.
+	ResponseSchema *genai.Schema
+}
+
+// NewLLMProvider creates the provider selected by name. An empty model or
+// base URL falls back to the provider's default.
+func NewLLMProvider(provider, model, apiKey, baseURL string) (LLMProvider, error) {
+	logrus.WithFields(logrus.Fields{
+		"provider": provider,
+		"model":    model,
+	}).Info("Creating LLM provider")
+
+	switch strings.ToLower(provider) {
+	case "", LLMProviderGemini:
+		return NewGeminiProvider(apiKey, model)
+	case LLMProviderOpenAI:
+		return NewOpenAIProvider(apiKey, model, baseURL), nil
+	case LLMProviderAnthropic:
+		return NewAnthropicProvider(apiKey, model, baseURL), nil
+	case LLMProviderOllama:
+		return NewOllamaProvider(model, baseURL), nil
+	default:
+		return nil, fmt.Errorf("unsupported LLM provider %q", provider)
+	}
+}
+
+var llmHTTPClient = &http.Client{Timeout: 10 * time.Minute}
+
+// postLLMJSON sends a JSON request to an HTTP based LLM API and decodes the JSON response into out.
+func postLLMJSON(ctx context.Context, url string, headers map[string]string, payload, out interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := llmHTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to make HTTP request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(respBody))
+	}
+
+	if err := json.Unmarshal(respBody, out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return nil
+}
+
+// jsonSchema converts a genai schema into a standard JSON Schema document for
+// providers that accept JSON Schema directly (OpenAI-compatible APIs, Ollama).
+func jsonSchema(schema *genai.Schema) map[string]interface{} {
+	if schema == nil {
+		return nil
+	}
+
+	out := map[string]interface{}{}
+	if schema.Type != "" {
+		out["type"] = strings.ToLower(string(schema.Type))
+	}
+	if schema.Description != "" {
+		out["description"] = schema.Description
+	}
+	if len(schema.Enum) > 0 {
+		out["enum"] = schema.Enum
+	}
+	if schema.Items != nil {
+		out["items"] = jsonSchema(schema.Items)
+	}
+	if len(schema.Properties) > 0 {
+		properties := map[string]interface{}{}
+		for name, property := range schema.Properties {
+			properties[name] = jsonSchema(property)
+		}
+		out["properties"] = properties
+	}
+	if len(schema.Required) > 0 {
+		out["required"] = schema.Required
+	}
+	return out
+}
@@ -0,0 +1,157 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/vinamra28/whytho/internal/diff"
+	"github.com/vinamra28/whytho/internal/models"
+)
+
+// WorkingTree is the ref LocalRepository.GetFile reads from the working tree.
+const WorkingTree = "WORKTREE"
+
+// errLocalUnsupported is returned by the LocalRepository methods that would
+// write to a code host.
+var errLocalUnsupported = errors.New("not supported for local reviews")
+
+// LocalRepository implements SCMProvider for a local checkout so the review
+// pipeline can run without a code host. It reviews the changes since the
+// merge base with a base ref, or a patch, and only supports reading.
+type LocalRepository struct {
+	dir string
+	// base is the ref the changes are reviewed against; .whytho/ files are read
+	// from it as from a target branch. Empty reads them from the working tree
+	base string
+	// patch, when set, is reviewed instead of the repository's changes
+	patch string
+}
+
+// NewLocalRepository reviews the changes in dir since its merge base with base.
+func NewLocalRepository(dir, base string) *LocalRepository {
+	return &LocalRepository{dir: dir, base: base}
+}
+
+// NewLocalPatch reviews a git-style patch, reading .whytho/ files from dir.
+func NewLocalPatch(dir, patch string) *LocalRepository {
+	return &LocalRepository{dir: dir, patch: patch}
+}
+
+func (l *LocalRepository) Name() string {
+	return "local"
+}
+
+// GetPullRequest describes the changes by the subject and body of the last
+// commit. Patches have no description.
+func (l *LocalRepository) GetPullRequest(repo string, number int) (*models.PullRequest, error) {
+	if l.patch != "" {
+		return &models.PullRequest{Title: "Local patch", Open: true}, nil
+	}
+
+	headSHA, err := l.git("rev-parse", "HEAD")
+	if err != nil {
+		return nil, err
+	}
+	message, err := l.git("log", "-1", "--format=%B")
+	if err != nil {
+		return nil, err
+	}
+	title, description, _ := strings.Cut(strings.TrimSpace(message), "\n")
+	return &models.PullRequest{
+		Title:        title,
+		Description:  strings.TrimSpace(description),
+		TargetBranch: l.base,
+		HeadSHA:      strings.TrimSpace(headSHA),
+		Open:         true,
+	}, nil
+}
+
+// GetChanges returns the patch's files, or the difference between the merge
+// base with the base ref and the working tree, uncommitted changes to tracked
+// files included.
+func (l *LocalRepository) GetChanges(repo string, number int) ([]models.MRChange, error) {
+	if l.patch != "" {
+		return diff.SplitFiles(l.patch), nil
+	}
+
+	mergeBase, err := l.git("merge-base", l.base, "HEAD")
+	if err != nil {
+		return nil, fmt.Errorf("failed to find merge base with %s: %w", l.base, err)
+	}
+	raw, err := l.git("diff", "--no-color", "--no-ext-diff", "-M", strings.TrimSpace(mergeBase))
+	if err != nil {
+		return nil, fmt.Errorf("failed to diff against %s: %w", l.base, err)
+	}
+	return diff.SplitFiles(raw), nil
+}
+
+// GetFile reads a file at a ref, or from the working tree when ref is empty
+// or WorkingTree.
+func (l *LocalRepository) GetFile(repo, path, ref string) (string, error) {
+	if ref == "" || ref == WorkingTree {
+		content, err := os.ReadFile(filepath.Join(l.dir, filepath.FromSlash(path)))
+		if errors.Is(err, os.ErrNotExist) {
+			return "", ErrFileNotFound
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to read file %s: %w", path, err)
+		}
+		return string(content), nil
+	}
+
+	object := ref + ":" + path
+	if _, err := l.git("cat-file", "-e", object); err != nil {
+		return "", ErrFileNotFound
+	}
+	content, err := l.git("show", object)
+	if err != nil {
+		return "", fmt.Errorf("failed to get file %s: %w", path, err)
+	}
+	return content, nil
+}
+
+// RepoGroups returns no groups, as local repositories belong to none.
+func (l *LocalRepository) RepoGroups(repo string) ([]string, error) {
+	return nil, nil
+}
+
+func (l *LocalRepository) PostInlineComment(repo string, number int, comment models.PositionedComment) error {
+	return errLocalUnsupported
+}
+
+func (l *LocalRepository) PostGeneralComment(repo string, number int, body string) error {
+	return errLocalUnsupported
+}
+
+func (l *LocalRepository) ListComments(repo string, number int) ([]models.SCMComment, error) {
+	return nil, nil
+}
+
+func (l *LocalRepository) BotUsername() (string, error) {
+	return "", errLocalUnsupported
+}
+
+func (l *LocalRepository) UpdateComment(repo string, number int, commentID int64, body string) error {
+	return errLocalUnsupported
+}
+
+func (l *LocalRepository) SetStatus(repo, sha, state, description, targetURL string) error {
+	return errLocalUnsupported
+}
+
+// git runs a git command in the repository and returns its output.
+func (l *LocalRepository) git(args ...string) (string, error) {
+	cmd := exec.Command("git", append([]string{"-C", l.dir}, args...)...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
@@ -0,0 +1,79 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultOllamaModel   = "llama3.1"
+	defaultOllamaBaseURL = "http://localhost:11434"
+)
+
+// OllamaProvider uses a local Ollama server so code never leaves the network.
+type OllamaProvider struct {
+	model   string
+	baseURL string
+}
+
+type ollamaChatRequest struct {
+	Model    string                 `json:"model"`
+	Messages []openAIMessage        `json:"messages"`
+	Stream   bool                   `json:"stream"`
+	Format   interface{}            `json:"format,omitempty"`
+	Options  map[string]interface{} `json:"options,omitempty"`
+}
+
+type ollamaChatResponse struct {
+	Message openAIMessage `json:"message"`
+}
+
+func NewOllamaProvider(model, baseURL string) *OllamaProvider {
+	if model == "" {
+		model = defaultOllamaModel
+	}
+	if baseURL == "" {
+		baseURL = defaultOllamaBaseURL
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"model":    model,
+		"base_url": baseURL,
+	}).Info("Ollama client created successfully")
+	return &OllamaProvider{
+		model:   model,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+	}
+}
+
+func (p *OllamaProvider) Name() string {
+	return LLMProviderOllama
+}
+
+func (p *OllamaProvider) Generate(ctx context.Context, req LLMRequest) (string, error) {
+	payload := ollamaChatRequest{
+		Model:    p.model,
+		Messages: []openAIMessage{{Role: "user", Content: req.Prompt}},
+		Stream:   false,
+		Options: map[string]interface{}{
+			"temperature": req.Temperature,
+		},
+	}
+	if req.ResponseSchema != nil {
+		payload.Format = jsonSchema(req.ResponseSchema)
+	}
+
+	var resp ollamaChatResponse
+	if err := postLLMJSON(ctx, p.baseURL+"/api/chat", nil, payload, &resp); err != nil {
+		return "", fmt.Errorf("ollama request failed: %w", err)
+	}
+
+	if resp.Message.Content == "" {
+		return "", fmt.Errorf("no response generated")
+	}
+
+	return resp.Message.Content, nil
+}
@@ -0,0 +1,96 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultOpenAIModel   = "gpt-4o"
+	defaultOpenAIBaseURL = "https://api.openai.com/v1"
+)
+
+// OpenAIProvider talks to any OpenAI-compatible chat completions API
+// (OpenAI, Azure OpenAI proxies, vLLM, LiteLLM, ...).
+type OpenAIProvider struct {
+	apiKey  string
+	model   string
+	baseURL string
+}
+
+type openAIMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type openAIChatRequest struct {
+	Model          string                 `json:"model"`
+	Messages       []openAIMessage        `json:"messages"`
+	Temperature    float32                `json:"temperature"`
+	ResponseFormat map[string]interface{} `json:"response_format,omitempty"`
+}
+
+type openAIChatResponse struct {
+	Choices []struct {
+		Message openAIMessage `json:"message"`
+	} `json:"choices"`
+}
+
+func NewOpenAIProvider(apiKey, model, baseURL string) *OpenAIProvider {
+	if model == "" {
+		model = defaultOpenAIModel
+	}
+	if baseURL == "" {
+		baseURL = defaultOpenAIBaseURL
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"model":    model,
+		"base_url": baseURL,
+	}).Info("OpenAI-compatible client created successfully")
+	return &OpenAIProvider{
+		apiKey:  apiKey,
+		model:   model,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+	}
+}
+
+func (p *OpenAIProvider) Name() string {
+	return LLMProviderOpenAI
+}
+
+func (p *OpenAIProvider) Generate(ctx context.Context, req LLMRequest) (string, error) {
+	payload := openAIChatRequest{
+		Model:       p.model,
+		Messages:    []openAIMessage{{Role: "user", Content: req.Prompt}},
+		Temperature: req.Temperature,
+	}
+	if req.ResponseSchema != nil {
+		payload.ResponseFormat = map[string]interface{}{
+			"type": "json_schema",
+			"json_schema": map[string]interface{}{
+				"name":   "response",
+				"schema": jsonSchema(req.ResponseSchema),
+			},
+		}
+	}
+
+	headers := map[string]string{}
+	if p.apiKey != "" {
+		headers["Authorization"] = "Bearer " + p.apiKey
+	}
+
+	var resp openAIChatResponse
+	if err := postLLMJSON(ctx, p.baseURL+"/chat/completions", headers, payload, &resp); err != nil {
+		return "", fmt.Errorf("openai request failed: %w", err)
+	}
+
+	if len(resp.Choices) == 0 {
+		return "", fmt.Errorf("no response generated")
+	}
+
+	return resp.Choices[0].Message.Content, nil
+}
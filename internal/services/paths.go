@@ -0,0 +1,242 @@
+package services
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/vinamra28/whytho/internal/models"
+)
+
+// MatchesPathPatterns reports whether filePath is selected by an ordered list
+// of gitignore-style patterns:
+//   - "*", "?" and "[...]" match within a path segment, "**" across segments
+//     and "{a,b}" matches either alternative
+//   - a pattern without a slash, such as "*.pb.go", matches at any depth;
+//     a leading "/" anchors a pattern to the repository root
+//   - a pattern matching a directory, such as "vendor" or "docs/", matches
+//     everything below it
+//   - a pattern starting with "!" re-includes what earlier patterns matched,
+//     the last matching pattern wins
+func MatchesPathPatterns(filePath string, patterns []string) bool {
+	matched := false
+	for _, pattern := range patterns {
+		negated := strings.HasPrefix(pattern, "!")
+		if negated {
+			pattern = pattern[1:]
+		}
+		if matchPathPattern(pattern, filePath) {
+			matched = !negated
+		}
+	}
+	return matched
+}
+
+// ValidatePathPattern reports syntax errors such as unclosed brackets or braces.
+func ValidatePathPattern(pattern string) error {
+	pattern = strings.TrimPrefix(pattern, "!")
+	if strings.Trim(pattern, "/") == "" {
+		return fmt.Errorf("empty path pattern %q", pattern)
+	}
+	alternatives, err := expandBraces(pattern)
+	if err != nil {
+		return err
+	}
+	for _, alternative := range alternatives {
+		for _, segment := range strings.Split(alternative, "/") {
+			if _, err := path.Match(segment, ""); err != nil {
+				return fmt.Errorf("invalid path pattern %q: %w", pattern, err)
+			}
+		}
+	}
+	return nil
+}
+
+func matchPathPattern(pattern, filePath string) bool {
+	alternatives, err := expandBraces(pattern)
+	if err != nil {
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"file_path": filePath,
+			"pattern":   pattern,
+		}).Warn("Invalid path pattern, skipping")
+		return false
+	}
+
+	fileSegments := strings.Split(strings.TrimPrefix(filePath, "/"), "/")
+	for _, alternative := range alternatives {
+		anchored := strings.HasPrefix(alternative, "/")
+		dirOnly := strings.HasSuffix(alternative, "/")
+		alternative = strings.Trim(alternative, "/")
+		if alternative == "" {
+			continue
+		}
+		if !anchored && !strings.Contains(alternative, "/") {
+			alternative = "**/" + alternative
+		}
+		patternSegments := strings.Split(alternative, "/")
+
+		// A match on one of the file's directories covers the file as well
+		for n := len(fileSegments); n > 0; n-- {
+			if dirOnly && n == len(fileSegments) {
+				continue
+			}
+			if matchSegments(patternSegments, fileSegments[:n]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matchSegments matches path segments, "**" standing for any number of them.
+func matchSegments(pattern, segments []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for len(pattern) > 0 && pattern[0] == "**" {
+				pattern = pattern[1:]
+			}
+			for i := 0; i <= len(segments); i++ {
+				if matchSegments(pattern, segments[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(segments) == 0 {
+			return false
+		}
+		if matched, err := path.Match(pattern[0], segments[0]); err != nil || !matched {
+			return false
+		}
+		pattern, segments = pattern[1:], segments[1:]
+	}
+	return len(segments) == 0
+}
+
+// expandBraces expands "{a,b}" alternatives, e.g. "*.{yml,yaml}" into
+// "*.yml" and "*.yaml".
+func expandBraces(pattern string) ([]string, error) {
+	start := strings.Index(pattern, "{")
+	if start < 0 {
+		if strings.Contains(pattern, "}") {
+			return nil, fmt.Errorf("invalid path pattern %q: unmatched }", pattern)
+		}
+		return []string{pattern}, nil
+	}
+
+	depth := 0
+	var options []string
+	optionStart := start + 1
+	for i := start; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '{':
+			depth++
+		case ',':
+			if depth == 1 {
+				options = append(options, pattern[optionStart:i])
+				optionStart = i + 1
+			}
+		case '}':
+			depth--
+			if depth > 0 {
+				continue
+			}
+			options = append(options, pattern[optionStart:i])
+			var expanded []string
+			for _, option := range options {
+				alternatives, err := expandBraces(pattern[:start] + option + pattern[i+1:])
+				if err != nil {
+					return nil, err
+				}
+				expanded = append(expanded, alternatives...)
+			}
+			return expanded, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid path pattern %q: unmatched {", pattern)
+}
+
+// pathSettings is how a file is reviewed under a WhyTho config.
+type pathSettings struct {
+	Excluded bool
+	// MinSeverity drops findings below it when set
+	MinSeverity string
+	Suggestions bool
+}
+
+// resolvePathSettings applies includePaths, excludePaths and then every
+// matching override, in order, to a file.
+func resolvePathSettings(config *models.WhyThoConfig, filePath string) pathSettings {
+	settings := pathSettings{Suggestions: true}
+	if config == nil {
+		return settings
+	}
+
+	if len(config.IncludePaths) > 0 && !MatchesPathPatterns(filePath, config.IncludePaths) {
+		settings.Excluded = true
+	}
+	if MatchesPathPatterns(filePath, config.ExcludePaths) {
+		settings.Excluded = true
+	}
+	for _, override := range config.Overrides {
+		if !MatchesPathPatterns(filePath, override.Paths) {
+			continue
+		}
+		if override.Exclude != nil {
+			settings.Excluded = *override.Exclude
+		}
+		if override.MinSeverity != "" {
+			settings.MinSeverity = strings.ToUpper(override.MinSeverity)
+		}
+		if override.Suggestions != nil {
+			settings.Suggestions = *override.Suggestions
+		}
+	}
+	return settings
+}
+
+// severityRank orders the known severities, 0 being unknown.
+func severityRank(severity string) int {
+	switch strings.ToUpper(severity) {
+	case "CRITICAL":
+		return 4
+	case "HIGH":
+		return 3
+	case "MEDIUM":
+		return 2
+	case "LOW":
+		return 1
+	default:
+		return 0
+	}
+}
+
+// applyPathOverrides drops findings below their file's minimum severity and
+// strips suggestions where they are turned off. Findings of unknown severity
+// are kept.
+func applyPathOverrides(config *models.WhyThoConfig, comments []models.PositionedComment) []models.PositionedComment {
+	if config == nil || len(config.Overrides) == 0 {
+		return comments
+	}
+
+	var result []models.PositionedComment
+	for _, comment := range comments {
+		settings := resolvePathSettings(config, comment.FilePath)
+		if rank := severityRank(comment.Severity); rank > 0 && rank < severityRank(settings.MinSeverity) {
+			logrus.WithFields(logrus.Fields{
+				"file_path":    comment.FilePath,
+				"severity":     comment.Severity,
+				"min_severity": settings.MinSeverity,
+			}).Debug("Dropping finding below the path's minimum severity")
+			continue
+		}
+		if !settings.Suggestions {
+			comment.Suggestion = ""
+			comment.SuggestionStartLine, comment.SuggestionEndLine = 0, 0
+			comment.SuggestionLinesAbove, comment.SuggestionLinesBelow = 0, 0
+		}
+		result = append(result, comment)
+	}
+	return result
+}
@@ -0,0 +1,217 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMatchesPathPatterns(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		matches  []string
+		misses   []string
+	}{
+		{
+			name:     "no patterns",
+			patterns: nil,
+			misses:   []string{"main.go"},
+		},
+		{
+			name:     "basename at any depth",
+			patterns: []string{"*.pb.go"},
+			matches:  []string{"api.pb.go", "proto/v1/api.pb.go"},
+			misses:   []string{"api.go", "api.pb.go.orig"},
+		},
+		{
+			name:     "directory name at any depth",
+			patterns: []string{"vendor"},
+			matches:  []string{"vendor/github.com/x/y.go", "tools/vendor/a.go", "vendor"},
+			misses:   []string{"vendoring/a.go", "src/myvendor/a.go"},
+		},
+		{
+			name:     "anchored to the root",
+			patterns: []string{"/vendor"},
+			matches:  []string{"vendor/a.go", "vendor"},
+			misses:   []string{"lib/vendor/a.go"},
+		},
+		{
+			name:     "anchored file",
+			patterns: []string{"/main.go"},
+			matches:  []string{"main.go"},
+			misses:   []string{"cmd/main.go"},
+		},
+		{
+			name:     "pattern with a slash is relative to the root",
+			patterns: []string{"internal/gen"},
+			matches:  []string{"internal/gen/a.go", "internal/gen"},
+			misses:   []string{"pkg/internal/gen/a.go"},
+		},
+		{
+			name:     "directory only",
+			patterns: []string{"docs/"},
+			matches:  []string{"docs/index.md", "docs/api/v1.md", "site/docs/index.md"},
+			misses:   []string{"docs", "site/docs", "docs.md"},
+		},
+		{
+			name:     "braces",
+			patterns: []string{"*.{yml,yaml}"},
+			matches:  []string{"app.yml", ".github/workflows/ci.yaml"},
+			misses:   []string{"app.json", "yml/app.json"},
+		},
+		{
+			name:     "nested braces",
+			patterns: []string{"*.{go,{yml,yaml}}"},
+			matches:  []string{"main.go", "config/app.yml", "config/app.yaml"},
+			misses:   []string{"app.json"},
+		},
+		{
+			name:     "braces with directories",
+			patterns: []string{"{cmd,internal/{api,db}}/**"},
+			matches:  []string{"cmd/main.go", "internal/api/handler.go", "internal/db/sql/schema.go"},
+			misses:   []string{"internal/queue/queue.go", "pkg/cmd/main.go"},
+		},
+		{
+			name:     "double star matches everything",
+			patterns: []string{"**"},
+			matches:  []string{"main.go", "a/b/c/d.txt"},
+		},
+		{
+			name:     "double star spans zero or more directories",
+			patterns: []string{"src/**/test.go"},
+			matches:  []string{"src/test.go", "src/a/test.go", "src/a/b/c/test.go"},
+			misses:   []string{"lib/src/test.go", "src/a/test.go.bak"},
+		},
+		{
+			name:     "leading double star",
+			patterns: []string{"**/testdata/*.json"},
+			matches:  []string{"testdata/a.json", "pkg/x/testdata/b.json"},
+			misses:   []string{"pkg/testdata/sub/c.json"},
+		},
+		{
+			name:     "trailing double star",
+			patterns: []string{"docs/**"},
+			matches:  []string{"docs/a.md", "docs/a/b/c.md"},
+			misses:   []string{"site/docs/a.md"},
+		},
+		{
+			name:     "single character and class wildcards",
+			patterns: []string{"file?.txt", "[ab].go"},
+			matches:  []string{"file1.txt", "x/fileA.txt", "a.go", "pkg/b.go"},
+			misses:   []string{"file10.txt", "c.go"},
+		},
+		{
+			name:     "star does not cross directories",
+			patterns: []string{"internal/*.go"},
+			matches:  []string{"internal/a.go"},
+			misses:   []string{"internal/sub/a.go"},
+		},
+		{
+			name:     "re-include after exclude",
+			patterns: []string{"*.go", "!*_test.go"},
+			matches:  []string{"main.go", "pkg/util.go"},
+			misses:   []string{"main_test.go", "pkg/util_test.go", "README.md"},
+		},
+		{
+			name:     "later pattern wins over re-include",
+			patterns: []string{"!*_test.go", "*.go"},
+			matches:  []string{"main.go", "main_test.go"},
+		},
+		{
+			name:     "re-include a file inside an excluded directory",
+			patterns: []string{"docs/", "!docs/keep.md"},
+			matches:  []string{"docs/other.md", "docs/sub/keep.md"},
+			misses:   []string{"docs/keep.md"},
+		},
+		{
+			name:     "exclude again after re-include",
+			patterns: []string{"gen/", "!gen/api/", "gen/api/*_mock.go"},
+			matches:  []string{"gen/a.go", "gen/api/client_mock.go"},
+			misses:   []string{"gen/api/client.go"},
+		},
+		{
+			name:     "leading slash in the file path",
+			patterns: []string{"/vendor"},
+			matches:  []string{"/vendor/a.go"},
+		},
+		{
+			name:     "invalid pattern is skipped",
+			patterns: []string{"*.{go", "*.md"},
+			matches:  []string{"README.md"},
+			misses:   []string{"main.go"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, filePath := range tt.matches {
+				if !MatchesPathPatterns(filePath, tt.patterns) {
+					t.Errorf("%q is not matched by %q", filePath, tt.patterns)
+				}
+			}
+			for _, filePath := range tt.misses {
+				if MatchesPathPatterns(filePath, tt.patterns) {
+					t.Errorf("%q is matched by %q", filePath, tt.patterns)
+				}
+			}
+		})
+	}
+}
+
+func TestExpandBraces(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    []string
+		wantErr bool
+	}{
+		{pattern: "*.go", want: []string{"*.go"}},
+		{pattern: "*.{yml,yaml}", want: []string{"*.yml", "*.yaml"}},
+		{pattern: "{a,b}/{c,d}", want: []string{"a/c", "a/d", "b/c", "b/d"}},
+		{pattern: "*.{go,{yml,yaml}}", want: []string{"*.go", "*.yml", "*.yaml"}},
+		{pattern: "{cmd,internal/{api,db}}/**", want: []string{"cmd/**", "internal/api/**", "internal/db/**"}},
+		{pattern: "a{,.bak}", want: []string{"a", "a.bak"}},
+		{pattern: "{single}", want: []string{"single"}},
+		{pattern: "*.{go", wantErr: true},
+		{pattern: "*.go}", wantErr: true},
+		{pattern: "{a,{b,c}", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			got, err := expandBraces(tt.pattern)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expandBraces(%q) error = %v, want error %v", tt.pattern, err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expandBraces(%q) = %q, want %q", tt.pattern, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidatePathPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		wantErr bool
+	}{
+		{pattern: "*.go"},
+		{pattern: "!docs/"},
+		{pattern: "/vendor"},
+		{pattern: "**/*.{yml,yaml}"},
+		{pattern: "[a-z]*.go"},
+		{pattern: "", wantErr: true},
+		{pattern: "/", wantErr: true},
+		{pattern: "!", wantErr: true},
+		{pattern: "[a-z.go", wantErr: true},
+		{pattern: "*.{yml,yaml", wantErr: true},
+		{pattern: "{a,[b}", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			if err := ValidatePathPattern(tt.pattern); (err != nil) != tt.wantErr {
+				t.Errorf("ValidatePathPattern(%q) error = %v, want error %v", tt.pattern, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// restClient is a small JSON client for the REST APIs of the SCM providers
+// that have no client library in this module.
+type restClient struct {
+	// provider names the API in error messages
+	provider string
+	baseURL  string
+	// headers are sent with every request, e.g. the Authorization header
+	headers map[string]string
+	http    *http.Client
+}
+
+func newRESTClient(provider, baseURL string, headers map[string]string) *restClient {
+	return &restClient{
+		provider: provider,
+		baseURL:  strings.TrimSuffix(baseURL, "/"),
+		headers:  headers,
+		http:     &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+// apiStatusError is returned when an API answers with a non-2xx status.
+type apiStatusError struct {
+	Provider   string
+	StatusCode int
+	Body       string
+}
+
+func (e *apiStatusError) Error() string {
+	return fmt.Sprintf("%s API returned status %d: %s", e.Provider, e.StatusCode, e.Body)
+}
+
+func isNotFound(err error) bool {
+	var statusErr *apiStatusError
+	return errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotFound
+}
+
+// do sends in as the JSON body, if set, and decodes the response into out,
+// if set. path is relative to the API base URL and may carry a query.
+func (c *restClient) do(method, path string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		encoded, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+		body = bytes.NewReader(encoded)
+	}
+
+	respBody, err := c.send(method, path, body, "application/json")
+	if err != nil {
+		return err
+	}
+	if out == nil || len(respBody) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(respBody, out); err != nil {
+		return fmt.Errorf("failed to decode %s API response: %w", c.provider, err)
+	}
+	return nil
+}
+
+// send performs a request and returns the raw response body.
+func (c *restClient) send(method, path string, body io.Reader, accept string) ([]byte, error) {
+	respBody, _, err := c.exchange(method, path, body, accept)
+	return respBody, err
+}
+
+// exchange performs a request and returns the raw response body and headers.
+func (c *restClient) exchange(method, path string, body io.Reader, accept string) ([]byte, http.Header, error) {
+	req, err := http.NewRequest(method, c.baseURL+path, body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+	for key, value := range c.headers {
+		req.Header.Set(key, value)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", accept)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to make HTTP request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, nil, &apiStatusError{Provider: c.provider, StatusCode: resp.StatusCode, Body: string(respBody)}
+	}
+	return respBody, resp.Header, nil
+}
+
+// botUserCache remembers the username of the account the bot posts as,
+// which does not change while the server runs.
+type botUserCache struct {
+	mu       sync.Mutex
+	username string
+}
+
+// get returns the cached username, calling fetch until it succeeds once.
+func (c *botUserCache) get(fetch func() (string, error)) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.username != "" {
+		return c.username, nil
+	}
+	username, err := fetch()
+	if err != nil {
+		return "", fmt.Errorf("failed to get current user: %w", err)
+	}
+	c.username = username
+	return username, nil
+}
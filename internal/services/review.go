@@ -2,55 +2,89 @@ package services
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
-	"path/filepath"
-	"strconv"
 	"strings"
 
 	"github.com/sirupsen/logrus"
+	"github.com/vinamra28/whytho/internal/diff"
 	"github.com/vinamra28/whytho/internal/models"
 	"google.golang.org/genai"
 )
 
+// maxReviewRepairAttempts bounds how often a malformed review is sent back to the LLM
+const maxReviewRepairAttempts = 2
+
 type ReviewService struct {
-	client *genai.Client
+	llm LLMProvider
+	// maxPromptTokens is the estimated prompt size a single review request may use
+	maxPromptTokens int
+	// batchConcurrency limits how many batches of a large MR are reviewed at once
+	batchConcurrency int
+	// configs resolves the .whytho/ configuration a review applies
+	configs *ConfigResolver
 }
 
-func NewReviewService(apiKey string) *ReviewService {
-	logrus.Info("Creating Gemini AI client for code review")
-	ctx := context.Background()
-	config := &genai.ClientConfig{
-		APIKey: apiKey,
-	}
-	client, err := genai.NewClient(ctx, config)
-	if err != nil {
-		logrus.WithError(err).Fatal("Failed to create Gemini client")
-		panic(fmt.Sprintf("Failed to create Gemini client: %v", err))
+func NewReviewService(llm LLMProvider, maxPromptTokens, batchConcurrency int) *ReviewService {
+	if batchConcurrency < 1 {
+		batchConcurrency = 1
 	}
 
-	logrus.Info("Gemini AI client created successfully")
+	logrus.WithFields(logrus.Fields{
+		"provider":          llm.Name(),
+		"max_prompt_tokens": maxPromptTokens,
+		"batch_concurrency": batchConcurrency,
+	}).Info("Creating review service")
 	return &ReviewService{
-		client: client,
+		llm:              llm,
+		maxPromptTokens:  maxPromptTokens,
+		batchConcurrency: batchConcurrency,
+		configs:          NewConfigResolver("", "", 0),
 	}
 }
 
-func (r *ReviewService) ReviewCode(changes []models.MRChange, title, description string, gitlabService *GitLabService, projectID, mrIID int, targetBranch string) (*models.CodeReview, error) {
+// WithConfigResolver returns a copy of the service that resolves the
+// .whytho/ configuration through configs, e.g. to inherit group config.
+func (r *ReviewService) WithConfigResolver(configs *ConfigResolver) *ReviewService {
+	resolving := *r
+	resolving.configs = configs
+	return &resolving
+}
+
+// ResolveConfig resolves the .whytho/ configuration of a review target.
+func (r *ReviewService) ResolveConfig(target ReviewTarget, changes []models.MRChange) *ResolvedConfig {
+	return r.configs.Resolve(target, changes)
+}
+
+// reviewConfig resolves the configuration a review applies. When the target
+// branch's configuration gates merge requests, the version modified by the
+// merge request is ignored, so it cannot exclude files from the gate or drop
+// the findings that would block it.
+func (r *ReviewService) reviewConfig(target ReviewTarget, changes []models.MRChange) *ResolvedConfig {
+	if !modifiesWhyThoFiles(changes) {
+		return r.configs.Resolve(target, changes)
+	}
+	base := r.configs.Resolve(target, nil)
+	if base.Config.Gating == nil {
+		return r.configs.Resolve(target, changes)
+	}
+	logrus.WithFields(logrus.Fields{
+		"project_id": target.Repo,
+		"mr_iid":     target.Number,
+	}).Info("Merge request is gated, ignoring its modified WhyTho config")
+	return base
+}
+
+func (r *ReviewService) ReviewCode(ctx context.Context, changes []models.MRChange, title, description string, target ReviewTarget) (*models.CodeReview, error) {
+	projectID, mrIID := target.Repo, target.Number
 	logrus.WithFields(logrus.Fields{
 		"changes_count": len(changes),
 		"mr_title":      title,
 	}).Info("Starting AI code review")
 
-	ctx := context.Background()
-
-	// Fetch WhyTho config to filter excluded paths
-	whyThoConfig, err := gitlabService.GetWhyThoConfig(projectID, mrIID, targetBranch, changes)
-	if err != nil {
-		logrus.WithError(err).WithFields(logrus.Fields{
-			"project_id": projectID,
-			"mr_iid":     mrIID,
-		}).Warn("Failed to fetch WhyTho config, proceeding without path filtering")
-		whyThoConfig = &models.WhyThoConfig{ExcludePaths: []string{}}
-	}
+	// Resolve the inherited and repository WhyTho config to filter excluded paths
+	resolved := r.reviewConfig(target, changes)
+	whyThoConfig := resolved.Config
 
 	// Filter out excluded paths
 	filteredChanges, excludedFiles := r.filterExcludedChanges(changes, whyThoConfig)
@@ -96,7 +130,7 @@ func (r *ReviewService) ReviewCode(changes []models.MRChange, title, description
 
 	logrus.Debug("Building code content for AI review")
 
-	processedFiles := 0
+	var files []reviewFile
 	for _, change := range filteredChanges {
 		if change.DeletedFile {
 			logrus.WithField("file", change.OldPath).Debug("Skipping deleted file")
@@ -109,43 +143,61 @@ func (r *ReviewService) ReviewCode(changes []models.MRChange, title, description
 			"renamed":  change.RenamedFile,
 		}).Debug("Processing file change")
 
-		codeContent.WriteString(fmt.Sprintf("## File: %s\n", change.NewPath))
-		if change.NewFile {
-			codeContent.WriteString("(New file)\n")
-		}
-		if change.RenamedFile {
-			codeContent.WriteString(fmt.Sprintf("(Renamed from: %s)\n", change.OldPath))
-		}
+		files = append(files, r.renderReviewFile(change))
+	}
+
+	logrus.WithField("processed_files", len(files)).Debug("Finished processing file changes")
 
-		// Process the diff to add line numbers for AI reference
-		codeContent.WriteString("```diff\n")
-		processedDiff := r.addLineNumbersToDiff(change.Diff)
-		codeContent.WriteString(processedDiff)
-		codeContent.WriteString("\n```\n\n")
-		processedFiles++
+	if resolved.Guidance != "" {
+		logrus.WithFields(logrus.Fields{
+			"project_id":      projectID,
+			"guidance_length": len(resolved.Guidance),
+		}).Info("Using custom review guidance")
+	} else {
+		logrus.WithField("project_id", projectID).Info("Using default review guidance")
 	}
 
-	logrus.WithField("processed_files", processedFiles).Debug("Finished processing file changes")
+	// Guidance mapped to paths is only given to the batches containing matching files
+	pathGuidance := r.configs.loadPathGuidance(resolved, files)
+	if len(pathGuidance.rules) > 0 {
+		logrus.WithFields(logrus.Fields{
+			"project_id":     projectID,
+			"guidance_files": pathGuidance.paths(),
+		}).Info("Using path-specific review guidance")
+	}
 
-	// Fetch custom review guidance from the repository
-	guidance, err := gitlabService.GetReviewGuidance(projectID, targetBranch)
+	// Whatever is left of the budget after the fixed prompt, including all
+	// guidance any batch may get, is shared by the file diffs
+	budget := r.maxPromptTokens - estimateTokens(buildReviewPrompt(pathGuidance.forFiles(files), codeContent.String()+batchNote))
+	batches, skippedFiles, err := planReviewBatches(files, budget)
 	if err != nil {
-		logrus.WithError(err).WithFields(logrus.Fields{
-			"project_id": projectID,
-			"branch":     targetBranch,
-		}).Warn("Failed to fetch custom review guidance, using default")
-		guidance = "" // Use default guidance
+		return nil, err
 	}
 
-	// Build the prompt with custom or default guidance
-	var prompt string
-	if guidance != "" {
-		logrus.WithFields(logrus.Fields{
-			"project_id":      projectID,
-			"guidance_length": len(guidance),
-		}).Info("Using custom review guidance from repository")
+	logrus.WithFields(logrus.Fields{
+		"project_id":    projectID,
+		"mr_iid":        mrIID,
+		"files":         len(files),
+		"batches":       len(batches),
+		"skipped_files": skippedFiles,
+		"file_budget":   budget,
+	}).Info("Planned review batches")
+
+	reviews, err := r.reviewBatches(ctx, pathGuidance, codeContent.String(), batches)
+	if err != nil {
+		return nil, err
+	}
 
-		prompt = fmt.Sprintf(`You are an expert code reviewer with deep knowledge of software engineering best practices. Review the following merge request changes according to the custom guidance provided below.
+	review := r.mergeReviews(ctx, title, reviews, skippedFiles)
+	review.PositionedComments = applyPathOverrides(whyThoConfig, review.PositionedComments)
+	return review, nil
+}
+
+// buildReviewPrompt wraps the MR details and file diffs with the review
+// instructions, using the repository's custom guidance when there is one.
+func buildReviewPrompt(guidance, content string) string {
+	if guidance != "" {
+		return fmt.Sprintf(`You are an expert code reviewer with deep knowledge of software engineering best practices. Review the following merge request changes according to the custom guidance provided below.
 
 CUSTOM REVIEW GUIDANCE:
 %s
@@ -158,18 +210,20 @@ When providing feedback, be solution-oriented and include specific improvement s
 - For security issues: Recommend secure coding practices and specific fixes
 - For maintainability: Suggest ways to make code more readable, testable, or modular
 
-Please format your response as follows:
-- Start with a summary paragraph highlighting the most important findings
-- Then provide specific comments in this EXACT format:
-  COMMENT:filename.go:diff_line_number:line_type:severity:comment_text
-  
-  Where:
-  - filename.go is the file path (exactly as shown in the diff)
-  - diff_line_number is the DIFF_LINE number shown in brackets (e.g., if you see [DIFF_LINE:5,NEW_LINE:42], use 5)
-  - line_type is either "new" (for lines starting with +), "old" (for lines starting with -), or "context" (for lines starting with space)
-  - severity is one of: LOW, MEDIUM, HIGH, or CRITICAL
-  - comment_text is your detailed feedback with specific suggestions
-  
+Please respond with a single JSON object (no surrounding prose) with these fields:
+- "summary": a summary paragraph highlighting the most important findings
+- "positioned_comments": an array of line-specific findings, each with:
+  - "file_path": the file path (exactly as shown in the "## File:" header)
+  - "line_number": the DIFF_LINE number shown in brackets (e.g., if you see [DIFF_LINE:5,NEW_LINE:42], use 5)
+  - "line_type": "new" (for lines starting with +), "old" (for lines starting with -), or "context" (for lines starting with space)
+  - "severity": one of LOW, MEDIUM, HIGH, or CRITICAL
+  - "rule": a short kebab-case identifier for the kind of issue (e.g. "error-handling", "sql-injection", "naming")
+  - "comment": your detailed feedback with specific suggestions (markdown allowed)
+  - "start_line_number" (optional): when the finding is about a block of code (e.g. a whole function or loop), the DIFF_LINE number of the block's first line; "line_number" is then its last line. Both must be in the same hunk
+  - "suggestion" (optional): when the fix is a small, self-contained change to added or context lines, the exact replacement code. It replaces the lines from "suggestion_start_line" to "suggestion_end_line" (DIFF_LINE numbers, both default to "line_number"), so it must keep the original indentation and leave the file compilable. Do not include diff markers, DIFF_LINE annotations or code fences
+  - "suggestion_start_line" / "suggestion_end_line" (optional): the DIFF_LINE range replaced by "suggestion"; it must include "line_number"
+- "comments": an array of general feedback strings that do not belong to a specific line
+
   Comment Structure Guidelines:
   - Start with a clear problem statement
   - Provide specific improvement suggestion
@@ -183,7 +237,7 @@ Please format your response as follows:
   - MEDIUM: Code quality issues, maintainability concerns, minor bugs
   - LOW: Style improvements, documentation suggestions, minor optimizations
   
-  Example: COMMENT:src/main.go:3:new:MEDIUM:Consider using a more specific variable name and declaring it as const for better readability and immutability. Suggestion: "const maxRetryCount = 5" instead of "myVar = 5". This makes the purpose clear and prevents accidental modification.
+  Example: {"summary": "...", "positioned_comments": [{"file_path": "src/main.go", "line_number": 3, "line_type": "new", "severity": "MEDIUM", "rule": "naming", "comment": "Consider using a more specific variable name and declaring it as const for better readability and immutability. Suggestion: \"const maxRetryCount = 5\" instead of \"myVar = 5\". This makes the purpose clear and prevents accidental modification."}], "comments": []}
 
 CRITICAL: 
 - Only use DIFF_LINE numbers from the brackets in the diff
@@ -196,11 +250,10 @@ Here are the changes to review:
 
 %s
 
-Focus on providing constructive, actionable feedback that helps improve code quality, security, and maintainability.`, guidance, codeContent.String())
-	} else {
-		logrus.WithField("project_id", projectID).Info("Using default review guidance")
+Focus on providing constructive, actionable feedback that helps improve code quality, security, and maintainability.`, guidance, content)
+	}
 
-		prompt = fmt.Sprintf(`You are an expert code reviewer with deep knowledge of software engineering best practices. Analyze the following merge request changes and provide comprehensive, actionable feedback.
+	return fmt.Sprintf(`You are an expert code reviewer with deep knowledge of software engineering best practices. Analyze the following merge request changes and provide comprehensive, actionable feedback.
 
 REVIEW OBJECTIVES:
 1. Identify code quality improvements and refactoring opportunities
@@ -217,18 +270,20 @@ When providing feedback, be solution-oriented and include specific improvement s
 - For security issues: Recommend secure coding practices and specific fixes
 - For maintainability: Suggest ways to make code more readable, testable, or modular
 
-Please format your response as follows:
-- Start with a summary paragraph highlighting the most important findings and overall assessment
-- Then provide specific comments in this EXACT format:
-  COMMENT:filename.go:diff_line_number:line_type:severity:comment_text
-  
-  Where:
-  - filename.go is the file path (exactly as shown in the diff)
-  - diff_line_number is the DIFF_LINE number shown in brackets (e.g., if you see [DIFF_LINE:5,NEW_LINE:42], use 5)
-  - line_type is either "new" (for lines starting with +), "old" (for lines starting with -), or "context" (for lines starting with space)
-  - severity is one of: LOW, MEDIUM, HIGH, or CRITICAL
-  - comment_text is your detailed feedback with specific suggestions
-  
+Please respond with a single JSON object (no surrounding prose) with these fields:
+- "summary": a summary paragraph highlighting the most important findings and overall assessment
+- "positioned_comments": an array of line-specific findings, each with:
+  - "file_path": the file path (exactly as shown in the "## File:" header)
+  - "line_number": the DIFF_LINE number shown in brackets (e.g., if you see [DIFF_LINE:5,NEW_LINE:42], use 5)
+  - "line_type": "new" (for lines starting with +), "old" (for lines starting with -), or "context" (for lines starting with space)
+  - "severity": one of LOW, MEDIUM, HIGH, or CRITICAL
+  - "rule": a short kebab-case identifier for the kind of issue (e.g. "error-handling", "sql-injection", "naming")
+  - "comment": your detailed feedback with specific suggestions (markdown allowed)
+  - "start_line_number" (optional): when the finding is about a block of code (e.g. a whole function or loop), the DIFF_LINE number of the block's first line; "line_number" is then its last line. Both must be in the same hunk
+  - "suggestion" (optional): when the fix is a small, self-contained change to added or context lines, the exact replacement code. It replaces the lines from "suggestion_start_line" to "suggestion_end_line" (DIFF_LINE numbers, both default to "line_number"), so it must keep the original indentation and leave the file compilable. Do not include diff markers, DIFF_LINE annotations or code fences
+  - "suggestion_start_line" / "suggestion_end_line" (optional): the DIFF_LINE range replaced by "suggestion"; it must include "line_number"
+- "comments": an array of general feedback strings that do not belong to a specific line
+
   Comment Structure Guidelines:
   - Start with a clear problem statement
   - Provide specific improvement suggestion with reasoning
@@ -242,7 +297,7 @@ Please format your response as follows:
   - MEDIUM: Code quality issues, maintainability concerns, minor bugs, suboptimal patterns
   - LOW: Style improvements, documentation suggestions, minor optimizations, naming conventions
   
-  Example: COMMENT:src/main.go:3:new:MEDIUM:Consider using a more descriptive variable name and declaring it as const for better readability and immutability. Suggestion: Replace "myVar = 5" with "const maxRetryCount = 5". This improves code clarity and prevents accidental modification, following Go naming conventions.
+  Example: {"summary": "...", "positioned_comments": [{"file_path": "src/main.go", "line_number": 3, "line_type": "new", "severity": "MEDIUM", "rule": "naming", "comment": "Consider using a more descriptive variable name and declaring it as const for better readability and immutability. Suggestion: Replace \"myVar = 5\" with \"const maxRetryCount = 5\". This improves code clarity and prevents accidental modification, following Go naming conventions."}], "comments": []}
 
 CRITICAL: 
 - Only use DIFF_LINE numbers from the brackets in the diff
@@ -255,137 +310,564 @@ Here are the changes to review:
 
 %s
 
-Focus on providing constructive, actionable feedback that helps developers write better, more secure, and maintainable code.`, codeContent.String())
-	}
+Focus on providing constructive, actionable feedback that helps developers write better, more secure, and maintainable code.`, content)
+}
 
-	logrus.Debug("Sending request to Gemini AI for code review")
-	content := genai.NewContentFromText(prompt, "user")
-	config := &genai.GenerateContentConfig{
-		Temperature: genai.Ptr(float32(0.1)),
-	}
-	resp, err := r.client.Models.GenerateContent(ctx, "gemini-2.5-pro", []*genai.Content{content}, config)
+// generateReview asks the LLM for a JSON review and validates it against the
+// reviewed diffs. Malformed or invalid output is sent back to the model for
+// repair up to maxReviewRepairAttempts times.
+func (r *ReviewService) generateReview(ctx context.Context, prompt string, diffIndex map[string]map[int]models.DiffLine) (*models.CodeReview, error) {
+	logrus.WithField("provider", r.llm.Name()).Debug("Sending request to LLM for code review")
+	reviewText, err := r.llm.Generate(ctx, LLMRequest{
+		Prompt:         prompt,
+		Temperature:    0.1,
+		ResponseSchema: reviewResponseSchema(),
+	})
 	if err != nil {
-		logrus.WithError(err).Error("Failed to generate AI code review")
+		logrus.WithError(err).WithField("provider", r.llm.Name()).Error("Failed to generate AI code review")
 		return nil, fmt.Errorf("failed to generate review: %w", err)
 	}
 
-	if len(resp.Candidates) == 0 {
-		logrus.Error("No response candidates generated by AI")
-		return nil, fmt.Errorf("no response generated")
+	logrus.WithFields(logrus.Fields{
+		"provider":      r.llm.Name(),
+		"review_length": len(reviewText),
+	}).Info("AI code review generated successfully")
+
+	for attempt := 1; ; attempt++ {
+		review, problems, err := r.parseReview(reviewText, diffIndex)
+		if err == nil && len(problems) == 0 {
+			return review, nil
+		}
+
+		if attempt > maxReviewRepairAttempts {
+			if err != nil {
+				logrus.WithError(err).Error("AI review response is still not valid JSON after repair attempts")
+				return nil, fmt.Errorf("failed to parse review: %w", err)
+			}
+			logrus.WithField("problems", problems).Warn("AI review still has invalid comments after repair attempts, dropping them")
+			return review, nil
+		}
+
+		if err != nil {
+			problems = []string{err.Error()}
+		}
+		logrus.WithFields(logrus.Fields{
+			"attempt":  attempt,
+			"problems": problems,
+		}).Warn("AI review response is malformed, requesting repair")
+
+		reviewText, err = r.llm.Generate(ctx, LLMRequest{
+			Prompt:         buildRepairPrompt(prompt, reviewText, problems),
+			Temperature:    0.1,
+			ResponseSchema: reviewResponseSchema(),
+		})
+		if err != nil {
+			logrus.WithError(err).WithField("provider", r.llm.Name()).Error("Failed to repair AI code review")
+			return nil, fmt.Errorf("failed to repair review: %w", err)
+		}
 	}
+}
+
+func buildRepairPrompt(prompt, previous string, problems []string) string {
+	return fmt.Sprintf(`%s
 
-	reviewText := resp.Text()
+Your previous response could not be used because of the following problems:
+- %s
 
-	logrus.WithField("review_length", len(reviewText)).Info("AI code review generated successfully")
-	return r.parseReview(reviewText), nil
+Previous response:
+%s
+
+Return the corrected review as a single JSON object that follows the required format exactly. Do not include any text outside the JSON object.`, prompt, strings.Join(problems, "\n- "), previous)
 }
 
-func (r *ReviewService) parseReview(reviewText string) *models.CodeReview {
+func reviewResponseSchema() *genai.Schema {
+	return &genai.Schema{
+		Type: genai.TypeObject,
+		Properties: map[string]*genai.Schema{
+			"summary": {
+				Type:        genai.TypeString,
+				Description: "Summary paragraph of the most important findings",
+			},
+			"comments": {
+				Type:        genai.TypeArray,
+				Description: "General comments that are not tied to a specific line",
+				Items:       &genai.Schema{Type: genai.TypeString},
+			},
+			"positioned_comments": {
+				Type: genai.TypeArray,
+				Items: &genai.Schema{
+					Type: genai.TypeObject,
+					Properties: map[string]*genai.Schema{
+						"file_path":         {Type: genai.TypeString},
+						"line_number":       {Type: genai.TypeInteger, Description: "DIFF_LINE number from the annotated diff"},
+						"line_type":         {Type: genai.TypeString, Enum: []string{"new", "old", "context"}},
+						"severity":          {Type: genai.TypeString, Enum: []string{"LOW", "MEDIUM", "HIGH", "CRITICAL"}},
+						"rule":              {Type: genai.TypeString, Description: "Short kebab-case identifier of the issue kind, e.g. error-handling"},
+						"comment":           {Type: genai.TypeString},
+						"start_line_number": {Type: genai.TypeInteger, Description: "First DIFF_LINE of a multi-line finding, line_number being the last"},
+						"suggestion": {
+							Type:        genai.TypeString,
+							Description: "Optional replacement code for the suggestion_start_line..suggestion_end_line range",
+						},
+						"suggestion_start_line": {Type: genai.TypeInteger, Description: "First DIFF_LINE replaced by the suggestion"},
+						"suggestion_end_line":   {Type: genai.TypeInteger, Description: "Last DIFF_LINE replaced by the suggestion"},
+					},
+					Required:         []string{"file_path", "line_number", "line_type", "severity", "rule", "comment"},
+					PropertyOrdering: []string{"file_path", "line_number", "line_type", "severity", "rule", "comment", "start_line_number", "suggestion", "suggestion_start_line", "suggestion_end_line"},
+				},
+			},
+		},
+		Required:         []string{"summary", "positioned_comments", "comments"},
+		PropertyOrdering: []string{"summary", "positioned_comments", "comments"},
+	}
+}
+
+// parseReview decodes the JSON review. The returned review only contains valid
+// positioned comments; problems describes every comment that was rejected.
+func (r *ReviewService) parseReview(reviewText string, diffIndex map[string]map[int]models.DiffLine) (*models.CodeReview, []string, error) {
 	logrus.Debug("Parsing AI review response")
-	lines := strings.Split(reviewText, "\n")
 
-	var summary strings.Builder
+	var review models.CodeReview
+	if err := json.Unmarshal([]byte(extractJSONObject(reviewText)), &review); err != nil {
+		return nil, nil, fmt.Errorf("response is not valid JSON: %w", err)
+	}
+
+	var problems []string
+	if strings.TrimSpace(review.Summary) == "" {
+		problems = append(problems, `"summary" must not be empty`)
+	}
+
 	var comments []string
-	var positionedComments []models.PositionedComment
+	for _, comment := range review.Comments {
+		if strings.TrimSpace(comment) != "" {
+			comments = append(comments, comment)
+		}
+	}
 
-	inSummary := true
-
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-
-		if strings.HasPrefix(line, "COMMENT:") {
-			inSummary = false
-			comment := strings.TrimPrefix(line, "COMMENT:")
-			comment = strings.TrimSpace(comment)
-
-			if comment != "" {
-				// Try to parse positioned comment format: filename:line:type:severity:comment
-				parts := strings.SplitN(comment, ":", 5)
-				if len(parts) == 5 {
-					filePath := parts[0]
-					lineNumStr := parts[1]
-					lineType := parts[2]
-					severity := parts[3]
-					commentText := parts[4]
-
-					if lineNum, err := strconv.Atoi(lineNumStr); err == nil {
-						// Valid positioned comment
-						positionedComment := models.PositionedComment{
-							FilePath:     filePath,
-							LineNumber:   lineNum,
-							LineType:     lineType,
-							Severity:     severity,
-							Comment:      commentText,
-							OriginalLine: "", // We could enhance this later
-						}
-						positionedComments = append(positionedComments, positionedComment)
-
-						logrus.WithFields(logrus.Fields{
-							"file_path":   filePath,
-							"line_number": lineNum,
-							"line_type":   lineType,
-							"severity":    severity,
-						}).Debug("Parsed positioned comment")
-					} else {
-						// Fallback to general comment
-						comments = append(comments, comment)
-						logrus.WithField("comment", comment).Debug("Failed to parse line number, using general comment")
-					}
-				} else {
-					// Fallback to general comment
-					comments = append(comments, comment)
-					logrus.WithField("comment", comment).Debug("Comment not in positioned format, using general comment")
-				}
-			}
-		} else if inSummary && line != "" {
-			if summary.Len() > 0 {
-				summary.WriteString(" ")
-			}
-			summary.WriteString(line)
+	var positionedComments []models.PositionedComment
+	for i, comment := range review.PositionedComments {
+		if problem := validatePositionedComment(&comment, diffIndex); problem != "" {
+			problems = append(problems, fmt.Sprintf("positioned_comments[%d]: %s", i, problem))
+			continue
 		}
+		positionedComments = append(positionedComments, comment)
+
+		logrus.WithFields(logrus.Fields{
+			"file_path":   comment.FilePath,
+			"line_number": comment.LineNumber,
+			"line_type":   comment.LineType,
+			"severity":    comment.Severity,
+		}).Debug("Parsed positioned comment")
 	}
 
 	logrus.WithFields(logrus.Fields{
-		"summary_length":            len(summary.String()),
+		"summary_length":            len(review.Summary),
 		"general_comments_count":    len(comments),
 		"positioned_comments_count": len(positionedComments),
+		"problems_count":            len(problems),
 	}).Debug("Review parsing completed")
 
 	return &models.CodeReview{
-		Summary:            summary.String(),
+		Summary:            strings.TrimSpace(review.Summary),
 		Comments:           comments,
 		PositionedComments: positionedComments,
+	}, problems, nil
+}
+
+// validatePositionedComment checks a comment against the schema and the
+// annotated diffs, normalising severity and line type in place.
+func validatePositionedComment(comment *models.PositionedComment, diffIndex map[string]map[int]models.DiffLine) string {
+	if strings.TrimSpace(comment.Comment) == "" {
+		return `"comment" must not be empty`
+	}
+
+	comment.Severity = strings.ToUpper(strings.TrimSpace(comment.Severity))
+	switch comment.Severity {
+	case "LOW", "MEDIUM", "HIGH", "CRITICAL":
+	default:
+		return fmt.Sprintf("severity %q must be one of LOW, MEDIUM, HIGH or CRITICAL", comment.Severity)
+	}
+
+	lines, ok := diffIndex[comment.FilePath]
+	if !ok {
+		return fmt.Sprintf("file_path %q is not one of the reviewed files", comment.FilePath)
+	}
+
+	diffLine, ok := lines[comment.LineNumber]
+	if !ok {
+		return fmt.Sprintf("line_number %d is not a DIFF_LINE of %s", comment.LineNumber, comment.FilePath)
+	}
+
+	lineType := "context"
+	switch diffLine.Type {
+	case "+":
+		lineType = "new"
+	case "-":
+		lineType = "old"
 	}
+
+	// The DIFF_LINE number is authoritative, so fix up a mismatching line type
+	if comment.LineType != lineType {
+		logrus.WithFields(logrus.Fields{
+			"file_path":   comment.FilePath,
+			"line_number": comment.LineNumber,
+			"line_type":   comment.LineType,
+			"actual_type": lineType,
+		}).Debug("Correcting line type of positioned comment")
+		comment.LineType = lineType
+	}
+
+	// Anchor content and rule feed the fingerprint used to deduplicate comments
+	comment.OriginalLine = diffLine.Content
+	comment.Rule = normalizeRule(comment.Rule)
+
+	// An unusable range or suggestion does not invalidate the finding itself
+	if comment.StartLineNumber != 0 {
+		if problem := validateLineRange(comment, lines); problem != "" {
+			logrus.WithFields(logrus.Fields{
+				"file_path":   comment.FilePath,
+				"line_number": comment.LineNumber,
+				"problem":     problem,
+			}).Debug("Anchoring multi-line comment to its last line only")
+			comment.StartLineNumber = 0
+		}
+	}
+	if comment.Suggestion != "" {
+		if problem := validateSuggestion(comment, lines); problem != "" {
+			logrus.WithFields(logrus.Fields{
+				"file_path":   comment.FilePath,
+				"line_number": comment.LineNumber,
+				"problem":     problem,
+			}).Debug("Dropping invalid suggestion from positioned comment")
+			comment.Suggestion = ""
+			comment.SuggestionStartLine = 0
+			comment.SuggestionEndLine = 0
+		}
+	}
+	return ""
 }
 
-func (r *ReviewService) shouldExcludePath(filePath string, excludePaths []string) bool {
-	for _, pattern := range excludePaths {
-		// Use filepath.Match for basic glob pattern matching
-		matched, err := filepath.Match(pattern, filePath)
-		if err != nil {
-			logrus.WithError(err).WithFields(logrus.Fields{
-				"file_path": filePath,
-				"pattern":   pattern,
-			}).Warn("Invalid exclude pattern, skipping")
+// validateLineRange checks that a multi-line comment starts before its last
+// line and that the whole range lies within one hunk.
+func validateLineRange(comment *models.PositionedComment, lines map[int]models.DiffLine) string {
+	start := comment.StartLineNumber
+	if start == comment.LineNumber {
+		comment.StartLineNumber = 0
+		return ""
+	}
+	if start > comment.LineNumber {
+		return fmt.Sprintf("start_line_number %d is after line_number %d", start, comment.LineNumber)
+	}
+
+	lastOld, lastNew := 0, 0
+	for n := start; n <= comment.LineNumber; n++ {
+		line, ok := lines[n]
+		if !ok {
+			return fmt.Sprintf("start_line_number %d is not a DIFF_LINE of %s", start, comment.FilePath)
+		}
+		// Within a hunk each side's line numbers continue without gaps
+		if line.OldLineNum != 0 {
+			if lastOld != 0 && line.OldLineNum != lastOld+1 {
+				return fmt.Sprintf("lines %d-%d span more than one hunk", start, comment.LineNumber)
+			}
+			lastOld = line.OldLineNum
+		}
+		if line.NewLineNum != 0 {
+			if lastNew != 0 && line.NewLineNum != lastNew+1 {
+				return fmt.Sprintf("lines %d-%d span more than one hunk", start, comment.LineNumber)
+			}
+			lastNew = line.NewLineNum
+		}
+	}
+	return ""
+}
+
+// validateSuggestion checks that a suggestion replaces a contiguous range of
+// the new file around the commented line, so GitLab can apply it, and that
+// applying it does not unbalance brackets. It fills in the relative range.
+func validateSuggestion(comment *models.PositionedComment, lines map[int]models.DiffLine) string {
+	if comment.LineType == "old" {
+		return "suggestions can only be attached to added or context lines"
+	}
+	if strings.Contains(comment.Suggestion, "```") || strings.Contains(comment.Suggestion, "[DIFF_LINE:") {
+		return `"suggestion" must be plain replacement code without code fences or DIFF_LINE annotations`
+	}
+
+	start, end := comment.SuggestionStartLine, comment.SuggestionEndLine
+	if start == 0 {
+		start = comment.LineNumber
+	}
+	if end == 0 {
+		end = comment.LineNumber
+	}
+	if start > comment.LineNumber || end < comment.LineNumber {
+		return fmt.Sprintf("suggestion range %d-%d must include line_number %d", start, end, comment.LineNumber)
+	}
+
+	// Removed lines are not part of the new file and are skipped; every other
+	// line must continue the new file's numbering
+	var original []string
+	firstNewLine, lastNewLine := 0, 0
+	for n := start; n <= end; n++ {
+		line, ok := lines[n]
+		if !ok {
+			return fmt.Sprintf("suggestion range %d-%d is not within the diff of %s", start, end, comment.FilePath)
+		}
+		if line.Type == "-" {
 			continue
 		}
-		if matched {
-			return true
+		if lastNewLine != 0 && line.NewLineNum != lastNewLine+1 {
+			return fmt.Sprintf("suggestion range %d-%d spans more than one hunk", start, end)
 		}
+		if firstNewLine == 0 {
+			firstNewLine = line.NewLineNum
+		}
+		lastNewLine = line.NewLineNum
+		original = append(original, line.Content)
+	}
 
-		// Also check if the pattern with ** matches (simple directory prefix matching)
-		if strings.HasSuffix(pattern, "/**") {
-			prefix := strings.TrimSuffix(pattern, "/**")
-			if strings.HasPrefix(filePath, prefix+"/") || filePath == prefix {
-				return true
-			}
+	anchor := lines[comment.LineNumber].NewLineNum
+	replacement := strings.TrimRight(comment.Suggestion, "\n")
+	if replacement == strings.Join(original, "\n") {
+		return "suggestion does not change the code"
+	}
+	if bracketBalance(replacement) != bracketBalance(strings.Join(original, "\n")) {
+		return "suggestion would leave unbalanced brackets in the file"
+	}
+
+	comment.Suggestion = replacement
+	comment.SuggestionStartLine = start
+	comment.SuggestionEndLine = end
+	comment.SuggestionLinesAbove = anchor - firstNewLine
+	comment.SuggestionLinesBelow = lastNewLine - anchor
+	return ""
+}
+
+// bracketBalance returns the net number of open (), [] and {} brackets.
+func bracketBalance(code string) [3]int {
+	var balance [3]int
+	for _, c := range code {
+		switch c {
+		case '(':
+			balance[0]++
+		case ')':
+			balance[0]--
+		case '[':
+			balance[1]++
+		case ']':
+			balance[1]--
+		case '{':
+			balance[2]++
+		case '}':
+			balance[2]--
+		}
+	}
+	return balance
+}
+
+// normalizeRule turns the model supplied rule into a stable kebab-case identifier.
+func normalizeRule(rule string) string {
+	rule = strings.ToLower(strings.TrimSpace(rule))
+	rule = strings.Join(strings.FieldsFunc(rule, func(c rune) bool {
+		return !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9')
+	}), "-")
+	if rule == "" {
+		return "general"
+	}
+	return rule
+}
+
+// extractJSONObject strips markdown code fences or surrounding prose that some
+// models add around the JSON object.
+func extractJSONObject(text string) string {
+	start := strings.Index(text, "{")
+	end := strings.LastIndex(text, "}")
+	if start < 0 || end < start {
+		return text
+	}
+	return text[start : end+1]
+}
+
+// SummarizeChanges asks the LLM for a short prose summary of the merge request changes.
+func (r *ReviewService) SummarizeChanges(ctx context.Context, changes []models.MRChange, title, description string) (string, error) {
+	logrus.WithFields(logrus.Fields{
+		"changes_count": len(changes),
+		"mr_title":      title,
+	}).Info("Summarizing merge request changes")
+
+	var codeContent strings.Builder
+	codeContent.WriteString("## Merge Request Details\n")
+	codeContent.WriteString(fmt.Sprintf("**Title:** %s\n", title))
+	codeContent.WriteString(fmt.Sprintf("**Description:** %s\n\n", description))
+	for _, change := range changes {
+		if change.DeletedFile {
+			codeContent.WriteString(fmt.Sprintf("## File: %s\n(Deleted file)\n\n", change.OldPath))
+			continue
+		}
+		codeContent.WriteString(fmt.Sprintf("## File: %s\n```diff\n%s\n```\n\n", change.NewPath, change.Diff))
+	}
+
+	prompt := fmt.Sprintf(`You are an expert software engineer. Summarize the following merge request for a reviewer who has not seen it yet.
+
+Respond in markdown with:
+- one short paragraph describing the purpose and overall approach of the change
+- a bullet list of the most important changes, grouped by area where it helps
+- a short list of things a reviewer should look at carefully, if any
+
+Do not review the code or suggest improvements, only describe what it does.
+
+%s`, codeContent.String())
+
+	summary, err := r.llm.Generate(ctx, LLMRequest{
+		Prompt:      prompt,
+		Temperature: 0.2,
+	})
+	if err != nil {
+		logrus.WithError(err).WithField("provider", r.llm.Name()).Error("Failed to generate merge request summary")
+		return "", fmt.Errorf("failed to generate summary: %w", err)
+	}
+	return strings.TrimSpace(summary), nil
+}
+
+// ExplainCode asks the LLM to explain the code a diff note is attached to,
+// answering the developer's question when one was given.
+func (r *ReviewService) ExplainCode(ctx context.Context, change models.MRChange, position models.NotePosition, question string) (string, error) {
+	logrus.WithFields(logrus.Fields{
+		"file_path": change.NewPath,
+		"new_line":  position.NewLine,
+		"old_line":  position.OldLine,
+	}).Info("Explaining code on diff thread")
+
+	annotatedDiff, _ := r.addLineNumbersToDiff(change.Diff)
+
+	line := fmt.Sprintf("NEW_LINE:%d (or CONTEXT:%d)", position.NewLine, position.NewLine)
+	if position.NewLine == 0 {
+		line = fmt.Sprintf("OLD_LINE:%d", position.OldLine)
+	}
+	if strings.TrimSpace(question) == "" {
+		question = "What does this code do, and why might it have been written this way?"
+	}
+
+	prompt := fmt.Sprintf(`You are an expert software engineer helping a developer understand a merge request.
+
+The developer is asking about the line annotated with %s in the file %s, shown in the diff below. Use the surrounding lines as context.
+
+Question: %s
+
+Answer concisely in markdown. Explain what the code does and any non-obvious behaviour, edge cases or risks. Only suggest changes if the question asks for them.
+
+## File: %s
+`+"```diff\n%s\n```", line, change.NewPath, question, change.NewPath, annotatedDiff)
+
+	explanation, err := r.llm.Generate(ctx, LLMRequest{
+		Prompt:      prompt,
+		Temperature: 0.2,
+	})
+	if err != nil {
+		logrus.WithError(err).WithField("provider", r.llm.Name()).Error("Failed to generate code explanation")
+		return "", fmt.Errorf("failed to generate explanation: %w", err)
+	}
+	return strings.TrimSpace(explanation), nil
+}
+
+// AnswerFollowUp asks the LLM to respond to the replies on one of its
+// findings, given the diff hunk the finding is anchored to.
+func (r *ReviewService) AnswerFollowUp(ctx context.Context, thread *models.BotThread, hunk string) (*models.FollowUpAnswer, error) {
+	logrus.WithFields(logrus.Fields{
+		"discussion_id": thread.Discussion.DiscussionID,
+		"file_path":     thread.Discussion.FilePath,
+		"notes_count":   len(thread.Notes),
+	}).Info("Answering follow-up on review thread")
+
+	var conversation strings.Builder
+	for i, note := range thread.Notes {
+		author := "@" + note.Author
+		switch {
+		case i == 0:
+			author = "You (original finding)"
+		case note.FromBot:
+			author = "You"
 		}
+		conversation.WriteString(fmt.Sprintf("### %s\n%s\n\n", author, note.Body))
 	}
-	return false
+
+	code := "(The finding is not attached to a diff line.)"
+	if hunk != "" {
+		code = fmt.Sprintf("File: %s\n```diff\n%s\n```", thread.Discussion.FilePath, hunk)
+	}
+
+	prompt := fmt.Sprintf(`You are an expert code reviewer. Earlier you left a review finding on a merge request and developers have replied to it. Continue the conversation.
+
+CODE THE FINDING IS ATTACHED TO:
+%s
+
+CONVERSATION:
+%s
+Respond to the latest reply. Be concise, specific and collegial:
+- If the developer's argument is correct, the finding is a false positive, or the code now addresses it, concede briefly and set "resolve" to true
+- If the finding still stands, explain why with reference to the code and suggest a concrete fix, and set "resolve" to false
+- If the developer asks a question, answer it
+
+Please respond with a single JSON object (no surrounding prose) with these fields:
+- "reply": your markdown reply to post in the thread
+- "resolve": true if the thread should be resolved, false otherwise`, code, conversation.String())
+
+	text, err := r.llm.Generate(ctx, LLMRequest{
+		Prompt:         prompt,
+		Temperature:    0.2,
+		ResponseSchema: followUpResponseSchema(),
+	})
+	if err != nil {
+		logrus.WithError(err).WithField("provider", r.llm.Name()).Error("Failed to generate follow-up answer")
+		return nil, fmt.Errorf("failed to generate follow-up answer: %w", err)
+	}
+
+	var answer models.FollowUpAnswer
+	if err := json.Unmarshal([]byte(extractJSONObject(text)), &answer); err != nil {
+		return nil, fmt.Errorf("failed to parse follow-up answer: %w", err)
+	}
+	answer.Reply = strings.TrimSpace(answer.Reply)
+	if answer.Reply == "" {
+		return nil, fmt.Errorf("follow-up answer has an empty reply")
+	}
+	return &answer, nil
 }
 
+func followUpResponseSchema() *genai.Schema {
+	return &genai.Schema{
+		Type: genai.TypeObject,
+		Properties: map[string]*genai.Schema{
+			"reply":   {Type: genai.TypeString, Description: "Markdown reply to post in the thread"},
+			"resolve": {Type: genai.TypeBoolean, Description: "Whether the finding should be resolved"},
+		},
+		Required:         []string{"reply", "resolve"},
+		PropertyOrdering: []string{"reply", "resolve"},
+	}
+}
+
+// DiffHunk returns the hunk of a diff that contains the given new line, or
+// the given old line when newLine is 0.
+func DiffHunk(rawDiff string, newLine, oldLine int) string {
+	parsed := diff.Parse(rawDiff)
+
+	line, ok := parsed.LineAtNew(newLine)
+	if newLine == 0 {
+		line, ok = parsed.LineAtOld(oldLine)
+	}
+	if !ok {
+		return ""
+	}
+	hunk, ok := parsed.HunkAt(line.Position)
+	if !ok {
+		return ""
+	}
+	return hunk.String()
+}
+
+// filterExcludedChanges drops the files the WhyTho config does not review,
+// returning the remaining changes and the excluded paths.
 func (r *ReviewService) filterExcludedChanges(changes []models.MRChange, config *models.WhyThoConfig) ([]models.MRChange, []string) {
-	if config == nil || len(config.ExcludePaths) == 0 {
+	if config == nil {
 		return changes, []string{}
 	}
 
@@ -398,10 +880,11 @@ func (r *ReviewService) filterExcludedChanges(changes []models.MRChange, config
 			filePath = change.OldPath // For deleted files
 		}
 
-		if r.shouldExcludePath(filePath, config.ExcludePaths) {
+		if resolvePathSettings(config, filePath).Excluded {
 			excludedFiles = append(excludedFiles, filePath)
 			logrus.WithFields(logrus.Fields{
 				"file_path":     filePath,
+				"include_paths": config.IncludePaths,
 				"exclude_paths": config.ExcludePaths,
 			}).Debug("Excluding file from review based on WhyTho config")
 		} else {
@@ -412,53 +895,31 @@ func (r *ReviewService) filterExcludedChanges(changes []models.MRChange, config
 	return filteredChanges, excludedFiles
 }
 
-func (r *ReviewService) addLineNumbersToDiff(diff string) string {
-	lines := strings.Split(diff, "\n")
+// addLineNumbersToDiff annotates every diff line with its DIFF_LINE number and
+// file line number for the model to reference.
+func (r *ReviewService) addLineNumbersToDiff(rawDiff string) (string, map[int]models.DiffLine) {
+	parsed := diff.Parse(rawDiff)
+
 	var result strings.Builder
-	diffLineNum := 0
-	oldLineNum := 0
-	newLineNum := 0
-
-	for _, line := range lines {
-		if strings.HasPrefix(line, "@@") {
-			// Parse hunk header to get starting line numbers
-			parts := strings.Split(line, " ")
-			if len(parts) >= 3 {
-				oldPart := strings.TrimPrefix(parts[1], "-")
-				newPart := strings.TrimPrefix(parts[2], "+")
-
-				if oldComma := strings.Index(oldPart, ","); oldComma > 0 {
-					oldPart = oldPart[:oldComma]
-				}
-				if newComma := strings.Index(newPart, ","); newComma > 0 {
-					newPart = newPart[:newComma]
-				}
-
-				if oldStart, err := strconv.Atoi(oldPart); err == nil {
-					oldLineNum = oldStart - 1
-				}
-				if newStart, err := strconv.Atoi(newPart); err == nil {
-					newLineNum = newStart - 1
-				}
+	if parsed.Binary {
+		result.WriteString("(Binary file, no textual diff)\n")
+	}
+	for _, hunk := range parsed.Hunks {
+		result.WriteString(hunk.Header() + "\n")
+		for _, line := range hunk.Lines {
+			switch line.Type {
+			case diff.LineAdded:
+				result.WriteString(fmt.Sprintf("+%s [DIFF_LINE:%d,NEW_LINE:%d]\n", line.Content, line.Position, line.NewLineNum))
+			case diff.LineRemoved:
+				result.WriteString(fmt.Sprintf("-%s [DIFF_LINE:%d,OLD_LINE:%d]\n", line.Content, line.Position, line.OldLineNum))
+			default:
+				result.WriteString(fmt.Sprintf(" %s [DIFF_LINE:%d,CONTEXT:%d]\n", line.Content, line.Position, line.NewLineNum))
+			}
+			if line.NoNewline {
+				result.WriteString("\\ No newline at end of file\n")
 			}
-			result.WriteString(line + "\n")
-		} else if strings.HasPrefix(line, "+") {
-			newLineNum++
-			diffLineNum++
-			result.WriteString(fmt.Sprintf("%s [DIFF_LINE:%d,NEW_LINE:%d]\n", line, diffLineNum, newLineNum))
-		} else if strings.HasPrefix(line, "-") {
-			oldLineNum++
-			diffLineNum++
-			result.WriteString(fmt.Sprintf("%s [DIFF_LINE:%d,OLD_LINE:%d]\n", line, diffLineNum, oldLineNum))
-		} else if strings.HasPrefix(line, " ") {
-			oldLineNum++
-			newLineNum++
-			diffLineNum++
-			result.WriteString(fmt.Sprintf("%s [DIFF_LINE:%d,CONTEXT:%d]\n", line, diffLineNum, newLineNum))
-		} else {
-			result.WriteString(line + "\n")
 		}
 	}
 
-	return result.String()
+	return result.String(), parsed.Lines()
 }
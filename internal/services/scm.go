@@ -0,0 +1,174 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/vinamra28/whytho/internal/diff"
+	"github.com/vinamra28/whytho/internal/models"
+)
+
+// ErrFileNotFound is returned by SCMProvider.GetFile when the file does not
+// exist at the requested ref.
+var ErrFileNotFound = errors.New("file not found")
+
+// SCMProvider is a source code host whose pull requests can be reviewed.
+// Repositories are identified by repo, the host's project ID or
+// "owner/name" path, and pull requests by their number within it.
+type SCMProvider interface {
+	// Name identifies the provider in logs and job payloads, e.g. "github"
+	Name() string
+	GetPullRequest(repo string, number int) (*models.PullRequest, error)
+	GetChanges(repo string, number int) ([]models.MRChange, error)
+	// GetFile returns the content of a file at a ref, or ErrFileNotFound. An
+	// empty ref reads the default branch
+	GetFile(repo, path, ref string) (string, error)
+	// PostInlineComment posts a finding on its diff line, falling back to a
+	// general comment when the line cannot be addressed
+	PostInlineComment(repo string, number int, comment models.PositionedComment) error
+	PostGeneralComment(repo string, number int, body string) error
+	// ListComments returns the general and inline comments of the pull request
+	ListComments(repo string, number int) ([]models.SCMComment, error)
+	// UpdateComment edits a general comment
+	UpdateComment(repo string, number int, commentID int64, body string) error
+	// SetStatus sets the "whytho/review" commit status, state being one of the ReviewStatus constants
+	SetStatus(repo, sha, state, description, targetURL string) error
+	// BotUsername returns the username of the account the bot posts as
+	BotUsername() (string, error)
+}
+
+// GroupedProvider is implemented by providers that know which groups a
+// repository belongs to. Providers that do not implement it address
+// repositories by "group/name" paths.
+type GroupedProvider interface {
+	// RepoGroups returns the full paths of the repository's groups, outermost
+	// first
+	RepoGroups(repo string) ([]string, error)
+}
+
+// repoGroups returns the groups of a repository, outermost first.
+func repoGroups(provider SCMProvider, repo string) ([]string, error) {
+	if grouped, ok := provider.(GroupedProvider); ok {
+		return grouped.RepoGroups(repo)
+	}
+	return pathGroups(path.Dir(repo)), nil
+}
+
+// pathGroups expands "org/team" to its nested groups "org" and "org/team".
+func pathGroups(fullPath string) []string {
+	if fullPath == "" || fullPath == "." || fullPath == "/" {
+		return nil
+	}
+	var groups []string
+	segments := strings.Split(fullPath, "/")
+	for i := range segments {
+		groups = append(groups, strings.Join(segments[:i+1], "/"))
+	}
+	return groups
+}
+
+// ReviewTarget identifies the pull request under review and the provider its
+// .whytho/ configuration is read from.
+type ReviewTarget struct {
+	Provider     SCMProvider
+	Repo         string
+	Number       int
+	TargetBranch string
+	// HeadRef is the pull request's head commit, which a .whytho/config.yaml
+	// modified by the pull request is read from
+	HeadRef string
+}
+
+// ResolveDiffPosition fills in the file paths and old/new line numbers of the
+// DIFF_LINE a comment (and, for multi-line comments, its range start) refers to.
+func ResolveDiffPosition(changes []models.MRChange, positionedComment models.PositionedComment) (models.PositionedComment, error) {
+	for _, change := range changes {
+		if change.NewPath != positionedComment.FilePath && change.OldPath != positionedComment.FilePath {
+			continue
+		}
+
+		parsed := diff.Parse(change.Diff)
+		line, ok := parsed.Line(positionedComment.LineNumber)
+		if !ok || line.Type != diffLineType(positionedComment.LineType) {
+			return positionedComment, fmt.Errorf("could not find %s line for diff line %d", positionedComment.LineType, positionedComment.LineNumber)
+		}
+
+		resolved := positionedComment
+		resolved.FilePath = change.NewPath
+		resolved.OldPath = change.OldPath
+		resolved.OldLineNumber = line.OldLineNum
+		resolved.NewLineNumber = line.NewLineNum
+		resolved.LineCode, _ = parsed.LineCode(change.NewPath, positionedComment.LineNumber)
+
+		if start := positionedComment.StartLineNumber; start != 0 && start < positionedComment.LineNumber {
+			startLine, ok := parsed.Line(start)
+			startCode, hasCode := parsed.LineCode(change.NewPath, start)
+			if ok && hasCode {
+				resolved.StartLineType = startLine.Type
+				resolved.StartOldLineNumber = startLine.OldLineNum
+				resolved.StartNewLineNumber = startLine.NewLineNum
+				resolved.StartLineCode = startCode
+			}
+		}
+		return resolved, nil
+	}
+
+	return positionedComment, fmt.Errorf("file %s not found in merge request changes", positionedComment.FilePath)
+}
+
+// BotComments scans the comments posted by botUsername for the bot's markers.
+// It returns the fingerprints of the findings and comments already posted and
+// the ID of the summary comment, 0 when there is none. Markers copied into
+// other users' comments are ignored.
+func BotComments(comments []models.SCMComment, botUsername string) (map[string]bool, int64) {
+	posted := make(map[string]bool)
+	var summaryID int64
+	for _, comment := range comments {
+		kind, attributes, ok := parseBotComment(comment, botUsername)
+		if !ok {
+			continue
+		}
+		switch kind {
+		case BotNoteKindSummary:
+			summaryID = comment.ID
+		case BotNoteKindFinding, BotNoteKindComment:
+			posted[attributes["fingerprint"]] = true
+		}
+	}
+	return posted, summaryID
+}
+
+// parseBotComment parses the marker of a comment posted by botUsername.
+func parseBotComment(comment models.SCMComment, botUsername string) (string, map[string]string, bool) {
+	if botUsername == "" || !strings.EqualFold(comment.Author, botUsername) {
+		return "", nil, false
+	}
+	return parseBotMarker(comment.Body)
+}
+
+// GeneralCommentBody tags a general review comment with its fingerprint.
+func GeneralCommentBody(comment string) string {
+	return fmt.Sprintf("%s\n\n%s", comment, commentMarker(comment))
+}
+
+// SummaryCommentBody tags the review summary so it is edited in place on the
+// next review, recording the head commit it was written for.
+func SummaryCommentBody(summary, headSHA string) string {
+	return fmt.Sprintf("%s\n\n%s", summary, summaryMarker(headSHA))
+}
+
+// InlineCommentBody renders a finding posted on its diff line, suggestion
+// renders the replacement code in the host's syntax.
+func InlineCommentBody(comment models.PositionedComment, suggestion string) string {
+	return fmt.Sprintf("%s\n\n%s%s\n\n%s", formatSeverity(comment.Severity), comment.Comment, suggestion, findingMarker(comment))
+}
+
+// UnpositionedFindingBody renders a finding that could not be anchored to the
+// diff as a general comment naming its file and line.
+func UnpositionedFindingBody(comment models.PositionedComment) string {
+	return fmt.Sprintf("**File: %s (Line %d)** - %s\n\n%s%s\n\n%s",
+		comment.FilePath, comment.LineNumber, formatSeverity(comment.Severity), comment.Comment,
+		formatSuggestion(comment, false), findingMarker(comment))
+}
@@ -0,0 +1,217 @@
+package services
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/vinamra28/whytho/internal/models"
+	"gopkg.in/yaml.v3"
+)
+
+// WhyThoConfigSchema is the JSON Schema of .whytho/config.yaml, for editors
+// and CI. ValidateWhyThoConfig checks the same rules.
+//
+//go:embed whytho.schema.json
+var WhyThoConfigSchema []byte
+
+// yamlTypeNames drops the Go type from yaml's unknown field errors
+var yamlTypeNames = regexp.MustCompile(` in type [\w.]+`)
+
+// decodeWhyThoConfig decodes .whytho/config.yaml, rejecting unknown fields so
+// that typos are reported instead of silently ignored.
+func decodeWhyThoConfig(content string) (*models.WhyThoConfig, error) {
+	var config models.WhyThoConfig
+	decoder := yaml.NewDecoder(strings.NewReader(content))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&config); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// ValidateWhyThoConfig checks the content of a .whytho/config.yaml, returning
+// a description of every problem found.
+func ValidateWhyThoConfig(content string) []string {
+	_, problems := validateWhyThoConfig(content)
+	return problems
+}
+
+// validateWhyThoConfig decodes and checks a config. The config is nil when it
+// cannot be decoded.
+func validateWhyThoConfig(content string) (*models.WhyThoConfig, []string) {
+	config, err := decodeWhyThoConfig(content)
+	if err != nil {
+		var typeErr *yaml.TypeError
+		if errors.As(err, &typeErr) {
+			problems := make([]string, 0, len(typeErr.Errors))
+			for _, message := range typeErr.Errors {
+				problems = append(problems, yamlTypeNames.ReplaceAllString(message, ""))
+			}
+			return nil, problems
+		}
+		return nil, []string{strings.TrimPrefix(err.Error(), "yaml: ")}
+	}
+
+	var problems []string
+	checkPatterns := func(field string, patterns []string) {
+		for i, pattern := range patterns {
+			if err := ValidatePathPattern(pattern); err != nil {
+				problems = append(problems, fmt.Sprintf("%s[%d]: %v", field, i, err))
+			}
+		}
+	}
+	checkSeverity := func(field, severity string) {
+		if severityRank(severity) == 0 {
+			problems = append(problems, fmt.Sprintf("%s: unknown severity %q, expected LOW, MEDIUM, HIGH or CRITICAL", field, severity))
+		}
+	}
+	checkSeverities := func(field string, severities []string) {
+		for i, severity := range severities {
+			checkSeverity(fmt.Sprintf("%s[%d]", field, i), severity)
+		}
+	}
+
+	checkPatterns("includePaths", config.IncludePaths)
+	checkPatterns("excludePaths", config.ExcludePaths)
+	for i, override := range config.Overrides {
+		field := fmt.Sprintf("overrides[%d]", i)
+		if len(override.Paths) == 0 {
+			problems = append(problems, field+": paths is required")
+		}
+		checkPatterns(field+".paths", override.Paths)
+		if override.MinSeverity != "" {
+			checkSeverity(field+".minSeverity", override.MinSeverity)
+		}
+	}
+	for _, pattern := range sortedGuidancePatterns(config) {
+		field := fmt.Sprintf("guidance[%q]", pattern)
+		if err := ValidatePathPattern(pattern); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", field, err))
+		}
+		if len(config.Guidance[pattern]) == 0 {
+			problems = append(problems, field+": no guidance files given")
+		}
+		for i, guidancePath := range config.Guidance[pattern] {
+			if strings.TrimSpace(guidancePath) == "" {
+				problems = append(problems, fmt.Sprintf("%s[%d]: empty guidance file path", field, i))
+			}
+		}
+	}
+	if config.Gating != nil {
+		checkSeverities("gating.block", config.Gating.Block)
+		checkSeverities("gating.requireResolution", config.Gating.RequireResolution)
+	}
+	return config, problems
+}
+
+// ValidateWhyThoFiles validates the .whytho/config.yaml at ref and checks
+// that the guidance files it maps exist there. A repository without a config
+// is valid.
+func ValidateWhyThoFiles(provider SCMProvider, repo, ref string) ([]string, error) {
+	content, err := provider.GetFile(repo, whyThoConfigPath, ref)
+	if errors.Is(err, ErrFileNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", whyThoConfigPath, err)
+	}
+
+	config, problems := validateWhyThoConfig(content)
+	if config == nil {
+		return problems, nil
+	}
+	checked := make(map[string]bool)
+	for _, pattern := range sortedGuidancePatterns(config) {
+		for _, guidancePath := range config.Guidance[pattern] {
+			if guidancePath == "" || checked[guidancePath] {
+				continue
+			}
+			checked[guidancePath] = true
+			if _, err := provider.GetFile(repo, guidancePath, ref); errors.Is(err, ErrFileNotFound) {
+				problems = append(problems, fmt.Sprintf("guidance[%q]: guidance file %s does not exist", pattern, guidancePath))
+			} else if err != nil {
+				return nil, fmt.Errorf("failed to fetch %s: %w", guidancePath, err)
+			}
+		}
+	}
+	return problems, nil
+}
+
+// ValidateConfigChanges validates the .whytho/ files at the pull request's
+// head when the pull request modifies any of them, returning the problems
+// found. Nothing is checked when the head cannot be read.
+func ValidateConfigChanges(target ReviewTarget, changes []models.MRChange) []string {
+	if target.HeadRef == "" || !modifiesWhyThoFiles(changes) {
+		return nil
+	}
+	problems, err := ValidateWhyThoFiles(target.Provider, target.Repo, target.HeadRef)
+	if err != nil {
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"project_id": target.Repo,
+			"mr_iid":     target.Number,
+		}).Warn("Failed to validate modified WhyTho config")
+		return nil
+	}
+	if len(problems) > 0 {
+		logrus.WithFields(logrus.Fields{
+			"project_id": target.Repo,
+			"mr_iid":     target.Number,
+			"problems":   problems,
+		}).Info("Modified WhyTho config is invalid")
+	}
+	return problems
+}
+
+func modifiesWhyThoFiles(changes []models.MRChange) bool {
+	for _, change := range changes {
+		if strings.HasPrefix(change.NewPath, ".whytho/") || strings.HasPrefix(change.OldPath, ".whytho/") {
+			return true
+		}
+	}
+	return false
+}
+
+// ConfigCheckBody renders the note reporting the problems of the modified
+// .whytho/ files, or that they are valid again, and returns it with its
+// fingerprint so an unchanged note is not edited.
+func ConfigCheckBody(problems []string) (string, string) {
+	var text strings.Builder
+	if len(problems) == 0 {
+		text.WriteString("✅ **WhyTho configuration is valid.** The problems reported earlier have been fixed.")
+	} else {
+		text.WriteString("⚠️ **Invalid WhyTho configuration**\n\n")
+		text.WriteString("The `.whytho/` files changed in this merge request have problems. Until they are fixed, the configuration may not apply as intended:\n\n")
+		for _, problem := range problems {
+			text.WriteString(fmt.Sprintf("- %s\n", problem))
+		}
+		text.WriteString("\nRun `whytho config lint` locally to check the configuration before pushing.")
+	}
+	fingerprint := CommentFingerprint(text.String())
+	return fmt.Sprintf("%s\n\n%s", text.String(), configMarker(fingerprint)), fingerprint
+}
+
+// ConfigNote returns the ID and fingerprint of the configuration note posted
+// by botUsername among the comments, 0 when there is none.
+func ConfigNote(comments []models.SCMComment, botUsername string) (int64, string) {
+	for _, comment := range comments {
+		if kind, attributes, ok := parseBotComment(comment, botUsername); ok && kind == BotNoteKindConfig {
+			return comment.ID, attributes["fingerprint"]
+		}
+	}
+	return 0, ""
+}
+
+func sortedGuidancePatterns(config *models.WhyThoConfig) []string {
+	patterns := make([]string, 0, len(config.Guidance))
+	for pattern := range config.Guidance {
+		patterns = append(patterns, pattern)
+	}
+	sort.Strings(patterns)
+	return patterns
+}
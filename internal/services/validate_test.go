@@ -0,0 +1,236 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidateWhyThoConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		// want lists a substring of every expected problem, none for valid configs
+		want []string
+	}{
+		{
+			name:    "empty",
+			content: "",
+		},
+		{
+			name:    "comments only",
+			content: "# nothing configured yet\n",
+		},
+		{
+			name: "every field",
+			content: `includePaths:
+  - "src/**"
+excludePaths:
+  - "*.pb.go"
+  - "!keep.pb.go"
+overrides:
+  - paths: ["legacy/"]
+    exclude: false
+    minSeverity: high
+    suggestions: false
+guidance:
+  "*.sql": .whytho/sql.md
+  "{api,web}/**":
+    - .whytho/frontend.md
+    - .whytho/security.md
+gating:
+  block: [CRITICAL]
+  requireResolution: [high, MEDIUM]
+  approve: true
+`,
+		},
+		{
+			name:    "unknown top-level field",
+			content: "excludePath:\n  - vendor/\n",
+			want:    []string{"line 1: field excludePath not found"},
+		},
+		{
+			name:    "unknown nested field",
+			content: "overrides:\n  - paths: [docs/]\n    minSeverty: LOW\n",
+			want:    []string{"line 3: field minSeverty not found"},
+		},
+		{
+			name:    "unknown gating field",
+			content: "gating:\n  blocks: [CRITICAL]\n",
+			want:    []string{"field blocks not found"},
+		},
+		{
+			name:    "wrong type",
+			content: "excludePaths: vendor/\n",
+			want:    []string{"line 1: cannot unmarshal !!str `vendor/` into []string"},
+		},
+		{
+			name:    "invalid YAML",
+			content: "excludePaths: [vendor/\n",
+			want:    []string{"did not find expected"},
+		},
+		{
+			name:    "invalid patterns",
+			content: "includePaths: [\"src/[a\"]\nexcludePaths: [\"*.{go\", \"\"]\n",
+			want: []string{
+				"includePaths[0]: invalid path pattern",
+				"excludePaths[0]: invalid path pattern \"*.{go\": unmatched {",
+				"excludePaths[1]: empty path pattern",
+			},
+		},
+		{
+			name:    "override without paths",
+			content: "overrides:\n  - suggestions: false\n",
+			want:    []string{"overrides[0]: paths is required"},
+		},
+		{
+			name:    "unknown severities",
+			content: "overrides:\n  - paths: [a/]\n    minSeverity: HGH\ngating:\n  block: [BLOCKER]\n  requireResolution: [high, urgent]\n",
+			want: []string{
+				`overrides[0].minSeverity: unknown severity "HGH"`,
+				`gating.block[0]: unknown severity "BLOCKER"`,
+				`gating.requireResolution[1]: unknown severity "urgent"`,
+			},
+		},
+		{
+			name:    "empty guidance",
+			content: "guidance:\n  \"*.go\": []\n  \"*.sql\": \"\"\n  \"*.{md\": .whytho/docs.md\n",
+			want: []string{
+				`guidance["*.go"]: no guidance files given`,
+				`guidance["*.sql"][0]: empty guidance file path`,
+				`guidance["*.{md"]: invalid path pattern`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			problems := ValidateWhyThoConfig(tt.content)
+			if len(problems) != len(tt.want) {
+				t.Fatalf("ValidateWhyThoConfig() = %q, want %d problem(s)", problems, len(tt.want))
+			}
+			for i, want := range tt.want {
+				if !strings.Contains(problems[i], want) {
+					t.Errorf("problem %d = %q, want it to contain %q", i, problems[i], want)
+				}
+			}
+			for _, problem := range problems {
+				if strings.Contains(problem, "models.") {
+					t.Errorf("problem %q leaks a Go type name", problem)
+				}
+			}
+		})
+	}
+}
+
+func TestParseWhyThoConfig(t *testing.T) {
+	tests := []struct {
+		name         string
+		content      string
+		wantExcludes []string
+		wantErr      bool
+	}{
+		{
+			name:         "valid",
+			content:      "excludePaths: [vendor/]\n",
+			wantExcludes: []string{"vendor/"},
+		},
+		{
+			// Unknown fields are only reported by validation, so configs that
+			// loaded before strict validation keep excluding their files
+			name:         "unknown fields are ignored",
+			content:      "excludePaths: [vendor/]\nreviewers: [alice]\n",
+			wantExcludes: []string{"vendor/"},
+		},
+		{
+			name:    "wrong type",
+			content: "excludePaths: vendor/\n",
+			wantErr: true,
+		},
+		{
+			name:    "invalid YAML",
+			content: "excludePaths: [vendor/\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := parseWhyThoConfig(tt.content)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseWhyThoConfig() error = %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(config.ExcludePaths, tt.wantExcludes) {
+				t.Errorf("excludePaths = %q, want %q", config.ExcludePaths, tt.wantExcludes)
+			}
+		})
+	}
+}
+
+func TestValidateWhyThoFiles(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  []string
+	}{
+		{
+			name: "no config",
+		},
+		{
+			name: "guidance files exist",
+			files: map[string]string{
+				".whytho/config.yaml": "guidance:\n  \"*.sql\": .whytho/sql.md\n",
+				".whytho/sql.md":      "Check indexes.",
+			},
+		},
+		{
+			name: "missing guidance file",
+			files: map[string]string{
+				".whytho/config.yaml": "guidance:\n  \"*.sql\": [.whytho/sql.md, .whytho/missing.md]\n  \"*.go\": .whytho/missing.md\n",
+				".whytho/sql.md":      "Check indexes.",
+			},
+			want: []string{`guidance["*.go"]: guidance file .whytho/missing.md does not exist`},
+		},
+		{
+			name: "invalid config",
+			files: map[string]string{
+				".whytho/config.yaml": "guidance: [.whytho/sql.md]\n",
+			},
+			want: []string{"cannot unmarshal"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range tt.files {
+				writeTestFile(t, filepath.Join(dir, name), content)
+			}
+
+			problems, err := ValidateWhyThoFiles(NewLocalRepository(dir, ""), dir, "")
+			if err != nil {
+				t.Fatalf("ValidateWhyThoFiles() error = %v", err)
+			}
+			if len(problems) != len(tt.want) {
+				t.Fatalf("ValidateWhyThoFiles() = %q, want %d problem(s)", problems, len(tt.want))
+			}
+			for i, want := range tt.want {
+				if !strings.Contains(problems[i], want) {
+					t.Errorf("problem %d = %q, want it to contain %q", i, problems[i], want)
+				}
+			}
+		})
+	}
+}
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
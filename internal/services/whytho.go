@@ -0,0 +1,197 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/vinamra28/whytho/internal/diff"
+	"github.com/vinamra28/whytho/internal/models"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	whyThoConfigPath   = ".whytho/config.yaml"
+	whyThoGuidancePath = ".whytho/guidance.md"
+)
+
+// LoadReviewGuidance reads .whytho/guidance.md from the branch, returning ""
+// when the repository has none.
+func LoadReviewGuidance(provider SCMProvider, repo, branch string) (string, error) {
+	return LoadGuidanceFile(provider, repo, branch, whyThoGuidancePath)
+}
+
+// LoadGuidanceFile reads a guidance file from the branch, returning "" when
+// the repository has none.
+func LoadGuidanceFile(provider SCMProvider, repo, branch, guidancePath string) (string, error) {
+	logrus.WithFields(logrus.Fields{
+		"project_id":    repo,
+		"branch":        branch,
+		"guidance_path": guidancePath,
+	}).Debug("Fetching review guidance from repository")
+
+	content, err := provider.GetFile(repo, guidancePath, branch)
+	if err != nil {
+		if errors.Is(err, ErrFileNotFound) {
+			logrus.WithFields(logrus.Fields{
+				"project_id":    repo,
+				"branch":        branch,
+				"guidance_path": guidancePath,
+			}).Debug("Guidance file not found in repository")
+			return "", nil // Return empty string, not an error
+		}
+
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"project_id":    repo,
+			"branch":        branch,
+			"guidance_path": guidancePath,
+		}).Error("Failed to fetch guidance file from repository")
+		return "", fmt.Errorf("failed to fetch %s: %w", guidancePath, err)
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"project_id":      repo,
+		"branch":          branch,
+		"guidance_path":   guidancePath,
+		"guidance_length": len(content),
+	}).Info("Successfully fetched review guidance from repository")
+
+	return content, nil
+}
+
+// LoadWhyThoConfig reads .whytho/config.yaml, preferring the version modified
+// by the pull request's changes over the one on the target branch. The
+// modified version is read from headRef, or rebuilt from the diff when the
+// head cannot be read; both are parsed the same way as the branch version.
+func LoadWhyThoConfig(provider SCMProvider, repo string, number int, targetBranch, headRef string, changes []models.MRChange) (*models.WhyThoConfig, error) {
+	logrus.WithFields(logrus.Fields{
+		"project_id": repo,
+		"mr_iid":     number,
+		"branch":     targetBranch,
+	}).Debug("Fetching WhyTho config")
+
+	// First, check if .whytho/config.yaml is modified in the MR diff
+	for _, change := range changes {
+		if change.NewPath == whyThoConfigPath && !change.DeletedFile {
+			logrus.WithFields(logrus.Fields{
+				"project_id": repo,
+				"mr_iid":     number,
+			}).Info("WhyTho config found in MR diff, using modified version")
+
+			if headRef != "" {
+				content, err := provider.GetFile(repo, whyThoConfigPath, headRef)
+				if err == nil {
+					config, err := parseWhyThoConfig(content)
+					if err != nil {
+						logrus.WithError(err).WithFields(logrus.Fields{
+							"project_id": repo,
+							"mr_iid":     number,
+						}).Warn("Failed to parse modified WhyTho config, falling back to target branch")
+						break // Fall through to target branch lookup
+					}
+					return config, nil
+				}
+				logrus.WithError(err).WithFields(logrus.Fields{
+					"project_id": repo,
+					"mr_iid":     number,
+					"ref":        headRef,
+				}).Warn("Failed to fetch modified WhyTho config, rebuilding it from the diff")
+			}
+
+			// Parse the new version from the diff
+			config, err := parseWhyThoConfigFromDiff(change.Diff)
+			if err != nil {
+				logrus.WithError(err).WithFields(logrus.Fields{
+					"project_id": repo,
+					"mr_iid":     number,
+				}).Warn("Failed to parse WhyTho config from diff, falling back to target branch")
+				break // Fall through to target branch lookup
+			}
+			return config, nil
+		}
+	}
+
+	// If not in diff, fetch from target branch
+	return getWhyThoConfigFromBranch(provider, repo, targetBranch)
+}
+
+// parseWhyThoConfigFromDiff parses the new version of the config from its
+// added and context lines. It is complete for new files and for changes whose
+// hunks cover the whole file.
+func parseWhyThoConfigFromDiff(rawDiff string) (*models.WhyThoConfig, error) {
+	var yamlContent strings.Builder
+	for _, hunk := range diff.Parse(rawDiff).Hunks {
+		for _, line := range hunk.Lines {
+			if line.Type == diff.LineAdded || line.Type == diff.LineContext {
+				yamlContent.WriteString(line.Content + "\n")
+			}
+		}
+	}
+
+	config, err := parseWhyThoConfig(yamlContent.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse YAML from diff: %w", err)
+	}
+	return config, nil
+}
+
+// parseWhyThoConfig parses the content of .whytho/config.yaml. Unknown
+// fields are ignored with a warning, so configs written before they were
+// rejected keep applying; ValidateWhyThoConfig reports them.
+func parseWhyThoConfig(content string) (*models.WhyThoConfig, error) {
+	config, strictErr := decodeWhyThoConfig(content)
+	if strictErr == nil {
+		return config, nil
+	}
+	var lenient models.WhyThoConfig
+	if err := yaml.Unmarshal([]byte(content), &lenient); err != nil {
+		return nil, err
+	}
+	logrus.WithError(strictErr).Warn("WhyTho config has unknown fields, ignoring them")
+	return &lenient, nil
+}
+
+func getWhyThoConfigFromBranch(provider SCMProvider, repo, branch string) (*models.WhyThoConfig, error) {
+	logrus.WithFields(logrus.Fields{
+		"project_id": repo,
+		"branch":     branch,
+	}).Debug("Fetching WhyTho config from target branch")
+
+	content, err := provider.GetFile(repo, whyThoConfigPath, branch)
+	if err != nil {
+		if errors.Is(err, ErrFileNotFound) {
+			logrus.WithFields(logrus.Fields{
+				"project_id": repo,
+				"branch":     branch,
+			}).Debug("No .whytho/config.yaml file found in repository")
+			return &models.WhyThoConfig{ExcludePaths: []string{}}, nil // Return empty config
+		}
+
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"project_id": repo,
+			"branch":     branch,
+		}).Error("Failed to fetch .whytho/config.yaml from repository")
+		return nil, fmt.Errorf("failed to fetch .whytho/config.yaml: %w", err)
+	}
+
+	// Parse YAML content
+	config, err := parseWhyThoConfig(content)
+	if err != nil {
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"project_id": repo,
+			"branch":     branch,
+		}).Error("Failed to parse .whytho/config.yaml content")
+		return nil, fmt.Errorf("failed to parse .whytho/config.yaml: %w", err)
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"project_id":    repo,
+		"branch":        branch,
+		"include_paths": len(config.IncludePaths),
+		"exclude_paths": len(config.ExcludePaths),
+		"overrides":     len(config.Overrides),
+	}).Info("Successfully fetched WhyTho config from repository")
+
+	return config, nil
+}